@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// NewOpaqueToken returns a random token of the form "<id>.<secret>" together
+// with the hash of its secret. Only the hash should ever be persisted.
+func NewOpaqueToken(id string) (token string, secretHash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+	return id + "." + secret, HashSecret(secret), nil
+}
+
+func ParseOpaqueToken(token string) (id string, secret string, err error) {
+	id, secret, found := strings.Cut(token, ".")
+	if !found || id == "" || secret == "" {
+		return "", "", ErrInvalidToken
+	}
+	return id, secret, nil
+}
+
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func SecretMatches(secret string, secretHash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(secretHash)) == 1
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOpaqueToken(t *testing.T) {
+	token, hash, err := NewOpaqueToken("session-1")
+	if err != nil {
+		t.Fatalf("NewOpaqueToken: %v", err)
+	}
+
+	id, secret, err := ParseOpaqueToken(token)
+	if err != nil {
+		t.Fatalf("ParseOpaqueToken: %v", err)
+	}
+	if id != "session-1" {
+		t.Fatalf("expected id session-1, got %q", id)
+	}
+	if strings.Contains(hash, secret) {
+		t.Fatal("expected only the hash of the secret to be returned")
+	}
+	if !SecretMatches(secret, hash) {
+		t.Fatal("expected the secret to match its hash")
+	}
+
+	other, _, err := NewOpaqueToken("session-1")
+	if err != nil {
+		t.Fatalf("NewOpaqueToken: %v", err)
+	}
+	if other == token {
+		t.Fatal("expected every token to get its own secret")
+	}
+}
+
+func TestOpaqueToken_TamperedSecret(t *testing.T) {
+	token, hash, err := NewOpaqueToken("session-1")
+	if err != nil {
+		t.Fatalf("NewOpaqueToken: %v", err)
+	}
+	_, secret, err := ParseOpaqueToken(token)
+	if err != nil {
+		t.Fatalf("ParseOpaqueToken: %v", err)
+	}
+
+	flipped := []byte(secret)
+	flipped[0] ^= 1
+	for name, candidate := range map[string]string{
+		"flipped":   string(flipped),
+		"truncated": secret[:len(secret)-1],
+		"extended":  secret + "A",
+		"empty":     "",
+		"the hash":  hash,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if SecretMatches(candidate, hash) {
+				t.Fatal("expected a tampered secret not to match")
+			}
+		})
+	}
+}
+
+func TestParseOpaqueToken_Malformed(t *testing.T) {
+	for _, token := range []string{"", "no-separator", ".secret", "id.", "."} {
+		if _, _, err := ParseOpaqueToken(token); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken for %q, got %v", token, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"slices"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+type Principal struct {
+	UserID    string
+	Email     string
+	Roles     []domain.Role
+	SessionID string
+	TenantID  string
+	// APIKeyID and Scopes are only set when the caller authenticated with an
+	// API key; such callers are limited to the scopes of that key.
+	APIKeyID string
+	Scopes   []domain.Scope
+}
+
+func (p *Principal) HasScopes(scopes ...domain.Scope) bool {
+	if p.APIKeyID == "" {
+		return true
+	}
+	for _, sc := range scopes {
+		if !slices.Contains(p.Scopes, sc) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Principal) HasAnyRole(roles ...domain.Role) bool {
+	for _, r := range roles {
+		if slices.Contains(p.Roles, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p.HasAnyRole(domain.RoleAdmin)
+}
+
+// CanActOn reports whether the caller may manage the given user's account.
+func (p *Principal) CanActOn(userID string) bool {
+	return p.UserID == userID || p.IsAdmin()
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
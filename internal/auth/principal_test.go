@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+func TestPrincipal_CanActOn(t *testing.T) {
+	tests := []struct {
+		name   string
+		caller Principal
+		userID string
+		want   bool
+	}{
+		{"self", Principal{UserID: "ada", Roles: []domain.Role{domain.RoleCustomer}}, "ada", true},
+		{"someone else", Principal{UserID: "ada", Roles: []domain.Role{domain.RoleCustomer}}, "grace", false},
+		{"sellers are not admins", Principal{UserID: "ada", Roles: []domain.Role{domain.RoleSeller}}, "grace", false},
+		{"admin", Principal{UserID: "ada", Roles: []domain.Role{domain.RoleAdmin}}, "grace", true},
+		{"no roles", Principal{UserID: "ada"}, "grace", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.caller.CanActOn(tt.userID); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPrincipal_HasScopes(t *testing.T) {
+	session := Principal{UserID: "ada"}
+	key := Principal{UserID: "ada", APIKeyID: "key-1", Scopes: []domain.Scope{domain.ScopeProductsRead}}
+
+	if !session.HasScopes(domain.ScopeProductsWrite) {
+		t.Fatal("expected a session to be limited by roles only")
+	}
+	if !key.HasScopes(domain.ScopeProductsRead) {
+		t.Fatal("expected the key's own scope to be granted")
+	}
+	if key.HasScopes(domain.ScopeProductsRead, domain.ScopeProductsWrite) {
+		t.Fatal("expected a scope the key lacks to be refused")
+	}
+}
+
+func TestPrincipalFromContext(t *testing.T) {
+	if _, ok := PrincipalFromContext(context.Background()); ok {
+		t.Fatal("expected no principal on a bare context")
+	}
+	if _, ok := PrincipalFromContext(WithPrincipal(context.Background(), nil)); ok {
+		t.Fatal("expected a nil principal to count as none")
+	}
+
+	p := &Principal{UserID: "ada"}
+	got, ok := PrincipalFromContext(WithPrincipal(context.Background(), p))
+	if !ok || got != p {
+		t.Fatalf("expected the stored principal, got %v, %v", got, ok)
+	}
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/tenant"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid or expired token")
+	ErrTokenReused  = errors.New("refresh token reuse detected, session revoked")
+)
+
+type Claims struct {
+	Email     string        `json:"email"`
+	Roles     []domain.Role `json:"roles"`
+	SessionID string        `json:"sid"`
+	Tenant    string        `json:"tid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type TokenManager struct {
+	signingKey []byte
+	issuer     string
+	accessTTL  time.Duration
+}
+
+func NewTokenManager(signingKey, issuer string, accessTTL time.Duration) (*TokenManager, error) {
+	if signingKey == "" {
+		return nil, errors.New("auth signing key cannot be empty")
+	}
+	if accessTTL <= 0 {
+		return nil, errors.New("access token ttl must be greater than 0")
+	}
+
+	return &TokenManager{
+		signingKey: []byte(signingKey),
+		issuer:     issuer,
+		accessTTL:  accessTTL,
+	}, nil
+}
+
+func (m *TokenManager) IssueAccessToken(p *Principal) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(m.accessTTL)
+
+	claims := Claims{
+		Email:     p.Email,
+		Roles:     p.Roles,
+		SessionID: p.SessionID,
+		Tenant:    p.TenantID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    m.issuer,
+			Subject:   p.UserID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.signingKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+func (m *TokenManager) ParseAccessToken(token string) (*Principal, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		return m.signingKey, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.Subject == "" || claims.SessionID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	// Tokens issued before tenancy carry no tenant claim.
+	tenantID := claims.Tenant
+	if tenantID == "" {
+		tenantID = tenant.Default
+	}
+
+	return &Principal{
+		UserID:    claims.Subject,
+		Email:     claims.Email,
+		Roles:     claims.Roles,
+		SessionID: claims.SessionID,
+		TenantID:  tenantID,
+	}, nil
+}
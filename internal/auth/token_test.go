@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/tenant"
+)
+
+const testSigningKey = "test-signing-key"
+
+func newTestTokenManager(t *testing.T) *TokenManager {
+	t.Helper()
+	m, err := NewTokenManager(testSigningKey, "test", time.Minute)
+	if err != nil {
+		t.Fatalf("NewTokenManager: %v", err)
+	}
+	return m
+}
+
+// validClaims are what IssueAccessToken would sign for a user of acme.
+func validClaims() Claims {
+	now := time.Now()
+	return Claims{
+		Email:     "ada@example.com",
+		Roles:     []domain.Role{domain.RoleCustomer},
+		SessionID: "session-1",
+		Tenant:    "acme",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "test",
+			Subject:   "user-1",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, claims Claims, key any) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return token
+}
+
+func TestNewTokenManager(t *testing.T) {
+	if _, err := NewTokenManager("", "test", time.Minute); err == nil {
+		t.Fatal("expected an empty signing key to be refused")
+	}
+	if _, err := NewTokenManager(testSigningKey, "test", 0); err == nil {
+		t.Fatal("expected a zero ttl to be refused")
+	}
+}
+
+func TestTokenManager_RoundTrip(t *testing.T) {
+	m := newTestTokenManager(t)
+	issued := &Principal{
+		UserID:    "user-1",
+		Email:     "ada@example.com",
+		Roles:     []domain.Role{domain.RoleSeller},
+		SessionID: "session-1",
+		TenantID:  "acme",
+	}
+
+	token, expiresAt, err := m.IssueAccessToken(issued)
+	if err != nil {
+		t.Fatalf("IssueAccessToken: %v", err)
+	}
+	if d := time.Until(expiresAt); d <= 0 || d > time.Minute {
+		t.Fatalf("expected the token to expire within the ttl, got %v", d)
+	}
+
+	parsed, err := m.ParseAccessToken(token)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if parsed.UserID != issued.UserID || parsed.Email != issued.Email || parsed.SessionID != issued.SessionID || parsed.TenantID != issued.TenantID {
+		t.Fatalf("expected %+v, got %+v", issued, parsed)
+	}
+	if !parsed.HasAnyRole(domain.RoleSeller) {
+		t.Fatalf("expected the roles to survive, got %v", parsed.Roles)
+	}
+}
+
+func TestTokenManager_ParseRejects(t *testing.T) {
+	m := newTestTokenManager(t)
+
+	expired := validClaims()
+	expired.IssuedAt = jwt.NewNumericDate(time.Now().Add(-2 * time.Hour))
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+
+	notYet := validClaims()
+	notYet.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour))
+
+	otherIssuer := validClaims()
+	otherIssuer.Issuer = "someone-else"
+
+	noSubject := validClaims()
+	noSubject.Subject = ""
+
+	noSession := validClaims()
+	noSession.SessionID = ""
+
+	valid := sign(t, jwt.SigningMethodHS256, validClaims(), []byte(testSigningKey))
+	header, payload, _ := strings.Cut(valid, ".")
+	payload, signature, _ := strings.Cut(payload, ".")
+	tamperedClaims := validClaims()
+	tamperedClaims.Subject = "admin"
+	tamperedPayload := strings.Split(sign(t, jwt.SigningMethodHS256, tamperedClaims, []byte("whatever")), ".")[1]
+
+	tests := map[string]string{
+		"expired":             sign(t, jwt.SigningMethodHS256, expired, []byte(testSigningKey)),
+		"without expiry":      sign(t, jwt.SigningMethodHS256, noExpiry, []byte(testSigningKey)),
+		"not yet valid":       sign(t, jwt.SigningMethodHS256, notYet, []byte(testSigningKey)),
+		"other issuer":        sign(t, jwt.SigningMethodHS256, otherIssuer, []byte(testSigningKey)),
+		"without subject":     sign(t, jwt.SigningMethodHS256, noSubject, []byte(testSigningKey)),
+		"without session":     sign(t, jwt.SigningMethodHS256, noSession, []byte(testSigningKey)),
+		"wrong key":           sign(t, jwt.SigningMethodHS256, validClaims(), []byte("another-key")),
+		"wrong algorithm":     sign(t, jwt.SigningMethodHS512, validClaims(), []byte(testSigningKey)),
+		"unsigned":            sign(t, jwt.SigningMethodNone, validClaims(), jwt.UnsafeAllowNoneSignatureType),
+		"tampered payload":    header + "." + tamperedPayload + "." + signature,
+		"truncated signature": header + "." + payload + "." + signature[:len(signature)/2],
+		"not a token":         "not-a-token",
+		"empty":               "",
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := m.ParseAccessToken(token); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("expected ErrInvalidToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTokenManager_LegacyTokensBelongToDefault(t *testing.T) {
+	m := newTestTokenManager(t)
+	claims := validClaims()
+	claims.Tenant = ""
+
+	principal, err := m.ParseAccessToken(sign(t, jwt.SigningMethodHS256, claims, []byte(testSigningKey)))
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if principal.TenantID != tenant.Default {
+		t.Fatalf("expected %q, got %q", tenant.Default, principal.TenantID)
+	}
+}
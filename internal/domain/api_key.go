@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+type Scope string
+
+const (
+	ScopeProductsRead  Scope = "products:read"
+	ScopeProductsWrite Scope = "products:write"
+)
+
+func ParseScope(s string) (Scope, error) {
+	switch sc := Scope(s); sc {
+	case ScopeProductsRead, ScopeProductsWrite:
+		return sc, nil
+	default:
+		return "", errors.New("unknown scope: " + s)
+	}
+}
+
+// APIKey lets another backend act on behalf of a user, limited to Scopes.
+// Only the hash of the secret is kept.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	SecretHash string     `json:"secret_hash"`
+	Scopes     []Scope    `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func NewAPIKey(id string, userID string, name string, secretHash string, scopes []Scope) (*APIKey, error) {
+	if id == "" {
+		return nil, errors.New("api key id cannot be empty")
+	}
+	if userID == "" {
+		return nil, errors.New("user_id cannot be empty")
+	}
+	if name == "" {
+		return nil, errors.New("name cannot be empty")
+	}
+	if secretHash == "" {
+		return nil, errors.New("secret hash cannot be empty")
+	}
+	if len(scopes) == 0 {
+		return nil, errors.New("api key must have at least one scope")
+	}
+
+	unique := make([]Scope, 0, len(scopes))
+	for _, sc := range scopes {
+		if _, err := ParseScope(string(sc)); err != nil {
+			return nil, err
+		}
+		if !slices.Contains(unique, sc) {
+			unique = append(unique, sc)
+		}
+	}
+
+	return &APIKey{
+		ID:         id,
+		UserID:     userID,
+		Name:       name,
+		SecretHash: secretHash,
+		Scopes:     unique,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+func (k *APIKey) IsActive() bool {
+	return k.RevokedAt == nil
+}
+
+func (k *APIKey) Revoke() {
+	if k.RevokedAt != nil {
+		return
+	}
+	now := time.Now()
+	k.RevokedAt = &now
+}
+
+// Touch records a use of the key. It reports whether LastUsedAt changed, so
+// callers can skip a write when the previous timestamp is recent enough.
+func (k *APIKey) Touch(now time.Time, resolution time.Duration) bool {
+	if k.LastUsedAt != nil && now.Sub(*k.LastUsedAt) < resolution {
+		return false
+	}
+	k.LastUsedAt = &now
+	return true
+}
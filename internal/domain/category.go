@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Category is a node in the catalog tree. Top-level categories have no
+// ParentID.
+type Category struct {
+	ID        string    `json:"id"`
+	ParentID  string    `json:"parent_id,omitempty"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func NewCategory(id string, parentID string, name string) (*Category, error) {
+	if id == "" {
+		id = uuid.New().String()
+	}
+
+	now := time.Now()
+	category := &Category{
+		ID:        id,
+		ParentID:  parentID,
+		Name:      strings.TrimSpace(name),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := category.Validate(); err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+func (c *Category) Validate() error {
+	if c.Name == "" {
+		return errors.New("name cannot be empty")
+	}
+	if c.ParentID == c.ID {
+		return ErrCategoryCycle
+	}
+	return nil
+}
+
+// Subtree returns id followed by the IDs of every category below it in
+// categories, which should hold the whole tree.
+func Subtree(categories []*Category, id string) []string {
+	children := make(map[string][]string, len(categories))
+	for _, c := range categories {
+		if c.ParentID != "" {
+			children[c.ParentID] = append(children[c.ParentID], c.ID)
+		}
+	}
+
+	ids := []string{id}
+	seen := map[string]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids
+}
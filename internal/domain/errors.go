@@ -0,0 +1,32 @@
+package domain
+
+import "errors"
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrProductNotFound    = errors.New("product not found")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrTokenNotFound      = errors.New("token not found")
+	ErrTokenUsed          = errors.New("token has already been used")
+	ErrAPIKeyNotFound     = errors.New("api key not found")
+	ErrAttemptsNotFound   = errors.New("login attempts not found")
+	ErrExportNotFound     = errors.New("export not found")
+	ErrExportNotReady     = errors.New("export is not ready")
+	ErrExportPending      = errors.New("an export is already in progress")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrIncorrectPassword  = errors.New("current password is incorrect")
+	ErrForbidden          = errors.New("forbidden")
+	ErrEmailTaken         = errors.New("email is already in use")
+	ErrLoginThrottled     = errors.New("too many failed login attempts")
+	ErrRestoreExpired     = errors.New("restore grace period has expired")
+	ErrInsufficientStock  = errors.New("insufficient stock")
+	ErrVersionMismatch    = errors.New("resource version does not match")
+	ErrCurrencyMismatch   = errors.New("currencies do not match")
+	ErrCategoryNotFound   = errors.New("category not found")
+	ErrCategoryInUse      = errors.New("category still has subcategories or products")
+	ErrCategoryCycle      = errors.New("category cannot be moved below itself")
+	ErrImageNotFound      = errors.New("image not found")
+	ErrImageTooLarge      = errors.New("image is too large")
+	ErrImageLimitReached  = errors.New("product has reached its image limit")
+	ErrBatchAborted       = errors.New("not applied because another operation in the batch failed")
+)
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+type ExportStatus string
+
+const (
+	ExportStatusPending   ExportStatus = "pending"
+	ExportStatusCompleted ExportStatus = "completed"
+	ExportStatusFailed    ExportStatus = "failed"
+)
+
+// ExportJob tracks a personal data export that is built in the background.
+// The archive itself is stored separately and expires together with the job.
+type ExportJob struct {
+	ID          string       `json:"id"`
+	UserID      string       `json:"user_id"`
+	Status      ExportStatus `json:"status"`
+	Error       string       `json:"error,omitempty"`
+	Size        int          `json:"size"`
+	CreatedAt   time.Time    `json:"created_at"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty"`
+	ExpiresAt   time.Time    `json:"expires_at"`
+}
+
+func NewExportJob(id string, userID string, expiresAt time.Time) (*ExportJob, error) {
+	if id == "" {
+		return nil, errors.New("export id cannot be empty")
+	}
+	if userID == "" {
+		return nil, errors.New("user_id cannot be empty")
+	}
+
+	return &ExportJob{
+		ID:        id,
+		UserID:    userID,
+		Status:    ExportStatusPending,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (j *ExportJob) Complete(size int) {
+	now := time.Now()
+	j.Status = ExportStatusCompleted
+	j.Size = size
+	j.CompletedAt = &now
+}
+
+func (j *ExportJob) Fail(err error) {
+	now := time.Now()
+	j.Status = ExportStatusFailed
+	j.Error = err.Error()
+	j.CompletedAt = &now
+}
+
+func (j *ExportJob) IsPending() bool {
+	return j.Status == ExportStatusPending
+}
+
+func (j *ExportJob) IsReady() bool {
+	return j.Status == ExportStatusCompleted
+}
@@ -0,0 +1,145 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// currencyExponents lists the supported ISO 4217 codes with the number of
+// decimal places of their minor unit.
+var currencyExponents = map[string]int{
+	"AED": 2, "ARS": 2, "AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2,
+	"CLP": 0, "CNY": 2, "COP": 2, "CZK": 2, "DKK": 2, "EGP": 2, "EUR": 2,
+	"GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2, "INR": 2, "ISK": 0,
+	"JOD": 3, "JPY": 0, "KRW": 0, "KWD": 3, "MXN": 2, "MYR": 2, "NOK": 2,
+	"NZD": 2, "OMR": 3, "PHP": 2, "PLN": 2, "QAR": 2, "RON": 2, "SAR": 2,
+	"SEK": 2, "SGD": 2, "THB": 2, "TND": 3, "TRY": 2, "TWD": 2, "UAH": 2,
+	"USD": 2, "VND": 0, "ZAR": 2,
+}
+
+var errAmountOverflow = errors.New("money amount out of range")
+
+// Money is an amount in the minor unit of a currency, such as cents, so
+// arithmetic never rounds. Values of different currencies do not mix.
+type Money struct {
+	amount   int64
+	currency string
+}
+
+// NewMoney validates the currency code, accepting it in any letter case.
+func NewMoney(amount int64, currency string) (Money, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if _, ok := currencyExponents[currency]; !ok {
+		return Money{}, fmt.Errorf("unsupported currency %q: must be an ISO 4217 code", currency)
+	}
+	return Money{amount: amount, currency: currency}, nil
+}
+
+// ReconstituteMoney rebuilds a stored value without validating it again.
+func ReconstituteMoney(amount int64, currency string) Money {
+	return Money{amount: amount, currency: currency}
+}
+
+// NormalizeCurrency upper-cases code and checks that it is supported.
+func NormalizeCurrency(code string) (string, error) {
+	m, err := NewMoney(0, code)
+	return m.currency, err
+}
+
+func (m Money) Amount() int64      { return m.amount }
+func (m Money) Currency() string   { return m.currency }
+func (m Money) IsZero() bool       { return m.amount == 0 }
+func (m Money) IsPositive() bool   { return m.amount > 0 }
+func (m Money) IsNegative() bool   { return m.amount < 0 }
+func (m Money) Equal(o Money) bool { return m == o }
+
+func (m Money) Add(o Money) (Money, error) {
+	if m.currency != o.currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	if (o.amount > 0 && m.amount > math.MaxInt64-o.amount) || (o.amount < 0 && m.amount < math.MinInt64-o.amount) {
+		return Money{}, errAmountOverflow
+	}
+	return Money{amount: m.amount + o.amount, currency: m.currency}, nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if o.amount == math.MinInt64 {
+		return Money{}, errAmountOverflow
+	}
+	return m.Add(Money{amount: -o.amount, currency: o.currency})
+}
+
+// Mul scales the amount, for example by a quantity.
+func (m Money) Mul(n int64) (Money, error) {
+	if m.amount == 0 || n == 0 {
+		return Money{amount: 0, currency: m.currency}, nil
+	}
+	product := m.amount * n
+	if product/n != m.amount || (m.amount == -1 && n == math.MinInt64) || (n == -1 && m.amount == math.MinInt64) {
+		return Money{}, errAmountOverflow
+	}
+	return Money{amount: product, currency: m.currency}, nil
+}
+
+// Compare orders two amounts of the same currency like cmp.Compare.
+func (m Money) Compare(o Money) (int, error) {
+	if m.currency != o.currency {
+		return 0, ErrCurrencyMismatch
+	}
+	switch {
+	case m.amount < o.amount:
+		return -1, nil
+	case m.amount > o.amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// String formats the amount in major units followed by the currency code,
+// such as "12.34 USD" or "1500 JPY".
+func (m Money) String() string {
+	exponent := currencyExponents[m.currency]
+
+	sign, abs := "", uint64(m.amount)
+	if m.amount < 0 {
+		sign, abs = "-", -abs
+	}
+	digits := strconv.FormatUint(abs, 10)
+	if exponent == 0 {
+		return sign + digits + " " + m.currency
+	}
+
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	cut := len(digits) - exponent
+	return sign + digits[:cut] + "." + digits[cut:] + " " + m.currency
+}
+
+type moneyJSON struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.amount, Currency: m.currency})
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v moneyJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	parsed, err := NewMoney(v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
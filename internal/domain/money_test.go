@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestNormalizeCurrency(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		ok       bool
+	}{
+		{"usd", "USD", true},
+		{" Eur ", "EUR", true},
+		{"JPY", "JPY", true},
+		{"", "", false},
+		{"US", "", false},
+		{"XXX", "", false},
+	} {
+		got, err := NormalizeCurrency(tc.in)
+		if (err == nil) != tc.ok {
+			t.Fatalf("NormalizeCurrency(%q): err = %v, want ok = %v", tc.in, err, tc.ok)
+		}
+		if tc.ok && got != tc.want {
+			t.Fatalf("NormalizeCurrency(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestMoney_String(t *testing.T) {
+	for _, tc := range []struct {
+		amount   int64
+		currency string
+		want     string
+	}{
+		{1234, "USD", "12.34 USD"},
+		{5, "USD", "0.05 USD"},
+		{-150, "EUR", "-1.50 EUR"},
+		{0, "USD", "0.00 USD"},
+		{1500, "JPY", "1500 JPY"},
+		{1, "KWD", "0.001 KWD"},
+		{math.MinInt64, "JPY", "-9223372036854775808 JPY"},
+	} {
+		m, err := NewMoney(tc.amount, tc.currency)
+		if err != nil {
+			t.Fatalf("NewMoney(%d, %q): %v", tc.amount, tc.currency, err)
+		}
+		if got := m.String(); got != tc.want {
+			t.Fatalf("String() = %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	usd := func(amount int64) Money { return ReconstituteMoney(amount, "USD") }
+
+	sum, err := usd(150).Add(usd(250))
+	if err != nil || !sum.Equal(usd(400)) {
+		t.Fatalf("Add = %v, %v; want 4.00 USD", sum, err)
+	}
+	diff, err := usd(150).Sub(usd(250))
+	if err != nil || !diff.Equal(usd(-100)) || !diff.IsNegative() {
+		t.Fatalf("Sub = %v, %v; want -1.00 USD", diff, err)
+	}
+	total, err := usd(199).Mul(3)
+	if err != nil || !total.Equal(usd(597)) {
+		t.Fatalf("Mul = %v, %v; want 5.97 USD", total, err)
+	}
+	if c, err := usd(1).Compare(usd(2)); err != nil || c != -1 {
+		t.Fatalf("Compare = %d, %v; want -1", c, err)
+	}
+
+	if _, err := usd(1).Add(ReconstituteMoney(1, "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("Add across currencies: err = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := usd(1).Compare(ReconstituteMoney(1, "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("Compare across currencies: err = %v, want ErrCurrencyMismatch", err)
+	}
+
+	overflows := map[string]func() (Money, error){
+		"Add":          func() (Money, error) { return usd(math.MaxInt64).Add(usd(1)) },
+		"Sub":          func() (Money, error) { return usd(math.MinInt64).Sub(usd(1)) },
+		"Sub MinInt64": func() (Money, error) { return usd(0).Sub(usd(math.MinInt64)) },
+		"Mul":          func() (Money, error) { return usd(math.MaxInt64 / 2).Mul(3) },
+		"Mul MinInt64": func() (Money, error) { return usd(math.MinInt64).Mul(-1) },
+	}
+	for name, op := range overflows {
+		if _, err := op(); !errors.Is(err, errAmountOverflow) {
+			t.Fatalf("%s: err = %v, want errAmountOverflow", name, err)
+		}
+	}
+}
+
+func TestMoney_JSON(t *testing.T) {
+	m, err := NewMoney(1999, "eur")
+	if err != nil {
+		t.Fatalf("NewMoney: %v", err)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if string(data) != `{"amount":1999,"currency":"EUR"}` {
+		t.Fatalf("Marshal = %s", data)
+	}
+
+	var back Money
+	if err := json.Unmarshal(data, &back); err != nil || !back.Equal(m) {
+		t.Fatalf("Unmarshal = %v, %v; want %v", back, err, m)
+	}
+	if err := json.Unmarshal([]byte(`{"amount":1,"currency":"ABC"}`), &back); err == nil {
+		t.Fatal("Unmarshal accepted an unsupported currency")
+	}
+}
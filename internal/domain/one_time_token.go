@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+type TokenPurpose string
+
+const (
+	TokenPurposeEmailChange   TokenPurpose = "email_change"
+	TokenPurposePasswordReset TokenPurpose = "password_reset"
+)
+
+// OneTimeToken backs flows that are confirmed out of band, such as an email
+// change or a password reset. Only the hash of the secret is stored; Email
+// carries the address the token was issued for.
+type OneTimeToken struct {
+	ID        string       `json:"id"`
+	UserID    string       `json:"user_id"`
+	Purpose   TokenPurpose `json:"purpose"`
+	TokenHash string       `json:"token_hash"`
+	Email     string       `json:"email"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    *time.Time   `json:"used_at,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+func NewOneTimeToken(id string, userID string, purpose TokenPurpose, tokenHash string, email string, expiresAt time.Time) (*OneTimeToken, error) {
+	if id == "" {
+		return nil, errors.New("token id cannot be empty")
+	}
+	if userID == "" {
+		return nil, errors.New("user_id cannot be empty")
+	}
+	if tokenHash == "" {
+		return nil, errors.New("token hash cannot be empty")
+	}
+
+	return &OneTimeToken{
+		ID:        id,
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: tokenHash,
+		Email:     email,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func (t *OneTimeToken) IsUsable(purpose TokenPurpose, now time.Time) bool {
+	return t.Purpose == purpose && t.UsedAt == nil && now.Before(t.ExpiresAt)
+}
+
+func (t *OneTimeToken) MarkUsed(now time.Time) {
+	t.UsedAt = &now
+}
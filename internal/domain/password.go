@@ -0,0 +1,15 @@
+package domain
+
+// PasswordHasher produces self-describing hash strings: the algorithm and its
+// parameters are encoded in the hash, so stored passwords keep verifying after
+// the hashing policy changes.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hash, password string) (bool, error)
+	// NeedsRehash reports whether hash was produced by an algorithm or with
+	// parameters other than the ones currently preferred.
+	NeedsRehash(hash string) bool
+	// MaxLength is the longest password, in bytes, the preferred algorithm
+	// hashes.
+	MaxLength() int
+}
@@ -2,45 +2,58 @@ package domain
 
 import (
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
 
 type Product struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Name      string    `json:"name"`
-	Price     int       `json:"price"`
-	Stock     int       `json:"stock"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Price      Money      `json:"price"`
+	Stock      int        `json:"stock"`
+	CategoryID string     `json:"category_id,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	// Version changes on every write. Repositories assign it and refuse to
+	// update a product whose version is no longer current.
+	Version uint64 `json:"version"`
 }
 
-func NewProduct(id string, userID string, name string, price int, stock int) (*Product, error) {
-
-	if id == "" {
-		id = uuid.New().String()
-	}
-
-	if userID == "" {
-		return nil, errors.New("user_id cannot be empty")
-	}
+// Limits on free-form product tags.
+const (
+	MaxProductTags = 20
+	MaxTagLength   = 32
+)
 
-	if name == "" {
-		return nil, errors.New("name cannot be empty")
-	}
+// ProductPatch lists the fields to change; a nil field is left as it is.
+// Price is in minor units of Currency, or of the current currency when
+// Currency is nil. An empty CategoryID uncategorizes the product, and Tags
+// replaces the whole tag set.
+type ProductPatch struct {
+	Name       *string
+	Price      *int64
+	Currency   *string
+	Stock      *int
+	CategoryID *string
+	Tags       *[]string
+}
 
-	if price <= 0 {
-		return nil, errors.New("price must be greater than 0")
-	}
+func NewProduct(id string, userID string, name string, price Money, stock int) (*Product, error) {
 
-	if stock < 0 {
-		return nil, errors.New("stock cannot be negative")
+	if id == "" {
+		id = uuid.New().String()
 	}
 
 	now := time.Now()
-	return &Product{
+	product := &Product{
 		ID:        id,
 		UserID:    userID,
 		Name:      name,
@@ -48,17 +61,157 @@ func NewProduct(id string, userID string, name string, price int, stock int) (*P
 		Stock:     stock,
 		CreatedAt: now,
 		UpdatedAt: now,
-	}, nil
+	}
+	if err := product.Validate(); err != nil {
+		return nil, err
+	}
+
+	return product, nil
 }
 
-func ReconstituteProduct(id string, userID string, name string, price int, stock int, createdAt time.Time, updatedAt time.Time) *Product {
+func ReconstituteProduct(id string, userID string, name string, price Money, stock int, categoryID string, tags []string, createdAt time.Time, updatedAt time.Time, deletedAt *time.Time, version uint64) *Product {
 	return &Product{
-		ID:        id,
-		UserID:    userID,
-		Name:      name,
-		Price:     price,
-		Stock:     stock,
-		CreatedAt: createdAt,
-		UpdatedAt: updatedAt,
+		ID:         id,
+		UserID:     userID,
+		Name:       name,
+		Price:      price,
+		Stock:      stock,
+		CategoryID: categoryID,
+		Tags:       tags,
+		CreatedAt:  createdAt,
+		UpdatedAt:  updatedAt,
+		DeletedAt:  deletedAt,
+		Version:    version,
+	}
+}
+
+// Validate checks the invariants every product must satisfy.
+func (p *Product) Validate() error {
+	if p.UserID == "" {
+		return errors.New("user_id cannot be empty")
+	}
+
+	if p.Name == "" {
+		return errors.New("name cannot be empty")
+	}
+
+	if p.Price.Currency() == "" {
+		return errors.New("price must have a currency")
+	}
+
+	if !p.Price.IsPositive() {
+		return errors.New("price must be greater than 0")
+	}
+
+	if p.Stock < 0 {
+		return errors.New("stock cannot be negative")
+	}
+
+	if len(p.Tags) > MaxProductTags {
+		return fmt.Errorf("a product can have at most %d tags", MaxProductTags)
+	}
+
+	return nil
+}
+
+// NormalizeTags lower-cases and trims tags, drops duplicates and sorts them,
+// so equal tag sets compare and filter the same whichever way they were
+// written.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			return nil, errors.New("tags cannot be empty")
+		}
+		if utf8.RuneCountInString(tag) > MaxTagLength {
+			return nil, fmt.Errorf("tags cannot be longer than %d characters", MaxTagLength)
+		}
+		normalized = append(normalized, tag)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
+
+// ApplyPatch changes the patched fields and validates the result as a whole.
+// On error the product is left untouched.
+func (p *Product) ApplyPatch(patch ProductPatch, now time.Time) error {
+	patched := *p
+	if patch.Name != nil {
+		patched.Name = *patch.Name
+	}
+	if patch.Price != nil || patch.Currency != nil {
+		amount, currency := p.Price.Amount(), p.Price.Currency()
+		if patch.Price != nil {
+			amount = *patch.Price
+		}
+		if patch.Currency != nil {
+			currency = *patch.Currency
+		}
+		price, err := NewMoney(amount, currency)
+		if err != nil {
+			return err
+		}
+		patched.Price = price
+	}
+	if patch.Stock != nil {
+		patched.Stock = *patch.Stock
+	}
+	if patch.CategoryID != nil {
+		patched.CategoryID = *patch.CategoryID
+	}
+	if patch.Tags != nil {
+		tags, err := NormalizeTags(*patch.Tags)
+		if err != nil {
+			return err
+		}
+		patched.Tags = nil
+		if len(tags) > 0 {
+			patched.Tags = tags
+		}
+	}
+
+	if err := patched.Validate(); err != nil {
+		return err
+	}
+
+	patched.UpdatedAt = now
+	*p = patched
+	return nil
+}
+
+func (p *Product) IsDeleted() bool {
+	return p.DeletedAt != nil
+}
+
+// SoftDelete hides the product until it is restored or purged.
+func (p *Product) SoftDelete(now time.Time) {
+	if p.DeletedAt != nil {
+		return
+	}
+	p.DeletedAt = &now
+	p.UpdatedAt = now
+}
+
+// AdjustStock adds a signed delta to the stock, refusing any change that
+// would take it below zero.
+func (p *Product) AdjustStock(delta int, now time.Time) error {
+	if p.Stock+delta < 0 {
+		return ErrInsufficientStock
+	}
+	p.Stock += delta
+	p.UpdatedAt = now
+	return nil
+}
+
+func (p *Product) Restore(now time.Time, gracePeriod time.Duration) error {
+	if p.DeletedAt == nil {
+		return errors.New("product is not deleted")
+	}
+	if now.After(p.DeletedAt.Add(gracePeriod)) {
+		return ErrRestoreExpired
 	}
+	p.DeletedAt = nil
+	p.UpdatedAt = now
+	return nil
 }
@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// ProductImage records an uploaded image and its thumbnail. The files live in
+// a blob store under Key and ThumbnailKey; Width and Height are those of the
+// original.
+type ProductImage struct {
+	ID           string    `json:"id"`
+	ProductID    string    `json:"product_id"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Key          string    `json:"key"`
+	ThumbnailKey string    `json:"thumbnail_key"`
+	CreatedAt    time.Time `json:"created_at"`
+}
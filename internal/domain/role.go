@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"errors"
+	"slices"
+)
+
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleSeller   Role = "seller"
+	RoleCustomer Role = "customer"
+)
+
+func ParseRole(s string) (Role, error) {
+	switch r := Role(s); r {
+	case RoleAdmin, RoleSeller, RoleCustomer:
+		return r, nil
+	default:
+		return "", errors.New("unknown role: " + s)
+	}
+}
+
+// normalizeRoles validates and de-duplicates roles, keeping their order.
+func normalizeRoles(roles []Role) ([]Role, error) {
+	if len(roles) == 0 {
+		return nil, errors.New("user must have at least one role")
+	}
+
+	normalized := make([]Role, 0, len(roles))
+	for _, r := range roles {
+		if _, err := ParseRole(string(r)); err != nil {
+			return nil, err
+		}
+		if !slices.Contains(normalized, r) {
+			normalized = append(normalized, r)
+		}
+	}
+	return normalized, nil
+}
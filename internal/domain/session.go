@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// maxPreviousTokenHashes bounds how many rotated refresh tokens a session
+// remembers for reuse detection.
+const maxPreviousTokenHashes = 50
+
+// Session is a refresh-token family. Every refresh rotates TokenHash and keeps
+// the old hash around, so replaying a rotated token can be detected and the
+// whole family revoked.
+type Session struct {
+	ID                  string     `json:"id"`
+	UserID              string     `json:"user_id"`
+	TokenHash           string     `json:"token_hash"`
+	PreviousTokenHashes []string   `json:"previous_token_hashes"`
+	ExpiresAt           time.Time  `json:"expires_at"`
+	RevokedAt           *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	// Version changes on every write. Repositories assign it and refuse to
+	// update a session whose version is no longer current, so two refreshes
+	// racing on the same token cannot both rotate it.
+	Version uint64 `json:"version"`
+}
+
+func NewSession(id string, userID string, tokenHash string, expiresAt time.Time) (*Session, error) {
+	if id == "" {
+		return nil, errors.New("session id cannot be empty")
+	}
+	if userID == "" {
+		return nil, errors.New("user_id cannot be empty")
+	}
+	if tokenHash == "" {
+		return nil, errors.New("token hash cannot be empty")
+	}
+
+	now := time.Now()
+	return &Session{
+		ID:        id,
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+func (s *Session) WasRotated(tokenHash string) bool {
+	return slices.Contains(s.PreviousTokenHashes, tokenHash)
+}
+
+func (s *Session) Rotate(newTokenHash string) {
+	s.PreviousTokenHashes = append(s.PreviousTokenHashes, s.TokenHash)
+	if len(s.PreviousTokenHashes) > maxPreviousTokenHashes {
+		s.PreviousTokenHashes = s.PreviousTokenHashes[len(s.PreviousTokenHashes)-maxPreviousTokenHashes:]
+	}
+	s.TokenHash = newTokenHash
+	s.UpdatedAt = time.Now()
+}
+
+func (s *Session) Revoke() {
+	if s.RevokedAt != nil {
+		return
+	}
+	now := time.Now()
+	s.RevokedAt = &now
+	s.UpdatedAt = now
+}
@@ -2,10 +2,13 @@ package domain
 
 import (
 	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
@@ -13,22 +16,33 @@ type User struct {
 	name      string
 	email     string
 	password  string
+	roles     []Role
 	createdAt time.Time
 	updatedAt time.Time
+	deletedAt *time.Time
+	version   uint64
 }
 
-func NewUser(name, email, password string) (*User, error) {
+func NewUser(name, email, password string, hasher PasswordHasher, roles ...Role) (*User, error) {
 	if name == "" {
 		return nil, errors.New("name cannot be empty")
 	}
-	if email == "" {
-		return nil, errors.New("email cannot be empty")
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return nil, err
 	}
-	if len(password) < 6 {
-		return nil, errors.New("password must be at least 6 characters")
+	if err := ValidatePassword(password, hasher); err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		roles = []Role{RoleCustomer}
+	}
+	roles, err = normalizeRoles(roles)
+	if err != nil {
+		return nil, err
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := hasher.Hash(password)
 	if err != nil {
 		return nil, err
 	}
@@ -37,45 +51,103 @@ func NewUser(name, email, password string) (*User, error) {
 		id:        uuid.New().String(),
 		name:      name,
 		email:     email,
-		password:  string(hashedPassword),
+		password:  hashedPassword,
+		roles:     roles,
 		createdAt: time.Now(),
 		updatedAt: time.Now(),
 	}, nil
 }
 
-func Reconstitute(id, name, email, password string, createdAt, updatedAt time.Time) *User {
+func Reconstitute(id, name, email, password string, roles []Role, createdAt, updatedAt time.Time, deletedAt *time.Time, version uint64) *User {
+	// Records written before roles existed belong to plain customers.
+	if len(roles) == 0 {
+		roles = []Role{RoleCustomer}
+	}
 	return &User{
 		id:        id,
 		name:      name,
 		email:     email,
 		password:  password,
+		roles:     roles,
 		createdAt: createdAt,
 		updatedAt: updatedAt,
+		deletedAt: deletedAt,
+		version:   version,
 	}
 }
 
-func (u *User) ID() string           { return u.id }
-func (u *User) Name() string         { return u.name }
-func (u *User) Email() string        { return u.email }
-func (u *User) Password() string     { return u.password }
-func (u *User) CreatedAt() time.Time { return u.createdAt }
-func (u *User) UpdatedAt() time.Time { return u.updatedAt }
+func (u *User) ID() string            { return u.id }
+func (u *User) Name() string          { return u.name }
+func (u *User) Email() string         { return u.email }
+func (u *User) Password() string      { return u.password }
+func (u *User) Roles() []Role         { return slices.Clone(u.roles) }
+func (u *User) CreatedAt() time.Time  { return u.createdAt }
+func (u *User) UpdatedAt() time.Time  { return u.updatedAt }
+func (u *User) DeletedAt() *time.Time { return u.deletedAt }
+func (u *User) IsDeleted() bool       { return u.deletedAt != nil }
+func (u *User) Version() uint64       { return u.version }
 
-func (u *User) UpdatePassword(newPassword string) error {
-	if len(newPassword) < 6 {
-		return errors.New("password must be at least 6 characters")
+// SetVersion records the version a repository assigned on write; like
+// Product.Version it changes on every write.
+func (u *User) SetVersion(version uint64) { u.version = version }
+
+func (u *User) UpdatePassword(newPassword string, hasher PasswordHasher) error {
+	if err := ValidatePassword(newPassword, hasher); err != nil {
+		return err
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	hashedPassword, err := hasher.Hash(newPassword)
 	if err != nil {
 		return err
 	}
 
-	u.password = string(hashedPassword)
+	u.password = hashedPassword
 	u.updatedAt = time.Now()
 	return nil
 }
 
+func (u *User) ChangePassword(currentPassword, newPassword string, hasher PasswordHasher) error {
+	if !u.CheckPassword(currentPassword, hasher) {
+		return ErrIncorrectPassword
+	}
+	if currentPassword == newPassword {
+		return errors.New("new password must differ from the current password")
+	}
+	return u.UpdatePassword(newPassword, hasher)
+}
+
+// RehashPassword re-encodes an already verified password when the stored hash
+// no longer matches the hasher's policy. It skips ValidatePassword on purpose:
+// accounts created under an older policy must keep working.
+func (u *User) RehashPassword(password string, hasher PasswordHasher) (bool, error) {
+	if !hasher.NeedsRehash(u.password) {
+		return false, nil
+	}
+
+	hashedPassword, err := hasher.Hash(password)
+	if err != nil {
+		return false, err
+	}
+
+	u.password = hashedPassword
+	return true, nil
+}
+
+// UserPatch lists the profile fields to change; a nil field is left as it is.
+// Email, password and roles have flows of their own and are not patchable.
+type UserPatch struct {
+	Name *string
+}
+
+// ApplyPatch changes the patched fields, validating each. On error the user
+// is left untouched.
+func (u *User) ApplyPatch(patch UserPatch) error {
+	if patch.Name == nil {
+		return nil
+	}
+	return u.UpdateName(*patch.Name)
+}
+
 func (u *User) UpdateName(newName string) error {
 	if newName == "" {
 		return errors.New("name cannot be empty")
@@ -85,7 +157,85 @@ func (u *User) UpdateName(newName string) error {
 	return nil
 }
 
-func (u *User) CheckPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.password), []byte(password))
-	return err == nil
+func (u *User) HasRole(role Role) bool {
+	return slices.Contains(u.roles, role)
+}
+
+func (u *User) UpdateRoles(roles []Role) error {
+	roles, err := normalizeRoles(roles)
+	if err != nil {
+		return err
+	}
+	u.roles = roles
+	u.updatedAt = time.Now()
+	return nil
+}
+
+func (u *User) UpdateEmail(newEmail string) error {
+	email, err := NormalizeEmail(newEmail)
+	if err != nil {
+		return err
+	}
+	u.email = email
+	u.updatedAt = time.Now()
+	return nil
+}
+
+// SoftDelete hides the user until it is restored or purged.
+func (u *User) SoftDelete(now time.Time) {
+	if u.deletedAt != nil {
+		return
+	}
+	u.deletedAt = &now
+	u.updatedAt = now
+}
+
+func (u *User) Restore(now time.Time, gracePeriod time.Duration) error {
+	if u.deletedAt == nil {
+		return errors.New("user is not deleted")
+	}
+	if now.After(u.deletedAt.Add(gracePeriod)) {
+		return ErrRestoreExpired
+	}
+	u.deletedAt = nil
+	u.updatedAt = now
+	return nil
+}
+
+func (u *User) CheckPassword(password string, hasher PasswordHasher) bool {
+	ok, err := hasher.Verify(u.password, password)
+	return err == nil && ok
+}
+
+// ValidatePassword enforces the password policy. The upper bound is the
+// hasher's, so a password is never longer than its algorithm reads.
+func ValidatePassword(password string, hasher PasswordHasher) error {
+	if len(password) < 6 {
+		return errors.New("password must be at least 6 characters")
+	}
+	if maxLength := hasher.MaxLength(); len(password) > maxLength {
+		return fmt.Errorf("password must be at most %d bytes", maxLength)
+	}
+	return nil
+}
+
+// NormalizeEmail trims and lowercases an address and rejects anything that is
+// not a bare addr-spec, so "Name <a@b.c>" style input does not slip through.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return "", errors.New("email cannot be empty")
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", errors.New("email is not a valid address")
+	}
+
+	_, host, _ := strings.Cut(email, "@")
+	if !strings.Contains(host, ".") {
+		return "", errors.New("email is not a valid address")
+	}
+
+	return email, nil
 }
@@ -0,0 +1,22 @@
+package dto
+
+import "time"
+
+// CategoryRequest creates or replaces a category; an empty parent_id makes
+// it top-level.
+type CategoryRequest struct {
+	Name     string `json:"name"`
+	ParentID string `json:"parent_id"`
+}
+
+type CategoryResponse struct {
+	ID        string    `json:"id"`
+	ParentID  string    `json:"parent_id,omitempty"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CategoryListResponse struct {
+	Items []CategoryResponse `json:"items"`
+}
@@ -2,23 +2,107 @@ package dto
 
 import "time"
 
+// Prices are in the minor unit of their currency, such as cents. A product
+// created without a currency gets the configured default, and one without a
+// category_id stays uncategorized.
 type CreateProductRequest struct {
-	Name  string `json:"name"`
-	Price int    `json:"price"`
-	Stock int    `json:"stock"`
+	Name       string   `json:"name"`
+	Price      int64    `json:"price"`
+	Currency   string   `json:"currency"`
+	Stock      int      `json:"stock"`
+	CategoryID string   `json:"category_id"`
+	Tags       []string `json:"tags"`
 }
 
+// UpdateProductRequest replaces every editable field, so all are required
+// except the currency, which is kept when left out. A missing category_id or
+// tags clears them.
 type UpdateProductRequest struct {
-	Name  string `json:"name"`
-	Price int    `json:"price"`
-	Stock int    `json:"stock"`
+	Name       *string  `json:"name"`
+	Price      *int64   `json:"price"`
+	Currency   *string  `json:"currency"`
+	Stock      *int     `json:"stock"`
+	CategoryID string   `json:"category_id"`
+	Tags       []string `json:"tags"`
+}
+
+// PatchProductRequest is an RFC 7386 merge patch: members left out keep their
+// current value. category_id and tags may be null to clear them, and tags
+// replaces the whole set.
+type PatchProductRequest struct {
+	Name       *string   `json:"name"`
+	Price      *int64    `json:"price"`
+	Currency   *string   `json:"currency"`
+	Stock      *int      `json:"stock"`
+	CategoryID *string   `json:"category_id"`
+	Tags       *[]string `json:"tags"`
+}
+
+// BatchProductRequest carries the operations of POST /products:batch. With
+// atomic set, either every operation is applied or none is.
+type BatchProductRequest struct {
+	Atomic     bool                    `json:"atomic"`
+	Operations []BatchProductOperation `json:"operations"`
+}
+
+// BatchProductOperation is a create, update or delete. Updates and deletes
+// name the product by id and may carry an if_match entity tag. product holds
+// the fields to create with or change; unlike in a merge patch, null means
+// absent, so an empty category_id or tags clears them.
+type BatchProductOperation struct {
+	Op      string              `json:"op"`
+	ID      string              `json:"id"`
+	IfMatch string              `json:"if_match"`
+	Product PatchProductRequest `json:"product"`
+}
+
+// BatchProductResult reports one operation under its index in the request,
+// with the status it would have had as a request of its own.
+type BatchProductResult struct {
+	Index   int              `json:"index"`
+	Op      string           `json:"op"`
+	Status  int              `json:"status"`
+	ID      string           `json:"id,omitempty"`
+	ETag    string           `json:"etag,omitempty"`
+	Product *ProductResponse `json:"product,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+type BatchProductResponse struct {
+	Results   []BatchProductResult `json:"results"`
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+}
+
+// AdjustStockRequest carries a signed change: negative to take stock, positive
+// to replenish it.
+type AdjustStockRequest struct {
+	Delta int `json:"delta"`
 }
 
 type ProductResponse struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Name      string    `json:"name"`
-	Price     int       `json:"price"`
-	Stock     int       `json:"stock"`
-	CreatedAt time.Time `json:"created_at"`
+	ID             string    `json:"id"`
+	UserID         string    `json:"user_id"`
+	Name           string    `json:"name"`
+	Price          int64     `json:"price"`
+	Currency       string    `json:"currency"`
+	FormattedPrice string    `json:"formatted_price"`
+	Stock          int       `json:"stock"`
+	CategoryID     string    `json:"category_id,omitempty"`
+	Tags           []string  `json:"tags"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type ProductListResponse struct {
+	Items      []ProductResponse `json:"items"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+type ProductSearchResult struct {
+	ProductResponse
+	Score float64 `json:"score"`
+}
+
+type ProductSearchResponse struct {
+	Items []ProductSearchResult `json:"items"`
 }
@@ -0,0 +1,22 @@
+package dto
+
+import "time"
+
+// ProductImageResponse carries signed URLs for the image and its thumbnail;
+// both stop working at expires_at and have to be fetched again.
+type ProductImageResponse struct {
+	ID           string    `json:"id"`
+	ProductID    string    `json:"product_id"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ProductImageListResponse struct {
+	Items []ProductImageResponse `json:"items"`
+}
@@ -0,0 +1,116 @@
+// Package export builds personal data archives. The archive has its own JSON
+// shapes so that nothing sensitive, such as the password hash, can leak in
+// through a field added to an API response later.
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+type manifest struct {
+	UserID      string    `json:"user_id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Files       []string  `json:"files"`
+}
+
+type profile struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Roles     []string  `json:"roles"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type product struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Price      int64     `json:"price"`
+	Currency   string    `json:"currency"`
+	Stock      int       `json:"stock"`
+	CategoryID string    `json:"category_id,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Build returns a ZIP archive with manifest.json, profile.json and
+// products.json.
+func Build(user *domain.User, products []*domain.Product) ([]byte, error) {
+	roles := make([]string, 0, len(user.Roles()))
+	for _, r := range user.Roles() {
+		roles = append(roles, string(r))
+	}
+
+	items := make([]product, 0, len(products))
+	for _, p := range products {
+		items = append(items, product{
+			ID:         p.ID,
+			Name:       p.Name,
+			Price:      p.Price.Amount(),
+			Currency:   p.Price.Currency(),
+			Stock:      p.Stock,
+			CategoryID: p.CategoryID,
+			Tags:       p.Tags,
+			CreatedAt:  p.CreatedAt,
+			UpdatedAt:  p.UpdatedAt,
+		})
+	}
+
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"profile.json", profile{
+			ID:        user.ID(),
+			Name:      user.Name(),
+			Email:     user.Email(),
+			Roles:     roles,
+			CreatedAt: user.CreatedAt(),
+			UpdatedAt: user.UpdatedAt(),
+		}},
+		{"products.json", items},
+	}
+
+	m := manifest{UserID: user.ID(), GeneratedAt: time.Now().UTC()}
+	for _, f := range files {
+		m.Files = append(m.Files, f.name)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	if err := writeJSON(zw, "manifest.json", m, m.GeneratedAt); err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if err := writeJSON(zw, f.name, f.content, m.GeneratedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSON(zw *zip.Writer, name string, v any, modified time.Time) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
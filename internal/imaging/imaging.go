@@ -0,0 +1,144 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// MaxPixels bounds the decoded size of an upload, at most 64MB as RGBA. A
+// small file can declare enormous dimensions, so this is checked before
+// decoding.
+const MaxPixels = 16_000_000
+
+var (
+	ErrUnsupportedFormat = errors.New("image must be a JPEG, PNG or GIF")
+	ErrTooManyPixels     = fmt.Errorf("image cannot exceed %d pixels", MaxPixels)
+)
+
+// Info describes an image as sniffed from its bytes; the content type a
+// client declares is never trusted.
+type Info struct {
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+}
+
+var formats = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Inspect identifies the format and reads the dimensions without decoding
+// the pixels.
+func Inspect(data []byte) (Info, error) {
+	contentType := http.DetectContentType(data)
+	extension, ok := formats[contentType]
+	if !ok {
+		return Info{}, ErrUnsupportedFormat
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Info{}, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return Info{}, ErrUnsupportedFormat
+	}
+	if config.Width > MaxPixels/config.Height {
+		return Info{}, ErrTooManyPixels
+	}
+
+	return Info{
+		ContentType: contentType,
+		Extension:   extension,
+		Width:       config.Width,
+		Height:      config.Height,
+	}, nil
+}
+
+// Thumbnail scales the image down to fit a maxSide square, keeping its
+// aspect ratio; smaller images keep their size. Callers run Inspect first, so
+// only images within MaxPixels are decoded. JPEGs stay JPEGs, anything
+// else becomes a PNG so transparency survives. It returns the encoded
+// thumbnail with its content type and extension.
+func Thumbnail(data []byte, maxSide int) ([]byte, Info, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, Info{}, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+
+	thumb := downscale(src, maxSide)
+
+	var buf bytes.Buffer
+	info := Info{Width: thumb.Bounds().Dx(), Height: thumb.Bounds().Dy()}
+	if http.DetectContentType(data) == "image/jpeg" {
+		info.ContentType, info.Extension = "image/jpeg", ".jpg"
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+	} else {
+		info.ContentType, info.Extension = "image/png", ".png"
+		err = png.Encode(&buf, thumb)
+	}
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	return buf.Bytes(), info, nil
+}
+
+// downscale averages the source pixels under each target pixel, which reads
+// far better than nearest-neighbour sampling when shrinking a lot. Averaging
+// happens on premultiplied values, so transparent pixels do not darken their
+// neighbours. Source rows are converted one at a time, so the only full-size
+// image in memory is the decoded source.
+func downscale(src image.Image, maxSide int) *image.RGBA {
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	dw, dh := sw, sh
+	if sw > maxSide || sh > maxSide {
+		if sw >= sh {
+			dw, dh = maxSide, max(1, sh*maxSide/sw)
+		} else {
+			dw, dh = max(1, sw*maxSide/sh), maxSide
+		}
+	}
+
+	row := image.NewRGBA(image.Rect(0, 0, sw, 1))
+	sums := make([]uint64, dw*4)
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		y0, y1 := y*sh/dh, max((y+1)*sh/dh, y*sh/dh+1)
+		clear(sums)
+		for sy := y0; sy < y1; sy++ {
+			draw.Draw(row, row.Bounds(), src, image.Pt(sb.Min.X, sb.Min.Y+sy), draw.Src)
+			for x := range dw {
+				x0, x1 := x*sw/dw, max((x+1)*sw/dw, x*sw/dw+1)
+				for i := x0 * 4; i < x1*4; i += 4 {
+					sums[x*4] += uint64(row.Pix[i])
+					sums[x*4+1] += uint64(row.Pix[i+1])
+					sums[x*4+2] += uint64(row.Pix[i+2])
+					sums[x*4+3] += uint64(row.Pix[i+3])
+				}
+			}
+		}
+
+		for x := range dw {
+			x0, x1 := x*sw/dw, max((x+1)*sw/dw, x*sw/dw+1)
+			n := uint64((y1 - y0) * (x1 - x0))
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(sums[x*4] / n)
+			dst.Pix[i+1] = uint8(sums[x*4+1] / n)
+			dst.Pix[i+2] = uint8(sums[x*4+2] / n)
+			dst.Pix[i+3] = uint8(sums[x*4+3] / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,150 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func solid(w, h int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encode(t *testing.T, format string, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("encode %s: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func TestInspect(t *testing.T) {
+	img := solid(40, 20, color.RGBA{R: 200, A: 255})
+	tests := []struct {
+		format      string
+		contentType string
+		extension   string
+	}{
+		{"png", "image/png", ".png"},
+		{"jpeg", "image/jpeg", ".jpg"},
+		{"gif", "image/gif", ".gif"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			info, err := Inspect(encode(t, tt.format, img))
+			if err != nil {
+				t.Fatalf("Inspect: %v", err)
+			}
+			if info.ContentType != tt.contentType || info.Extension != tt.extension {
+				t.Fatalf("expected %s %s, got %s %s", tt.contentType, tt.extension, info.ContentType, info.Extension)
+			}
+			if info.Width != 40 || info.Height != 20 {
+				t.Fatalf("expected 40x20, got %dx%d", info.Width, info.Height)
+			}
+		})
+	}
+
+	t.Run("other formats are refused", func(t *testing.T) {
+		for _, data := range [][]byte{[]byte("<svg></svg>"), []byte("GIF89a"), {}} {
+			if _, err := Inspect(data); !errors.Is(err, ErrUnsupportedFormat) {
+				t.Fatalf("expected ErrUnsupportedFormat for %q, got %v", data, err)
+			}
+		}
+	})
+
+	t.Run("pixel bombs are refused before decoding", func(t *testing.T) {
+		// A GIF's logical screen size sits unchecked in its header, so a few
+		// bytes can claim 65535x65535 pixels.
+		data := encode(t, "gif", solid(1, 1, color.Black))
+		binary.LittleEndian.PutUint16(data[6:], 65535)
+		binary.LittleEndian.PutUint16(data[8:], 65535)
+		if _, err := Inspect(data); !errors.Is(err, ErrTooManyPixels) {
+			t.Fatalf("expected ErrTooManyPixels, got %v", err)
+		}
+	})
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		name          string
+		format        string
+		width, height int
+		wantW, wantH  int
+		contentType   string
+	}{
+		{"wide", "png", 200, 100, 50, 25, "image/png"},
+		{"tall", "png", 100, 300, 16, 50, "image/png"},
+		{"small images keep their size", "png", 30, 10, 30, 10, "image/png"},
+		{"jpegs stay jpegs", "jpeg", 120, 60, 50, 25, "image/jpeg"},
+		{"gifs become pngs", "gif", 100, 100, 50, 50, "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fill := color.RGBA{R: 40, G: 120, B: 200, A: 255}
+			thumb, info, err := Thumbnail(encode(t, tt.format, solid(tt.width, tt.height, fill)), 50)
+			if err != nil {
+				t.Fatalf("Thumbnail: %v", err)
+			}
+			if info.Width != tt.wantW || info.Height != tt.wantH {
+				t.Fatalf("expected %dx%d, got %dx%d", tt.wantW, tt.wantH, info.Width, info.Height)
+			}
+			if info.ContentType != tt.contentType {
+				t.Fatalf("expected %s, got %s", tt.contentType, info.ContentType)
+			}
+
+			decoded, _, err := image.Decode(bytes.NewReader(thumb))
+			if err != nil {
+				t.Fatalf("decode thumbnail: %v", err)
+			}
+			if b := decoded.Bounds(); b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+				t.Fatalf("expected the encoded thumbnail to be %dx%d, got %dx%d", tt.wantW, tt.wantH, b.Dx(), b.Dy())
+			}
+		})
+	}
+}
+
+func TestDownscaleAverages(t *testing.T) {
+	// Alternating black and white columns average out to grey.
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := range 2 {
+		for x := range 4 {
+			v := uint8(0)
+			if x%2 == 1 {
+				v = 254
+			}
+			src.Set(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+
+	dst := downscale(src, 2)
+	if b := dst.Bounds(); b.Dx() != 2 || b.Dy() != 1 {
+		t.Fatalf("expected 2x1, got %dx%d", b.Dx(), b.Dy())
+	}
+	for x := range 2 {
+		if got := dst.RGBAAt(x, 0); got != (color.RGBA{R: 127, G: 127, B: 127, A: 255}) {
+			t.Fatalf("pixel %d: expected grey, got %v", x, got)
+		}
+	}
+}
@@ -13,10 +13,22 @@ var (
 	HttpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "http_requests_total",
 		Help: "Total number of HTTP requests processed",
-	}, []string{"method", "path", "status"})
+	}, []string{"method", "path", "status", "tenant"})
 	HttpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "http_request_duration_seconds",
 		Help:    "Duration of HTTP requests in seconds",
 		Buckets: prometheus.DefBuckets,
-	}, []string{"method", "path"})
+	}, []string{"method", "path", "tenant"})
+	LoginFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "auth_login_failures_total",
+		Help: "Total number of failed login attempts",
+	})
+	LoginLockoutsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_login_lockouts_total",
+		Help: "Total number of temporary login lockouts",
+	}, []string{"scope"})
+	LoginThrottledTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_login_throttled_total",
+		Help: "Total number of login attempts rejected by backoff or lockout",
+	}, []string{"scope"})
 )
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	FindByID(ctx context.Context, id string) (*domain.APIKey, error)
+	FindAllByUserID(ctx context.Context, userID string) ([]*domain.APIKey, error)
+	Update(ctx context.Context, key *domain.APIKey) error
+	// Touch sets only the key's LastUsedAt, so recording a use never undoes
+	// a concurrent revocation.
+	Touch(ctx context.Context, id string, usedAt time.Time) error
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+// CategoryRepository stores the category tree of the tenant carried by ctx.
+// It keeps no structure beyond each category's ParentID; the service checks
+// that parents exist and that no cycle forms.
+type CategoryRepository interface {
+	Create(ctx context.Context, category *domain.Category) error
+	FindByID(ctx context.Context, id string) (*domain.Category, error)
+	// FindAll returns the whole tree ordered by name, with the ID as
+	// tie-breaker.
+	FindAll(ctx context.Context) ([]*domain.Category, error)
+	Update(ctx context.Context, category *domain.Category) error
+	Delete(ctx context.Context, id string) error
+}
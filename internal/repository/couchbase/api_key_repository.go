@@ -0,0 +1,159 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	cbopentelemetry "github.com/couchbase/gocb-opentelemetry"
+	"github.com/couchbase/gocb/v2"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type couchbaseAPIKeyRepository struct {
+	cluster    *gocb.Cluster
+	bucket     *gocb.Bucket
+	collection *gocb.Collection
+}
+
+type APIKeyDocument struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	SecretHash string     `json:"secret_hash"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	TenantID   string     `json:"tenant_id"`
+	Type       string     `json:"type"`
+}
+
+func NewAPIKeyRepository(conn *Connection) repository.APIKeyRepository {
+	return &couchbaseAPIKeyRepository{
+		cluster:    conn.Cluster,
+		bucket:     conn.Bucket,
+		collection: conn.Bucket.DefaultCollection(),
+	}
+}
+
+func apiKeyKey(ctx context.Context, id string) string {
+	return tenantKey(ctx, "api_key::"+id)
+}
+
+func toAPIKeyDocument(ctx context.Context, key *domain.APIKey) APIKeyDocument {
+	scopes := make([]string, 0, len(key.Scopes))
+	for _, sc := range key.Scopes {
+		scopes = append(scopes, string(sc))
+	}
+
+	return APIKeyDocument{
+		ID:         key.ID,
+		UserID:     key.UserID,
+		Name:       key.Name,
+		SecretHash: key.SecretHash,
+		Scopes:     scopes,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		TenantID:   tenant.FromContext(ctx),
+		Type:       "api_key",
+	}
+}
+
+func fromAPIKeyDocument(doc APIKeyDocument) *domain.APIKey {
+	scopes := make([]domain.Scope, 0, len(doc.Scopes))
+	for _, sc := range doc.Scopes {
+		scopes = append(scopes, domain.Scope(sc))
+	}
+
+	return &domain.APIKey{
+		ID:         doc.ID,
+		UserID:     doc.UserID,
+		Name:       doc.Name,
+		SecretHash: doc.SecretHash,
+		Scopes:     scopes,
+		CreatedAt:  doc.CreatedAt,
+		LastUsedAt: doc.LastUsedAt,
+		RevokedAt:  doc.RevokedAt,
+	}
+}
+
+func (r *couchbaseAPIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	_, err := r.collection.Insert(apiKeyKey(ctx, key.ID), toAPIKeyDocument(ctx, key), &gocb.InsertOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	return err
+}
+
+func (r *couchbaseAPIKeyRepository) FindByID(ctx context.Context, id string) (*domain.APIKey, error) {
+	result, err := r.collection.Get(apiKeyKey(ctx, id), &gocb.GetOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil, domain.ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+
+	var doc APIKeyDocument
+	err = result.Content(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromAPIKeyDocument(doc), nil
+}
+
+func (r *couchbaseAPIKeyRepository) FindAllByUserID(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	query := fmt.Sprintf("SELECT x.* FROM `%s` x WHERE x.type = 'api_key' AND x.user_id = $1 AND %s = $2 ORDER BY x.created_at", r.bucket.Name(), tenantExpr)
+	rows, err := r.cluster.Query(query, &gocb.QueryOptions{
+		PositionalParameters: []any{userID, tenant.FromContext(ctx)},
+		Context:              ctx,
+		ParentSpan:           cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*domain.APIKey
+	for rows.Next() {
+		var doc APIKeyDocument
+		if err := rows.Row(&doc); err != nil {
+			return nil, err
+		}
+		keys = append(keys, fromAPIKeyDocument(doc))
+	}
+	return keys, nil
+}
+
+func (r *couchbaseAPIKeyRepository) Update(ctx context.Context, key *domain.APIKey) error {
+	_, err := r.collection.Replace(apiKeyKey(ctx, key.ID), toAPIKeyDocument(ctx, key), &gocb.ReplaceOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return domain.ErrAPIKeyNotFound
+	}
+	return err
+}
+
+func (r *couchbaseAPIKeyRepository) Touch(ctx context.Context, id string, usedAt time.Time) error {
+	_, err := r.collection.MutateIn(apiKeyKey(ctx, id), []gocb.MutateInSpec{
+		gocb.UpsertSpec("last_used_at", usedAt, nil),
+	}, &gocb.MutateInOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return domain.ErrAPIKeyNotFound
+	}
+	return err
+}
@@ -0,0 +1,145 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	cbopentelemetry "github.com/couchbase/gocb-opentelemetry"
+	"github.com/couchbase/gocb/v2"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type couchbaseCategoryRepository struct {
+	cluster    *gocb.Cluster
+	bucket     *gocb.Bucket
+	collection *gocb.Collection
+}
+
+type CategoryDocument struct {
+	ID        string    `json:"id"`
+	ParentID  string    `json:"parent_id,omitempty"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	TenantID  string    `json:"tenant_id"`
+	Type      string    `json:"type"`
+}
+
+func NewCategoryRepository(conn *Connection) repository.CategoryRepository {
+	return &couchbaseCategoryRepository{
+		cluster:    conn.Cluster,
+		bucket:     conn.Bucket,
+		collection: conn.Bucket.DefaultCollection(),
+	}
+}
+
+func categoryKey(ctx context.Context, id string) string {
+	return tenantKey(ctx, "category::"+id)
+}
+
+func toCategoryDocument(ctx context.Context, category *domain.Category) CategoryDocument {
+	return CategoryDocument{
+		ID:        category.ID,
+		ParentID:  category.ParentID,
+		Name:      category.Name,
+		CreatedAt: category.CreatedAt,
+		UpdatedAt: category.UpdatedAt,
+		TenantID:  tenant.FromContext(ctx),
+		Type:      "category",
+	}
+}
+
+func fromCategoryDocument(doc CategoryDocument) *domain.Category {
+	return &domain.Category{
+		ID:        doc.ID,
+		ParentID:  doc.ParentID,
+		Name:      doc.Name,
+		CreatedAt: doc.CreatedAt,
+		UpdatedAt: doc.UpdatedAt,
+	}
+}
+
+func (r *couchbaseCategoryRepository) Create(ctx context.Context, category *domain.Category) error {
+	_, err := r.collection.Insert(categoryKey(ctx, category.ID), toCategoryDocument(ctx, category), &gocb.InsertOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	return err
+}
+
+func (r *couchbaseCategoryRepository) FindByID(ctx context.Context, id string) (*domain.Category, error) {
+	result, err := r.collection.Get(categoryKey(ctx, id), &gocb.GetOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil, domain.ErrCategoryNotFound
+		}
+		return nil, err
+	}
+
+	var doc CategoryDocument
+	err = result.Content(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromCategoryDocument(doc), nil
+}
+
+// FindAll reads at request_plus consistency: the service walks the result
+// to expand subtrees and refuse cycles, so a category written just before
+// must be part of it.
+func (r *couchbaseCategoryRepository) FindAll(ctx context.Context) ([]*domain.Category, error) {
+	query := fmt.Sprintf("SELECT x.* FROM `%s` x WHERE x.type = 'category' AND %s = $1 ORDER BY x.name, x.id", r.bucket.Name(), tenantExpr)
+	rows, err := r.cluster.Query(query, &gocb.QueryOptions{
+		PositionalParameters: []any{tenant.FromContext(ctx)},
+		ScanConsistency:      gocb.QueryScanConsistencyRequestPlus,
+		Context:              ctx,
+		ParentSpan:           cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var categories []*domain.Category
+	for rows.Next() {
+		var doc CategoryDocument
+		if err := rows.Row(&doc); err != nil {
+			return nil, err
+		}
+		categories = append(categories, fromCategoryDocument(doc))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (r *couchbaseCategoryRepository) Update(ctx context.Context, category *domain.Category) error {
+	_, err := r.collection.Replace(categoryKey(ctx, category.ID), toCategoryDocument(ctx, category), &gocb.ReplaceOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return domain.ErrCategoryNotFound
+	}
+	return err
+}
+
+func (r *couchbaseCategoryRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.collection.Remove(categoryKey(ctx, id), &gocb.RemoveOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return domain.ErrCategoryNotFound
+	}
+	return err
+}
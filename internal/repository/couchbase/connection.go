@@ -0,0 +1,43 @@
+package couchbase
+
+import (
+	"time"
+
+	cbopentelemetry "github.com/couchbase/gocb-opentelemetry"
+	"github.com/couchbase/gocb/v2"
+	"github.com/yusirdemir/microservice/pkg/config"
+	"go.opentelemetry.io/otel"
+)
+
+// Connection is the cluster and bucket the repositories share. Open it once
+// with Connect and close it once the repositories are no longer used.
+type Connection struct {
+	Cluster *gocb.Cluster
+	Bucket  *gocb.Bucket
+}
+
+func Connect(cfg config.DatabaseConfig) (*Connection, error) {
+	cluster, err := gocb.Connect(cfg.Host, gocb.ClusterOptions{
+		Authenticator: gocb.PasswordAuthenticator{
+			Username: cfg.Username,
+			Password: cfg.Password,
+		},
+		Tracer: cbopentelemetry.NewOpenTelemetryRequestTracer(otel.GetTracerProvider()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := cluster.Bucket(cfg.Bucket)
+	err = bucket.WaitUntilReady(30*time.Second, nil)
+	if err != nil {
+		_ = cluster.Close(nil)
+		return nil, err
+	}
+
+	return &Connection{Cluster: cluster, Bucket: bucket}, nil
+}
+
+func (c *Connection) Close() error {
+	return c.Cluster.Close(nil)
+}
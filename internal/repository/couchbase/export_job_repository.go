@@ -0,0 +1,188 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	cbopentelemetry "github.com/couchbase/gocb-opentelemetry"
+	"github.com/couchbase/gocb/v2"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type couchbaseExportJobRepository struct {
+	cluster    *gocb.Cluster
+	bucket     *gocb.Bucket
+	collection *gocb.Collection
+}
+
+type ExportJobDocument struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	Size        int        `json:"size"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	TenantID    string     `json:"tenant_id"`
+	Type        string     `json:"type"`
+}
+
+func NewExportJobRepository(conn *Connection) repository.ExportJobRepository {
+	return &couchbaseExportJobRepository{
+		cluster:    conn.Cluster,
+		bucket:     conn.Bucket,
+		collection: conn.Bucket.DefaultCollection(),
+	}
+}
+
+func exportJobKey(ctx context.Context, id string) string {
+	return tenantKey(ctx, "export_job::"+id)
+}
+
+// exportArchiveKey holds the raw ZIP bytes. Couchbase caps documents at
+// 20 MiB, which bounds the size of a single export.
+func exportArchiveKey(ctx context.Context, id string) string {
+	return tenantKey(ctx, "export_archive::"+id)
+}
+
+// exportPendingKey marks that a user has a pending job, so a second one is
+// refused by the insert. It expires with the job in case the job never
+// finishes.
+func exportPendingKey(ctx context.Context, userID string) string {
+	return tenantKey(ctx, "export_pending::"+userID)
+}
+
+func toExportJobDocument(ctx context.Context, job *domain.ExportJob) ExportJobDocument {
+	return ExportJobDocument{
+		ID:          job.ID,
+		UserID:      job.UserID,
+		Status:      string(job.Status),
+		Error:       job.Error,
+		Size:        job.Size,
+		CreatedAt:   job.CreatedAt,
+		CompletedAt: job.CompletedAt,
+		ExpiresAt:   job.ExpiresAt,
+		TenantID:    tenant.FromContext(ctx),
+		Type:        "export_job",
+	}
+}
+
+func (r *couchbaseExportJobRepository) Create(ctx context.Context, job *domain.ExportJob) error {
+	if job.IsPending() {
+		_, err := r.collection.Insert(exportPendingKey(ctx, job.UserID), job.ID, &gocb.InsertOptions{
+			Expiry:     time.Until(job.ExpiresAt),
+			Context:    ctx,
+			ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+		})
+		if errors.Is(err, gocb.ErrDocumentExists) {
+			return domain.ErrExportPending
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := r.collection.Insert(exportJobKey(ctx, job.ID), toExportJobDocument(ctx, job), &gocb.InsertOptions{
+		Expiry:     time.Until(job.ExpiresAt),
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil && job.IsPending() {
+		_ = r.releasePending(ctx, job.UserID)
+	}
+	return err
+}
+
+func (r *couchbaseExportJobRepository) FindByID(ctx context.Context, id string) (*domain.ExportJob, error) {
+	result, err := r.collection.Get(exportJobKey(ctx, id), &gocb.GetOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil, domain.ErrExportNotFound
+		}
+		return nil, err
+	}
+
+	var doc ExportJobDocument
+	if err := result.Content(&doc); err != nil {
+		return nil, err
+	}
+
+	return &domain.ExportJob{
+		ID:          doc.ID,
+		UserID:      doc.UserID,
+		Status:      domain.ExportStatus(doc.Status),
+		Error:       doc.Error,
+		Size:        doc.Size,
+		CreatedAt:   doc.CreatedAt,
+		CompletedAt: doc.CompletedAt,
+		ExpiresAt:   doc.ExpiresAt,
+	}, nil
+}
+
+func (r *couchbaseExportJobRepository) Update(ctx context.Context, job *domain.ExportJob) error {
+	_, err := r.collection.Replace(exportJobKey(ctx, job.ID), toExportJobDocument(ctx, job), &gocb.ReplaceOptions{
+		Expiry:     time.Until(job.ExpiresAt),
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return domain.ErrExportNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if !job.IsPending() {
+		return r.releasePending(ctx, job.UserID)
+	}
+	return nil
+}
+
+func (r *couchbaseExportJobRepository) releasePending(ctx context.Context, userID string) error {
+	_, err := r.collection.Remove(exportPendingKey(ctx, userID), &gocb.RemoveOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil && !errors.Is(err, gocb.ErrDocumentNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (r *couchbaseExportJobRepository) SaveArchive(ctx context.Context, job *domain.ExportJob, archive []byte) error {
+	_, err := r.collection.Upsert(exportArchiveKey(ctx, job.ID), archive, &gocb.UpsertOptions{
+		Transcoder: gocb.NewRawBinaryTranscoder(),
+		Expiry:     time.Until(job.ExpiresAt),
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	return err
+}
+
+func (r *couchbaseExportJobRepository) FindArchive(ctx context.Context, id string) ([]byte, error) {
+	result, err := r.collection.Get(exportArchiveKey(ctx, id), &gocb.GetOptions{
+		Transcoder: gocb.NewRawBinaryTranscoder(),
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil, domain.ErrExportNotFound
+		}
+		return nil, err
+	}
+
+	var archive []byte
+	if err := result.Content(&archive); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
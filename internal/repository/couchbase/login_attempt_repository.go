@@ -0,0 +1,201 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	cbopentelemetry "github.com/couchbase/gocb-opentelemetry"
+	"github.com/couchbase/gocb/v2"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// maxCasRetries bounds the optimistic retry loops in Reserve and Refund.
+// Contention on a single key only comes from concurrent logins against it.
+const maxCasRetries = 10
+
+type couchbaseLoginAttemptRepository struct {
+	cluster    *gocb.Cluster
+	bucket     *gocb.Bucket
+	collection *gocb.Collection
+}
+
+type LoginAttemptsDocument struct {
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+	LockedUntil time.Time `json:"locked_until"`
+	Type        string    `json:"type"`
+}
+
+func NewLoginAttemptRepository(conn *Connection) repository.LoginAttemptRepository {
+	return &couchbaseLoginAttemptRepository{
+		cluster:    conn.Cluster,
+		bucket:     conn.Bucket,
+		collection: conn.Bucket.DefaultCollection(),
+	}
+}
+
+func loginAttemptsKey(key string) string {
+	return "login_attempts::" + key
+}
+
+func toLoginAttemptsDocument(attempts *domain.LoginAttempts) LoginAttemptsDocument {
+	return LoginAttemptsDocument{
+		Key:         attempts.Key,
+		Failures:    attempts.Failures,
+		LastFailure: attempts.LastFailure,
+		LockedUntil: attempts.LockedUntil,
+		Type:        "login_attempts",
+	}
+}
+
+func fromLoginAttemptsDocument(doc LoginAttemptsDocument) *domain.LoginAttempts {
+	return &domain.LoginAttempts{
+		Key:         doc.Key,
+		Failures:    doc.Failures,
+		LastFailure: doc.LastFailure,
+		LockedUntil: doc.LockedUntil,
+	}
+}
+
+func (r *couchbaseLoginAttemptRepository) Find(ctx context.Context, key string) (*domain.LoginAttempts, error) {
+	result, err := r.collection.Get(loginAttemptsKey(key), &gocb.GetOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil, domain.ErrAttemptsNotFound
+		}
+		return nil, err
+	}
+
+	var doc LoginAttemptsDocument
+	if err := result.Content(&doc); err != nil {
+		return nil, err
+	}
+
+	return fromLoginAttemptsDocument(doc), nil
+}
+
+// Reserve is a CAS-guarded read-modify-write, so concurrent attempts on
+// different replicas are never lost. The document expiry forgets keys that
+// stay quiet for the policy's retention period.
+func (r *couchbaseLoginAttemptRepository) Reserve(ctx context.Context, key string, policy domain.LockoutPolicy, now time.Time) (*domain.LoginAttempts, bool, error) {
+	docKey := loginAttemptsKey(key)
+
+	for range maxCasRetries {
+		result, err := r.collection.Get(docKey, &gocb.GetOptions{
+			Context:    ctx,
+			ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+		})
+
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			attempts := domain.NewLoginAttempts(key)
+			locked, err := attempts.Reserve(now, policy)
+			if err != nil {
+				return nil, false, err
+			}
+
+			_, err = r.collection.Insert(docKey, toLoginAttemptsDocument(attempts), &gocb.InsertOptions{
+				Expiry:     policy.Retention(),
+				Context:    ctx,
+				ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+			})
+			if errors.Is(err, gocb.ErrDocumentExists) {
+				continue
+			}
+			if err != nil {
+				return nil, false, err
+			}
+			return attempts, locked, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		var doc LoginAttemptsDocument
+		if err := result.Content(&doc); err != nil {
+			return nil, false, err
+		}
+
+		attempts := fromLoginAttemptsDocument(doc)
+		locked, err := attempts.Reserve(now, policy)
+		if err != nil {
+			return nil, false, err
+		}
+
+		_, err = r.collection.Replace(docKey, toLoginAttemptsDocument(attempts), &gocb.ReplaceOptions{
+			Cas:        result.Cas(),
+			Expiry:     policy.Retention(),
+			Context:    ctx,
+			ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+		})
+		if errors.Is(err, gocb.ErrCasMismatch) || errors.Is(err, gocb.ErrDocumentNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return attempts, locked, nil
+	}
+
+	return nil, false, errors.New("login attempts update kept conflicting")
+}
+
+// Refund keeps the document's expiry, which still runs from the last counted
+// attempt.
+func (r *couchbaseLoginAttemptRepository) Refund(ctx context.Context, key string, policy domain.LockoutPolicy) error {
+	docKey := loginAttemptsKey(key)
+
+	for range maxCasRetries {
+		result, err := r.collection.Get(docKey, &gocb.GetOptions{
+			Context:    ctx,
+			ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+		})
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var doc LoginAttemptsDocument
+		if err := result.Content(&doc); err != nil {
+			return err
+		}
+
+		attempts := fromLoginAttemptsDocument(doc)
+		attempts.Refund(policy)
+
+		_, err = r.collection.Replace(docKey, toLoginAttemptsDocument(attempts), &gocb.ReplaceOptions{
+			Cas:            result.Cas(),
+			PreserveExpiry: true,
+			Context:        ctx,
+			ParentSpan:     cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+		})
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil
+		}
+		if errors.Is(err, gocb.ErrCasMismatch) {
+			continue
+		}
+		return err
+	}
+
+	return errors.New("login attempts update kept conflicting")
+}
+
+func (r *couchbaseLoginAttemptRepository) Delete(ctx context.Context, key string) error {
+	_, err := r.collection.Remove(loginAttemptsKey(key), &gocb.RemoveOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil && !errors.Is(err, gocb.ErrDocumentNotFound) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	cbopentelemetry "github.com/couchbase/gocb-opentelemetry"
+	"github.com/couchbase/gocb/v2"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type couchbaseOneTimeTokenRepository struct {
+	cluster    *gocb.Cluster
+	bucket     *gocb.Bucket
+	collection *gocb.Collection
+}
+
+type OneTimeTokenDocument struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"token_hash"`
+	Email     string     `json:"email"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	TenantID  string     `json:"tenant_id"`
+	Type      string     `json:"type"`
+}
+
+func NewOneTimeTokenRepository(conn *Connection) repository.OneTimeTokenRepository {
+	return &couchbaseOneTimeTokenRepository{
+		cluster:    conn.Cluster,
+		bucket:     conn.Bucket,
+		collection: conn.Bucket.DefaultCollection(),
+	}
+}
+
+func oneTimeTokenKey(ctx context.Context, id string) string {
+	return tenantKey(ctx, "token::"+id)
+}
+
+func toOneTimeTokenDocument(ctx context.Context, token *domain.OneTimeToken) OneTimeTokenDocument {
+	return OneTimeTokenDocument{
+		ID:        token.ID,
+		UserID:    token.UserID,
+		Purpose:   string(token.Purpose),
+		TokenHash: token.TokenHash,
+		Email:     token.Email,
+		ExpiresAt: token.ExpiresAt,
+		UsedAt:    token.UsedAt,
+		CreatedAt: token.CreatedAt,
+		TenantID:  tenant.FromContext(ctx),
+		Type:      "one_time_token",
+	}
+}
+
+func (r *couchbaseOneTimeTokenRepository) Create(ctx context.Context, token *domain.OneTimeToken) error {
+	_, err := r.collection.Insert(oneTimeTokenKey(ctx, token.ID), toOneTimeTokenDocument(ctx, token), &gocb.InsertOptions{
+		Expiry:     time.Until(token.ExpiresAt),
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	return err
+}
+
+func (r *couchbaseOneTimeTokenRepository) FindByID(ctx context.Context, id string) (*domain.OneTimeToken, error) {
+	result, err := r.collection.Get(oneTimeTokenKey(ctx, id), &gocb.GetOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil, domain.ErrTokenNotFound
+		}
+		return nil, err
+	}
+
+	var doc OneTimeTokenDocument
+	err = result.Content(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.OneTimeToken{
+		ID:        doc.ID,
+		UserID:    doc.UserID,
+		Purpose:   domain.TokenPurpose(doc.Purpose),
+		TokenHash: doc.TokenHash,
+		Email:     doc.Email,
+		ExpiresAt: doc.ExpiresAt,
+		UsedAt:    doc.UsedAt,
+		CreatedAt: doc.CreatedAt,
+	}, nil
+}
+
+// MarkUsed replaces the document under the CAS it was read with. Tokens are
+// never written after their creation except here, so a CAS mismatch means a
+// concurrent redemption won.
+func (r *couchbaseOneTimeTokenRepository) MarkUsed(ctx context.Context, id string, usedAt time.Time) error {
+	key := oneTimeTokenKey(ctx, id)
+	result, err := r.collection.Get(key, &gocb.GetOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return domain.ErrTokenNotFound
+		}
+		return err
+	}
+
+	var doc OneTimeTokenDocument
+	if err := result.Content(&doc); err != nil {
+		return err
+	}
+	if doc.UsedAt != nil {
+		return domain.ErrTokenUsed
+	}
+	doc.UsedAt = &usedAt
+
+	_, err = r.collection.Replace(key, doc, &gocb.ReplaceOptions{
+		Cas:        result.Cas(),
+		Expiry:     time.Until(doc.ExpiresAt),
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gocb.ErrCasMismatch):
+			return domain.ErrTokenUsed
+		case errors.Is(err, gocb.ErrDocumentNotFound):
+			return domain.ErrTokenNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *couchbaseOneTimeTokenRepository) DeleteAllByUserID(ctx context.Context, userID string, purpose domain.TokenPurpose) error {
+	query := fmt.Sprintf("DELETE FROM `%s` x WHERE x.type = 'one_time_token' AND x.user_id = $1 AND x.purpose = $2 AND %s = $3", r.bucket.Name(), tenantExpr)
+	rows, err := r.cluster.Query(query, &gocb.QueryOptions{
+		PositionalParameters: []any{userID, string(purpose), tenant.FromContext(ctx)},
+		ScanConsistency:      gocb.QueryScanConsistencyRequestPlus,
+		Context:              ctx,
+		ParentSpan:           cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
@@ -0,0 +1,251 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	cbopentelemetry "github.com/couchbase/gocb-opentelemetry"
+	"github.com/couchbase/gocb/v2"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type couchbaseProductImageRepository struct {
+	cluster    *gocb.Cluster
+	bucket     *gocb.Bucket
+	collection *gocb.Collection
+}
+
+type ProductImageDocument struct {
+	ID           string    `json:"id"`
+	ProductID    string    `json:"product_id"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Key          string    `json:"key"`
+	ThumbnailKey string    `json:"thumbnail_key"`
+	CreatedAt    time.Time `json:"created_at"`
+	TenantID     string    `json:"tenant_id"`
+	Type         string    `json:"type"`
+}
+
+// ProductImageSetDocument lists a product's image IDs. Create and Delete
+// change it in the same transaction as the image itself, which is what keeps
+// concurrent uploads within the per-product limit.
+type ProductImageSetDocument struct {
+	ProductID string   `json:"product_id"`
+	ImageIDs  []string `json:"image_ids"`
+	TenantID  string   `json:"tenant_id"`
+	Type      string   `json:"type"`
+}
+
+func NewProductImageRepository(conn *Connection) repository.ProductImageRepository {
+	return &couchbaseProductImageRepository{
+		cluster:    conn.Cluster,
+		bucket:     conn.Bucket,
+		collection: conn.Bucket.DefaultCollection(),
+	}
+}
+
+func productImageKey(ctx context.Context, id string) string {
+	return tenantKey(ctx, "product_image::"+id)
+}
+
+func productImageSetKey(ctx context.Context, productID string) string {
+	return tenantKey(ctx, "product_images::"+productID)
+}
+
+func toProductImageDocument(ctx context.Context, image *domain.ProductImage) ProductImageDocument {
+	return ProductImageDocument{
+		ID:           image.ID,
+		ProductID:    image.ProductID,
+		ContentType:  image.ContentType,
+		Size:         image.Size,
+		Width:        image.Width,
+		Height:       image.Height,
+		Key:          image.Key,
+		ThumbnailKey: image.ThumbnailKey,
+		CreatedAt:    image.CreatedAt,
+		TenantID:     tenant.FromContext(ctx),
+		Type:         "product_image",
+	}
+}
+
+func fromProductImageDocument(doc ProductImageDocument) *domain.ProductImage {
+	return &domain.ProductImage{
+		ID:           doc.ID,
+		ProductID:    doc.ProductID,
+		ContentType:  doc.ContentType,
+		Size:         doc.Size,
+		Width:        doc.Width,
+		Height:       doc.Height,
+		Key:          doc.Key,
+		ThumbnailKey: doc.ThumbnailKey,
+		CreatedAt:    doc.CreatedAt,
+	}
+}
+
+func (r *couchbaseProductImageRepository) Create(ctx context.Context, image *domain.ProductImage, limit int) error {
+	_, err := r.cluster.Transactions().Run(func(tac *gocb.TransactionAttemptContext) error {
+		set := ProductImageSetDocument{
+			ProductID: image.ProductID,
+			TenantID:  tenant.FromContext(ctx),
+			Type:      "product_image_set",
+		}
+		current, err := tac.Get(r.collection, productImageSetKey(ctx, image.ProductID))
+		switch {
+		case errors.Is(err, gocb.ErrDocumentNotFound):
+			current = nil
+		case err != nil:
+			return err
+		default:
+			if err := current.Content(&set); err != nil {
+				return err
+			}
+		}
+
+		if len(set.ImageIDs) >= limit {
+			return domain.ErrImageLimitReached
+		}
+		set.ImageIDs = append(set.ImageIDs, image.ID)
+
+		if current == nil {
+			_, err = tac.Insert(r.collection, productImageSetKey(ctx, image.ProductID), set)
+		} else {
+			_, err = tac.Replace(current, set)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tac.Insert(r.collection, productImageKey(ctx, image.ID), toProductImageDocument(ctx, image))
+		return err
+	}, nil)
+	return transactionError(err)
+}
+
+func (r *couchbaseProductImageRepository) FindByID(ctx context.Context, id string) (*domain.ProductImage, error) {
+	result, err := r.collection.Get(productImageKey(ctx, id), &gocb.GetOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil, domain.ErrImageNotFound
+		}
+		return nil, err
+	}
+
+	var doc ProductImageDocument
+	err = result.Content(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromProductImageDocument(doc), nil
+}
+
+// FindAllByProductID reads at request_plus consistency, since the service
+// counts the result against the per-product limit right after uploads.
+func (r *couchbaseProductImageRepository) FindAllByProductID(ctx context.Context, productID string) ([]*domain.ProductImage, error) {
+	query := fmt.Sprintf("SELECT x.* FROM `%s` x WHERE x.type = 'product_image' AND x.product_id = $1 AND %s = $2 ORDER BY x.created_at, x.id", r.bucket.Name(), tenantExpr)
+	rows, err := r.cluster.Query(query, &gocb.QueryOptions{
+		PositionalParameters: []any{productID, tenant.FromContext(ctx)},
+		ScanConsistency:      gocb.QueryScanConsistencyRequestPlus,
+		Context:              ctx,
+		ParentSpan:           cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var images []*domain.ProductImage
+	for rows.Next() {
+		var doc ProductImageDocument
+		if err := rows.Row(&doc); err != nil {
+			return nil, err
+		}
+		images = append(images, fromProductImageDocument(doc))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (r *couchbaseProductImageRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.cluster.Transactions().Run(func(tac *gocb.TransactionAttemptContext) error {
+		current, err := tac.Get(r.collection, productImageKey(ctx, id))
+		if err != nil {
+			if errors.Is(err, gocb.ErrDocumentNotFound) {
+				return domain.ErrImageNotFound
+			}
+			return err
+		}
+
+		var doc ProductImageDocument
+		if err := current.Content(&doc); err != nil {
+			return err
+		}
+
+		setResult, err := tac.Get(r.collection, productImageSetKey(ctx, doc.ProductID))
+		switch {
+		case errors.Is(err, gocb.ErrDocumentNotFound):
+		case err != nil:
+			return err
+		default:
+			var set ProductImageSetDocument
+			if err := setResult.Content(&set); err != nil {
+				return err
+			}
+			set.ImageIDs = slices.DeleteFunc(set.ImageIDs, func(imageID string) bool { return imageID == id })
+			if len(set.ImageIDs) == 0 {
+				err = tac.Remove(setResult)
+			} else {
+				_, err = tac.Replace(setResult, set)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return tac.Remove(current)
+	}, nil)
+	return transactionError(err)
+}
+
+// DeleteAllByProductIDs removes the products' image sets in the same
+// statement as their images.
+func (r *couchbaseProductImageRepository) DeleteAllByProductIDs(ctx context.Context, productIDs []string) ([]*domain.ProductImage, error) {
+	query := fmt.Sprintf("DELETE FROM `%s` x WHERE x.type IN ['product_image', 'product_image_set'] AND x.product_id IN $1 AND %s = $2 RETURNING x.*", r.bucket.Name(), tenantExpr)
+	rows, err := r.cluster.Query(query, &gocb.QueryOptions{
+		PositionalParameters: []any{productIDs, tenant.FromContext(ctx)},
+		ScanConsistency:      gocb.QueryScanConsistencyRequestPlus,
+		Context:              ctx,
+		ParentSpan:           cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var images []*domain.ProductImage
+	for rows.Next() {
+		var doc ProductImageDocument
+		if err := rows.Row(&doc); err != nil {
+			return nil, err
+		}
+		if doc.Type == "product_image" {
+			images = append(images, fromProductImageDocument(doc))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
@@ -4,88 +4,150 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	cbopentelemetry "github.com/couchbase/gocb-opentelemetry"
 	"github.com/couchbase/gocb/v2"
 	"github.com/yusirdemir/microservice/internal/domain"
 	"github.com/yusirdemir/microservice/internal/repository"
-	"github.com/yusirdemir/microservice/pkg/config"
-	"go.opentelemetry.io/otel"
+	"github.com/yusirdemir/microservice/internal/tenant"
 	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
+// maxStockRetries bounds the CAS loop in AdjustStock. A popular product sees
+// far more contention than a login key, so it gets a larger budget than
+// maxCasRetries.
+const maxStockRetries = 50
+
+// batchConcurrency bounds the writes WriteBatch keeps in flight, so a large
+// batch cannot flood the cluster or the SDK's connection pool.
+const batchConcurrency = 16
+
 type couchbaseProductRepository struct {
 	cluster    *gocb.Cluster
 	bucket     *gocb.Bucket
 	collection *gocb.Collection
+	// defaultCurrency prices documents written before products had one.
+	defaultCurrency string
 }
 
 type ProductDocument struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Name      string    `json:"name"`
-	Price     int       `json:"price"`
-	Stock     int       `json:"stock"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Type      string    `json:"type"`
-}
-
-func NewProductRepository(cfg *config.Config) (repository.ProductRepository, error) {
-	cluster, err := gocb.Connect(cfg.Database.Host, gocb.ClusterOptions{
-		Authenticator: gocb.PasswordAuthenticator{
-			Username: cfg.Database.Username,
-			Password: cfg.Database.Password,
-		},
-		Tracer: cbopentelemetry.NewOpenTelemetryRequestTracer(otel.GetTracerProvider()),
-	})
-	if err != nil {
-		return nil, err
-	}
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Price      int64      `json:"price"`
+	Currency   string     `json:"currency,omitempty"`
+	Stock      int        `json:"stock"`
+	CategoryID string     `json:"category_id,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	TenantID   string     `json:"tenant_id"`
+	Type       string     `json:"type"`
+}
 
-	bucket := cluster.Bucket(cfg.Database.Bucket)
-	err = bucket.WaitUntilReady(30*time.Second, nil)
+func NewProductRepository(conn *Connection, currency string) (repository.ProductRepository, error) {
+	defaultCurrency, err := domain.NormalizeCurrency(currency)
 	if err != nil {
 		return nil, err
 	}
 
-	collection := bucket.DefaultCollection()
-
 	return &couchbaseProductRepository{
-		cluster:    cluster,
-		bucket:     bucket,
-		collection: collection,
+		cluster:         conn.Cluster,
+		bucket:          conn.Bucket,
+		collection:      conn.Bucket.DefaultCollection(),
+		defaultCurrency: defaultCurrency,
 	}, nil
 }
 
+func toProductDocument(ctx context.Context, product *domain.Product) ProductDocument {
+	return ProductDocument{
+		ID:         product.ID,
+		UserID:     product.UserID,
+		Name:       product.Name,
+		Price:      product.Price.Amount(),
+		Currency:   product.Price.Currency(),
+		Stock:      product.Stock,
+		CategoryID: product.CategoryID,
+		Tags:       product.Tags,
+		CreatedAt:  product.CreatedAt,
+		UpdatedAt:  product.UpdatedAt,
+		DeletedAt:  product.DeletedAt,
+		TenantID:   tenant.FromContext(ctx),
+		Type:       "product",
+	}
+}
+
+// fromProductDocument takes the document CAS as the product version. Rows from
+// N1QL carry no CAS and come back with version zero. Documents without a
+// currency are priced in defaultCurrency.
+func fromProductDocument(doc ProductDocument, cas gocb.Cas, defaultCurrency string) *domain.Product {
+	currency := doc.Currency
+	if currency == "" {
+		currency = defaultCurrency
+	}
+
+	return domain.ReconstituteProduct(
+		doc.ID,
+		doc.UserID,
+		doc.Name,
+		domain.ReconstituteMoney(doc.Price, currency),
+		doc.Stock,
+		doc.CategoryID,
+		doc.Tags,
+		doc.CreatedAt,
+		doc.UpdatedAt,
+		doc.DeletedAt,
+		uint64(cas),
+	)
+}
+
 func (r *couchbaseProductRepository) Create(ctx context.Context, product *domain.Product) error {
-	doc := ProductDocument{
-		ID:        product.ID,
-		UserID:    product.UserID,
-		Name:      product.Name,
-		Price:     product.Price,
-		Stock:     product.Stock,
-		CreatedAt: product.CreatedAt,
-		UpdatedAt: product.UpdatedAt,
-		Type:      "product",
-	}
-
-	_, err := r.collection.Insert(product.ID, doc, &gocb.InsertOptions{
+	result, err := r.collection.Insert(tenantKey(ctx, product.ID), toProductDocument(ctx, product), &gocb.InsertOptions{
 		Context:    ctx,
 		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
 	})
-	return err
+	if err != nil {
+		return err
+	}
+
+	product.Version = uint64(result.Cas())
+	return nil
 }
 
 func (r *couchbaseProductRepository) FindByID(ctx context.Context, id string) (*domain.Product, error) {
-	result, err := r.collection.Get(id, &gocb.GetOptions{
+	product, err := r.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if product.IsDeleted() {
+		return nil, domain.ErrProductNotFound
+	}
+	return product, nil
+}
+
+func (r *couchbaseProductRepository) FindDeletedByID(ctx context.Context, id string) (*domain.Product, error) {
+	product, err := r.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !product.IsDeleted() {
+		return nil, domain.ErrProductNotFound
+	}
+	return product, nil
+}
+
+func (r *couchbaseProductRepository) get(ctx context.Context, id string) (*domain.Product, error) {
+	result, err := r.collection.Get(tenantKey(ctx, id), &gocb.GetOptions{
 		Context:    ctx,
 		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
 	})
 	if err != nil {
 		if errors.Is(err, gocb.ErrDocumentNotFound) {
-			return nil, errors.New("product not found")
+			return nil, domain.ErrProductNotFound
 		}
 		return nil, err
 	}
@@ -96,21 +158,13 @@ func (r *couchbaseProductRepository) FindByID(ctx context.Context, id string) (*
 		return nil, err
 	}
 
-	return domain.ReconstituteProduct(
-		doc.ID,
-		doc.UserID,
-		doc.Name,
-		doc.Price,
-		doc.Stock,
-		doc.CreatedAt,
-		doc.UpdatedAt,
-	), nil
+	return fromProductDocument(doc, result.Cas(), r.defaultCurrency), nil
 }
 
 func (r *couchbaseProductRepository) FindAllByUserID(ctx context.Context, userID string) ([]*domain.Product, error) {
-	query := fmt.Sprintf("SELECT x.* FROM `%s` x WHERE x.type = 'product' AND x.user_id = $1", r.bucket.Name())
+	query := fmt.Sprintf("SELECT x.* FROM `%s` x WHERE x.type = 'product' AND x.user_id = $1 AND x.deleted_at IS MISSING AND %s = $2", r.bucket.Name(), tenantExpr)
 	rows, err := r.cluster.Query(query, &gocb.QueryOptions{
-		PositionalParameters: []any{userID},
+		PositionalParameters: []any{userID, tenant.FromContext(ctx)},
 		Context:              ctx,
 		ParentSpan:           cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
 	})
@@ -124,44 +178,395 @@ func (r *couchbaseProductRepository) FindAllByUserID(ctx context.Context, userID
 		if err := rows.Row(&doc); err != nil {
 			return nil, err
 		}
-		products = append(products, domain.ReconstituteProduct(
-			doc.ID,
-			doc.UserID,
-			doc.Name,
-			doc.Price,
-			doc.Stock,
-			doc.CreatedAt,
-			doc.UpdatedAt,
-		))
+		products = append(products, fromProductDocument(doc, 0, r.defaultCurrency))
 	}
 	return products, nil
 }
 
-func (r *couchbaseProductRepository) Update(ctx context.Context, product *domain.Product) error {
-	product.UpdatedAt = time.Now()
+func (r *couchbaseProductRepository) CountByUserID(ctx context.Context, userID string) (int, error) {
+	query := fmt.Sprintf("SELECT RAW COUNT(*) FROM `%s` x WHERE x.type = 'product' AND x.user_id = $1 AND x.deleted_at IS MISSING AND %s = $2", r.bucket.Name(), tenantExpr)
+	rows, err := r.cluster.Query(query, &gocb.QueryOptions{
+		PositionalParameters: []any{userID, tenant.FromContext(ctx)},
+		Context:              ctx,
+		ParentSpan:           cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	if err := rows.One(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *couchbaseProductRepository) FindPageByUserID(ctx context.Context, userID string, page repository.PageRequest) (*repository.ProductPage, error) {
+	return r.List(ctx, repository.ProductQuery{OwnerID: userID, PageRequest: page})
+}
+
+// productSortExpressions maps each sort to the N1QL expression it orders by.
+// Timestamps compare as epoch millis, matching the cursor values.
+var productSortExpressions = map[repository.ProductSort]string{
+	repository.ProductSortCreatedAt: "STR_TO_MILLIS(x.created_at)",
+	repository.ProductSortPrice:     "x.price",
+	repository.ProductSortName:      "x.name",
+}
+
+// List translates the query into a parameterized N1QL statement and seeks
+// past the cursor instead of using OFFSET, so every page costs the same.
+func (r *couchbaseProductRepository) List(ctx context.Context, query repository.ProductQuery) (*repository.ProductPage, error) {
+	query.Normalize()
+	after, err := repository.DecodeCursor(query.Cursor, string(query.Sort), query.Descending)
+	if err != nil {
+		return nil, err
+	}
+
+	sortExpr := productSortExpressions[query.Sort]
+	conditions := []string{"x.type = 'product'", "x.deleted_at IS MISSING", tenantExpr + " = $tenant"}
+	params := map[string]any{"tenant": tenant.FromContext(ctx)}
+
+	if query.OwnerID != "" {
+		conditions = append(conditions, "x.user_id = $owner")
+		params["owner"] = query.OwnerID
+	}
+	if query.MinPrice != nil {
+		conditions = append(conditions, "x.price >= $min_price")
+		params["min_price"] = *query.MinPrice
+	}
+	if query.MaxPrice != nil {
+		conditions = append(conditions, "x.price <= $max_price")
+		params["max_price"] = *query.MaxPrice
+	}
+	if query.Currency != "" {
+		conditions = append(conditions, "IFMISSINGORNULL(x.currency, $default_currency) = $currency")
+		params["currency"] = query.Currency
+		params["default_currency"] = r.defaultCurrency
+	}
+	if len(query.CategoryIDs) > 0 {
+		conditions = append(conditions, "x.category_id IN $categories")
+		params["categories"] = query.CategoryIDs
+	}
+	if query.Tag != "" {
+		conditions = append(conditions, "ANY t IN x.tags SATISFIES t = $tag END")
+		params["tag"] = query.Tag
+	}
+	if query.InStock {
+		conditions = append(conditions, "x.stock > 0")
+	}
+	if query.CreatedAfter != nil {
+		conditions = append(conditions, "STR_TO_MILLIS(x.created_at) > $created_after")
+		params["created_after"] = query.CreatedAfter.UnixMilli()
+	}
+
+	direction, op := "ASC", ">"
+	if query.Descending {
+		direction, op = "DESC", "<"
+	}
+
+	if after != nil {
+		var value any
+		if query.Sort == repository.ProductSortName {
+			value, err = after.String()
+		} else {
+			value, err = after.Int64()
+		}
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, fmt.Sprintf("(%[1]s %[2]s $after_value OR (%[1]s = $after_value AND x.id %[2]s $after_id))", sortExpr, op))
+		params["after_value"] = value
+		params["after_id"] = after.ID
+	}
+
+	statement := fmt.Sprintf("SELECT x.* FROM `%s` x WHERE %s ORDER BY %s %s, x.id %s LIMIT %d",
+		r.bucket.Name(), strings.Join(conditions, " AND "), sortExpr, direction, direction, query.Limit+1)
+	rows, err := r.cluster.Query(statement, &gocb.QueryOptions{
+		NamedParameters: params,
+		Context:         ctx,
+		ParentSpan:      cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &repository.ProductPage{}
+	for rows.Next() {
+		var doc ProductDocument
+		if err := rows.Row(&doc); err != nil {
+			return nil, err
+		}
+		page.Products = append(page.Products, fromProductDocument(doc, 0, r.defaultCurrency))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	doc := ProductDocument{
-		ID:        product.ID,
-		UserID:    product.UserID,
-		Name:      product.Name,
-		Price:     product.Price,
-		Stock:     product.Stock,
-		CreatedAt: product.CreatedAt,
-		UpdatedAt: product.UpdatedAt,
-		Type:      "product",
+	if len(page.Products) > query.Limit {
+		page.Products = page.Products[:query.Limit]
+		last := page.Products[len(page.Products)-1]
+		var value any
+		switch query.Sort {
+		case repository.ProductSortPrice:
+			value = last.Price.Amount()
+		case repository.ProductSortName:
+			value = last.Name
+		default:
+			value = last.CreatedAt.UnixMilli()
+		}
+		page.NextCursor = repository.EncodeCursor(repository.Cursor{
+			Sort:       string(query.Sort),
+			Descending: query.Descending,
+			Value:      value,
+			ID:         last.ID,
+		})
 	}
 
-	_, err := r.collection.Replace(product.ID, doc, &gocb.ReplaceOptions{
+	return page, nil
+}
+
+// Update replaces the document only if its CAS still equals the product
+// version, so a write based on a stale read fails instead of winning.
+func (r *couchbaseProductRepository) Update(ctx context.Context, product *domain.Product) error {
+	product.UpdatedAt = time.Now()
+
+	result, err := r.collection.Replace(tenantKey(ctx, product.ID), toProductDocument(ctx, product), &gocb.ReplaceOptions{
+		Cas:        gocb.Cas(product.Version),
 		Context:    ctx,
 		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
 	})
-	return err
+	if err != nil {
+		switch {
+		case errors.Is(err, gocb.ErrCasMismatch):
+			return domain.ErrVersionMismatch
+		case errors.Is(err, gocb.ErrDocumentNotFound):
+			return domain.ErrProductNotFound
+		}
+		return err
+	}
+
+	product.Version = uint64(result.Cas())
+	return nil
+}
+
+// WriteBatch issues the writes as individual KV operations, at most
+// batchConcurrency at a time.
+func (r *couchbaseProductRepository) WriteBatch(ctx context.Context, writes []repository.ProductWrite) []error {
+	errs := make([]error, len(writes))
+	slots := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for i, w := range writes {
+		slots <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-slots }()
+			if w.Create {
+				errs[i] = r.Create(ctx, w.Product)
+			} else {
+				errs[i] = r.Update(ctx, w.Product)
+			}
+		})
+	}
+	wg.Wait()
+	return errs
+}
+
+// WriteBatchAtomic runs the batch as one transaction. Transactional reads do
+// not expose the CAS, so each replaced document's CAS is read alongside: it
+// must still be the version its product was read with, and from then on the
+// transaction's own replace is guarded by what it read. Once committed, the
+// written products are read again for their new versions.
+func (r *couchbaseProductRepository) WriteBatchAtomic(ctx context.Context, writes []repository.ProductWrite) error {
+	failed := -1
+	_, err := r.cluster.Transactions().Run(func(tac *gocb.TransactionAttemptContext) error {
+		for i, w := range writes {
+			failed = i
+			key := tenantKey(ctx, w.Product.ID)
+			if w.Create {
+				if _, err := tac.Insert(r.collection, key, toProductDocument(ctx, w.Product)); err != nil {
+					if errors.Is(err, gocb.ErrDocumentExists) {
+						return errors.New("product already exists")
+					}
+					return err
+				}
+				continue
+			}
+
+			current, err := tac.Get(r.collection, key)
+			if err != nil {
+				if errors.Is(err, gocb.ErrDocumentNotFound) {
+					return domain.ErrProductNotFound
+				}
+				return err
+			}
+			meta, err := r.collection.Exists(key, &gocb.ExistsOptions{
+				Context:    ctx,
+				ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+			})
+			if err != nil {
+				return err
+			}
+			if !meta.Exists() {
+				return domain.ErrProductNotFound
+			}
+			if uint64(meta.Cas()) != w.Product.Version {
+				return domain.ErrVersionMismatch
+			}
+
+			w.Product.UpdatedAt = time.Now()
+			if _, err := tac.Replace(current, toProductDocument(ctx, w.Product)); err != nil {
+				return err
+			}
+		}
+		failed = -1
+		return nil
+	}, nil)
+	if err != nil {
+		err = transactionError(err)
+		if failed >= 0 {
+			return &repository.BatchWriteError{Index: failed, Err: err}
+		}
+		return err
+	}
+
+	// The batch is committed, so a failed read does not fail it: that
+	// product keeps version zero, which no document has, and its next update
+	// asks the client to read it again.
+	for _, w := range writes {
+		w.Product.Version = 0
+		result, err := r.collection.Get(tenantKey(ctx, w.Product.ID), &gocb.GetOptions{
+			Context:    ctx,
+			ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+		})
+		if err != nil {
+			continue
+		}
+		var doc ProductDocument
+		if err := result.Content(&doc); err != nil {
+			continue
+		}
+		*w.Product = *fromProductDocument(doc, result.Cas(), r.defaultCurrency)
+	}
+	return nil
+}
+
+// AdjustStock is a CAS-guarded read-modify-write: a replace that lost a race
+// re-reads the document and checks the stock again, so concurrent
+// adjustments are neither lost nor able to oversell.
+func (r *couchbaseProductRepository) AdjustStock(ctx context.Context, id string, delta int) (*domain.Product, error) {
+	key := tenantKey(ctx, id)
+
+	for range maxStockRetries {
+		result, err := r.collection.Get(key, &gocb.GetOptions{
+			Context:    ctx,
+			ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+		})
+		if err != nil {
+			if errors.Is(err, gocb.ErrDocumentNotFound) {
+				return nil, domain.ErrProductNotFound
+			}
+			return nil, err
+		}
+
+		var doc ProductDocument
+		if err := result.Content(&doc); err != nil {
+			return nil, err
+		}
+
+		product := fromProductDocument(doc, result.Cas(), r.defaultCurrency)
+		if product.IsDeleted() {
+			return nil, domain.ErrProductNotFound
+		}
+		if err := product.AdjustStock(delta, time.Now()); err != nil {
+			return nil, err
+		}
+
+		replaced, err := r.collection.Replace(key, toProductDocument(ctx, product), &gocb.ReplaceOptions{
+			Cas:        result.Cas(),
+			Context:    ctx,
+			ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+		})
+		if errors.Is(err, gocb.ErrCasMismatch) {
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, gocb.ErrDocumentNotFound) {
+				return nil, domain.ErrProductNotFound
+			}
+			return nil, err
+		}
+
+		product.Version = uint64(replaced.Cas())
+		return product, nil
+	}
+
+	return nil, errors.New("stock adjustment kept conflicting")
 }
 
 func (r *couchbaseProductRepository) Delete(ctx context.Context, id string) error {
-	_, err := r.collection.Remove(id, &gocb.RemoveOptions{
+	_, err := r.collection.Remove(tenantKey(ctx, id), &gocb.RemoveOptions{
 		Context:    ctx,
 		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
 	})
 	return err
 }
+
+func (r *couchbaseProductRepository) SoftDeleteAllByUserID(ctx context.Context, userID string, deletedAt time.Time) error {
+	query := fmt.Sprintf("UPDATE `%s` x SET x.deleted_at = $2, x.updated_at = $2 WHERE x.type = 'product' AND x.user_id = $1 AND x.deleted_at IS MISSING AND %s = $3", r.bucket.Name(), tenantExpr)
+	return r.exec(ctx, query, userID, deletedAt)
+}
+
+func (r *couchbaseProductRepository) RestoreAllByUserID(ctx context.Context, userID string, deletedAt time.Time) error {
+	query := fmt.Sprintf("UPDATE `%s` x SET x.updated_at = $3 UNSET x.deleted_at WHERE x.type = 'product' AND x.user_id = $1 AND STR_TO_MILLIS(x.deleted_at) = $2 AND %s = $4", r.bucket.Name(), tenantExpr)
+	return r.exec(ctx, query, userID, deletedAt.UnixMilli(), time.Now())
+}
+
+func (r *couchbaseProductRepository) DeleteAllByUserID(ctx context.Context, userID string) ([]string, error) {
+	query := fmt.Sprintf("DELETE FROM `%s` x WHERE x.type = 'product' AND x.user_id = $1 AND %s = $2 RETURNING RAW x.id", r.bucket.Name(), tenantExpr)
+	return r.execReturningIDs(ctx, query, userID)
+}
+
+func (r *couchbaseProductRepository) DeleteAllDeletedBefore(ctx context.Context, cutoff time.Time) ([]string, error) {
+	query := fmt.Sprintf("DELETE FROM `%s` x WHERE x.type = 'product' AND x.deleted_at IS VALUED AND STR_TO_MILLIS(x.deleted_at) < $1 AND %s = $2 RETURNING RAW x.id", r.bucket.Name(), tenantExpr)
+	return r.execReturningIDs(ctx, query, cutoff.UnixMilli())
+}
+
+// exec runs a bulk N1QL statement, binding the tenant in ctx as the last
+// positional parameter; every statement filters tenantExpr against it.
+func (r *couchbaseProductRepository) exec(ctx context.Context, query string, args ...any) error {
+	rows, err := r.cluster.Query(query, &gocb.QueryOptions{
+		PositionalParameters: append(args, tenant.FromContext(ctx)),
+		ScanConsistency:      gocb.QueryScanConsistencyRequestPlus,
+		Context:              ctx,
+		ParentSpan:           cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// execReturningIDs is exec for statements ending in RETURNING RAW x.id.
+func (r *couchbaseProductRepository) execReturningIDs(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := r.cluster.Query(query, &gocb.QueryOptions{
+		PositionalParameters: append(args, tenant.FromContext(ctx)),
+		ScanConsistency:      gocb.QueryScanConsistencyRequestPlus,
+		Context:              ctx,
+		ParentSpan:           cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Row(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
@@ -0,0 +1,132 @@
+package couchbase
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	cbopentelemetry "github.com/couchbase/gocb-opentelemetry"
+	"github.com/couchbase/gocb/v2"
+	"github.com/couchbase/gocb/v2/search"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type couchbaseSearchIndex struct {
+	cluster *gocb.Cluster
+	index   string
+}
+
+// NewSearchIndex queries the Full Text Search index with the given name. The
+// index should map documents of type "product" with name as a text field
+// (standard analyzer) and tenant_id as a keyword field. The server keeps it
+// current from document mutations, so Index and Remove have nothing to do.
+func NewSearchIndex(conn *Connection, index string) repository.SearchIndex {
+	return &couchbaseSearchIndex{
+		cluster: conn.Cluster,
+		index:   index,
+	}
+}
+
+func (s *couchbaseSearchIndex) Index(ctx context.Context, product *domain.Product) error {
+	return nil
+}
+
+func (s *couchbaseSearchIndex) Remove(ctx context.Context, id string) error {
+	return nil
+}
+
+// Search matches every query word against product names both as a fuzzy
+// term and as a prefix, and lets FTS rank the union.
+func (s *couchbaseSearchIndex) Search(ctx context.Context, query string, limit int) ([]repository.SearchHit, error) {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	var should []search.Query
+	for _, word := range words {
+		should = append(should,
+			search.NewMatchQuery(word).Field("name").Fuzziness(fuzziness(word)),
+			search.NewPrefixQuery(word).Field("name").Boost(0.8),
+		)
+	}
+	var q search.Query = search.NewDisjunctionQuery(should...)
+
+	// Documents written before tenancy carry no tenant_id, so the default
+	// tenant relies on collectHits dropping other tenants' keys.
+	if id := tenant.FromContext(ctx); id != tenant.Default {
+		q = search.NewConjunctionQuery(q, search.NewTermQuery(id).Field("tenant_id"))
+	}
+
+	return collectHits(ctx, limit, func(skip, limit int) ([]searchRow, error) {
+		result, err := s.cluster.SearchQuery(s.index, q, &gocb.SearchOptions{
+			Skip:       uint32(skip),
+			Limit:      uint32(limit),
+			Context:    ctx,
+			ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var rows []searchRow
+		for result.Next() {
+			row := result.Row()
+			rows = append(rows, searchRow{key: row.ID, score: row.Score})
+		}
+		return rows, result.Err()
+	})
+}
+
+// maxSearchPages bounds how far collectHits pages past other tenants'
+// documents before settling for fewer hits.
+const maxSearchPages = 10
+
+// searchRow is one FTS hit before its key is mapped back to the tenant.
+type searchRow struct {
+	key   string
+	score float64
+}
+
+// collectHits pages through fetch until it has limit hits of the tenant in
+// ctx. The default tenant's query cannot exclude other tenants' documents, so
+// a page can come up short once those are dropped.
+func collectHits(ctx context.Context, limit int, fetch func(skip, limit int) ([]searchRow, error)) ([]repository.SearchHit, error) {
+	var hits []repository.SearchHit
+	for page := range maxSearchPages {
+		rows, err := fetch(page*limit, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, row := range rows {
+			id, ok := untenantKey(ctx, row.key)
+			if !ok {
+				continue
+			}
+			hits = append(hits, repository.SearchHit{ID: id, Score: row.score})
+			if len(hits) == limit {
+				return hits, nil
+			}
+		}
+		if len(rows) < limit {
+			break
+		}
+	}
+	return hits, nil
+}
+
+// fuzziness allows more typos in longer words, like the memory index.
+func fuzziness(word string) uint64 {
+	switch n := utf8.RuneCountInString(word); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
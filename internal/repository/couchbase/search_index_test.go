@@ -0,0 +1,52 @@
+package couchbase
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+)
+
+func TestCollectHits(t *testing.T) {
+	// The default tenant's query also returns acme's documents, interleaved
+	// with its own.
+	rows := []searchRow{
+		{"acme::1", 9}, {"a", 8}, {"acme::2", 7},
+		{"acme::3", 6}, {"b", 5}, {"acme::4", 4},
+		{"c", 3},
+	}
+	fetch := func(skip, limit int) ([]searchRow, error) {
+		return rows[min(skip, len(rows)):min(skip+limit, len(rows))], nil
+	}
+	ids := func(hits []repository.SearchHit) []string {
+		var ids []string
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name   string
+		tenant string
+		limit  int
+		want   []string
+	}{
+		{"pages past other tenants' documents", tenant.Default, 2, []string{"a", "b"}},
+		{"stops once results run out", tenant.Default, 5, []string{"a", "b", "c"}},
+		{"strips the tenant prefix", "acme", 3, []string{"1", "2", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, err := collectHits(tenant.WithTenant(context.Background(), tt.tenant), tt.limit, fetch)
+			if err != nil {
+				t.Fatalf("collectHits: %v", err)
+			}
+			if got := ids(hits); !slices.Equal(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	cbopentelemetry "github.com/couchbase/gocb-opentelemetry"
+	"github.com/couchbase/gocb/v2"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type couchbaseSessionRepository struct {
+	cluster    *gocb.Cluster
+	bucket     *gocb.Bucket
+	collection *gocb.Collection
+}
+
+type SessionDocument struct {
+	ID                  string     `json:"id"`
+	UserID              string     `json:"user_id"`
+	TokenHash           string     `json:"token_hash"`
+	PreviousTokenHashes []string   `json:"previous_token_hashes"`
+	ExpiresAt           time.Time  `json:"expires_at"`
+	RevokedAt           *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	TenantID            string     `json:"tenant_id"`
+	Type                string     `json:"type"`
+}
+
+func NewSessionRepository(conn *Connection) repository.SessionRepository {
+	return &couchbaseSessionRepository{
+		cluster:    conn.Cluster,
+		bucket:     conn.Bucket,
+		collection: conn.Bucket.DefaultCollection(),
+	}
+}
+
+func sessionKey(ctx context.Context, id string) string {
+	return tenantKey(ctx, "session::"+id)
+}
+
+func toSessionDocument(ctx context.Context, session *domain.Session) SessionDocument {
+	return SessionDocument{
+		ID:                  session.ID,
+		UserID:              session.UserID,
+		TokenHash:           session.TokenHash,
+		PreviousTokenHashes: session.PreviousTokenHashes,
+		ExpiresAt:           session.ExpiresAt,
+		RevokedAt:           session.RevokedAt,
+		CreatedAt:           session.CreatedAt,
+		UpdatedAt:           session.UpdatedAt,
+		TenantID:            tenant.FromContext(ctx),
+		Type:                "session",
+	}
+}
+
+func (r *couchbaseSessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	result, err := r.collection.Insert(sessionKey(ctx, session.ID), toSessionDocument(ctx, session), &gocb.InsertOptions{
+		Expiry:     time.Until(session.ExpiresAt),
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		return err
+	}
+
+	session.Version = uint64(result.Cas())
+	return nil
+}
+
+func (r *couchbaseSessionRepository) FindByID(ctx context.Context, id string) (*domain.Session, error) {
+	result, err := r.collection.Get(sessionKey(ctx, id), &gocb.GetOptions{
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil, domain.ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	var doc SessionDocument
+	err = result.Content(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.Session{
+		ID:                  doc.ID,
+		UserID:              doc.UserID,
+		TokenHash:           doc.TokenHash,
+		PreviousTokenHashes: doc.PreviousTokenHashes,
+		ExpiresAt:           doc.ExpiresAt,
+		RevokedAt:           doc.RevokedAt,
+		CreatedAt:           doc.CreatedAt,
+		UpdatedAt:           doc.UpdatedAt,
+		Version:             uint64(result.Cas()),
+	}, nil
+}
+
+// Update replaces the document under the CAS the session was read with.
+// RevokeAllByUserID changes the CAS as well, so a rotation cannot undo a
+// revocation that landed after the read.
+func (r *couchbaseSessionRepository) Update(ctx context.Context, session *domain.Session) error {
+	result, err := r.collection.Replace(sessionKey(ctx, session.ID), toSessionDocument(ctx, session), &gocb.ReplaceOptions{
+		Cas:        gocb.Cas(session.Version),
+		Expiry:     time.Until(session.ExpiresAt),
+		Context:    ctx,
+		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gocb.ErrCasMismatch):
+			return domain.ErrVersionMismatch
+		case errors.Is(err, gocb.ErrDocumentNotFound):
+			return domain.ErrSessionNotFound
+		}
+		return err
+	}
+
+	session.Version = uint64(result.Cas())
+	return nil
+}
+
+func (r *couchbaseSessionRepository) RevokeAllByUserID(ctx context.Context, userID string) error {
+	query := fmt.Sprintf("UPDATE `%s` x SET x.revoked_at = $2, x.updated_at = $2 WHERE x.type = 'session' AND x.user_id = $1 AND x.revoked_at IS MISSING AND %s = $3", r.bucket.Name(), tenantExpr)
+	rows, err := r.cluster.Query(query, &gocb.QueryOptions{
+		PositionalParameters: []any{userID, time.Now(), tenant.FromContext(ctx)},
+		ScanConsistency:      gocb.QueryScanConsistencyRequestPlus,
+		PreserveExpiry:       true,
+		Context:              ctx,
+		ParentSpan:           cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
@@ -0,0 +1,33 @@
+package couchbase
+
+import (
+	"context"
+	"strings"
+
+	"github.com/yusirdemir/microservice/internal/tenant"
+)
+
+// tenantKey scopes a document key to the tenant in ctx, so a key-value
+// lookup can never reach another tenant's document. The default tenant keeps
+// bare keys, which leaves documents written before tenancy where they are.
+func tenantKey(ctx context.Context, key string) string {
+	id := tenant.FromContext(ctx)
+	if id == tenant.Default {
+		return key
+	}
+	return id + "::" + key
+}
+
+// tenantExpr is the N1QL expression for a document's tenant; documents
+// without a tenant_id belong to the default tenant.
+const tenantExpr = "IFMISSINGORNULL(x.tenant_id, '" + tenant.Default + "')"
+
+// untenantKey is the inverse of tenantKey: it strips the tenant prefix and
+// reports whether key belongs to the tenant in ctx at all.
+func untenantKey(ctx context.Context, key string) (string, bool) {
+	id := tenant.FromContext(ctx)
+	if id == tenant.Default {
+		return key, !strings.Contains(key, "::")
+	}
+	return strings.CutPrefix(key, id+"::")
+}
@@ -0,0 +1,30 @@
+package couchbase
+
+import (
+	"errors"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+// domainErrors are the errors a transaction callback may return on purpose;
+// they are surfaced as-is instead of the transaction failure wrapping them.
+var domainErrors = []error{
+	domain.ErrUserNotFound,
+	domain.ErrProductNotFound,
+	domain.ErrEmailTaken,
+	domain.ErrVersionMismatch,
+	domain.ErrImageNotFound,
+	domain.ErrImageLimitReached,
+}
+
+func transactionError(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, target := range domainErrors {
+		if errors.Is(err, target) {
+			return target
+		}
+	}
+	return err
+}
@@ -3,14 +3,15 @@ package couchbase
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	cbopentelemetry "github.com/couchbase/gocb-opentelemetry"
 	"github.com/couchbase/gocb/v2"
 	"github.com/yusirdemir/microservice/internal/domain"
 	"github.com/yusirdemir/microservice/internal/repository"
-	"github.com/yusirdemir/microservice/pkg/config"
-	"go.opentelemetry.io/otel"
+	"github.com/yusirdemir/microservice/internal/tenant"
 	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
@@ -20,69 +21,171 @@ type couchbaseUserRepository struct {
 	collection *gocb.Collection
 }
 
+type UserEmailDocument struct {
+	UserID string `json:"user_id"`
+	Type   string `json:"type"`
+}
+
+// UserDocument carries its own version counter rather than relying on the
+// CAS: users are written in transactions, which do not expose the CAS, and
+// the counter is compared and bumped inside them instead.
 type UserDocument struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Type      string    `json:"type"`
-}
-
-func NewUserRepository(cfg *config.Config) (repository.UserRepository, error) {
-	cluster, err := gocb.Connect(cfg.Database.Host, gocb.ClusterOptions{
-		Authenticator: gocb.PasswordAuthenticator{
-			Username: cfg.Database.Username,
-			Password: cfg.Database.Password,
-		},
-		Tracer: cbopentelemetry.NewOpenTelemetryRequestTracer(otel.GetTracerProvider()),
-	})
-	if err != nil {
-		return nil, err
-	}
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Password  string     `json:"password"`
+	Roles     []string   `json:"roles"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Version   uint64     `json:"version"`
+	TenantID  string     `json:"tenant_id"`
+	Type      string     `json:"type"`
+}
 
-	bucket := cluster.Bucket(cfg.Database.Bucket)
-	err = bucket.WaitUntilReady(30*time.Second, nil)
-	if err != nil {
-		return nil, err
+func NewUserRepository(conn *Connection) repository.UserRepository {
+	return &couchbaseUserRepository{
+		cluster:    conn.Cluster,
+		bucket:     conn.Bucket,
+		collection: conn.Bucket.DefaultCollection(),
 	}
+}
 
-	collection := bucket.DefaultCollection()
-
-	return &couchbaseUserRepository{
-		cluster:    cluster,
-		bucket:     bucket,
-		collection: collection,
-	}, nil
+func userEmailKey(email string) string {
+	return "user_email::" + email
 }
 
-func (r *couchbaseUserRepository) Create(ctx context.Context, user *domain.User) error {
-	doc := UserDocument{
+func toUserDocument(ctx context.Context, user *domain.User) UserDocument {
+	roles := make([]string, 0, len(user.Roles()))
+	for _, r := range user.Roles() {
+		roles = append(roles, string(r))
+	}
+
+	return UserDocument{
 		ID:        user.ID(),
 		Name:      user.Name(),
 		Email:     user.Email(),
 		Password:  user.Password(),
+		Roles:     roles,
 		CreatedAt: user.CreatedAt(),
 		UpdatedAt: user.UpdatedAt(),
+		DeletedAt: user.DeletedAt(),
+		Version:   user.Version(),
+		TenantID:  tenant.FromContext(ctx),
 		Type:      "user",
 	}
+}
 
-	_, err := r.collection.Insert(user.ID(), doc, &gocb.InsertOptions{
-		Context:    ctx,
-		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
-	})
-	return err
+func fromUserDocument(doc UserDocument) *domain.User {
+	roles := make([]domain.Role, 0, len(doc.Roles))
+	for _, r := range doc.Roles {
+		roles = append(roles, domain.Role(r))
+	}
+
+	return domain.Reconstitute(
+		doc.ID,
+		doc.Name,
+		doc.Email,
+		doc.Password,
+		roles,
+		doc.CreatedAt,
+		doc.UpdatedAt,
+		doc.DeletedAt,
+		documentVersion(doc),
+	)
+}
+
+// documentVersion treats documents written before versioning as version 1.
+func documentVersion(doc UserDocument) uint64 {
+	return max(doc.Version, 1)
+}
+
+// Create writes the user together with a lookup document keyed by email in a
+// single transaction; the lookup key is what makes addresses unique.
+func (r *couchbaseUserRepository) Create(ctx context.Context, user *domain.User) error {
+	// Users written before the lookup documents existed are only reachable
+	// through the query fallback in findByEmail. Soft-deleted users still
+	// hold their address.
+	if _, err := r.findByEmail(ctx, user.Email()); err == nil {
+		return domain.ErrEmailTaken
+	} else if !errors.Is(err, domain.ErrUserNotFound) {
+		return err
+	}
+
+	_, err := r.cluster.Transactions().Run(func(tac *gocb.TransactionAttemptContext) error {
+		_, err := tac.Insert(r.collection, tenantKey(ctx, userEmailKey(user.Email())), UserEmailDocument{UserID: user.ID(), Type: "user_email"})
+		if err != nil {
+			if errors.Is(err, gocb.ErrDocumentExists) {
+				return domain.ErrEmailTaken
+			}
+			return err
+		}
+
+		doc := toUserDocument(ctx, user)
+		doc.Version = 1
+		_, err = tac.Insert(r.collection, tenantKey(ctx, user.ID()), doc)
+		return err
+	}, nil)
+	if err != nil {
+		return transactionError(err)
+	}
+
+	user.SetVersion(1)
+	return nil
 }
 
 func (r *couchbaseUserRepository) FindByID(ctx context.Context, id string) (*domain.User, error) {
-	result, err := r.collection.Get(id, &gocb.GetOptions{
+	user, err := r.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsDeleted() {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (r *couchbaseUserRepository) FindDeletedByID(ctx context.Context, id string) (*domain.User, error) {
+	user, err := r.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsDeleted() {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (r *couchbaseUserRepository) FindAllDeletedBefore(ctx context.Context, cutoff time.Time) ([]*domain.User, error) {
+	query := fmt.Sprintf("SELECT x.* FROM `%s` x WHERE x.type = 'user' AND x.deleted_at IS VALUED AND STR_TO_MILLIS(x.deleted_at) < $1 AND %s = $2", r.bucket.Name(), tenantExpr)
+	rows, err := r.cluster.Query(query, &gocb.QueryOptions{
+		PositionalParameters: []any{cutoff.UnixMilli(), tenant.FromContext(ctx)},
+		Context:              ctx,
+		ParentSpan:           cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var users []*domain.User
+	for rows.Next() {
+		var doc UserDocument
+		if err := rows.Row(&doc); err != nil {
+			return nil, err
+		}
+		users = append(users, fromUserDocument(doc))
+	}
+	return users, rows.Err()
+}
+
+func (r *couchbaseUserRepository) get(ctx context.Context, id string) (*domain.User, error) {
+	result, err := r.collection.Get(tenantKey(ctx, id), &gocb.GetOptions{
 		Context:    ctx,
 		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
 	})
 	if err != nil {
 		if errors.Is(err, gocb.ErrDocumentNotFound) {
-			return nil, errors.New("user not found")
+			return nil, domain.ErrUserNotFound
 		}
 		return nil, err
 	}
@@ -93,38 +196,265 @@ func (r *couchbaseUserRepository) FindByID(ctx context.Context, id string) (*dom
 		return nil, err
 	}
 
-	return domain.Reconstitute(
-		doc.ID,
-		doc.Name,
-		doc.Email,
-		doc.Password,
-		doc.CreatedAt,
-		doc.UpdatedAt,
-	), nil
+	return fromUserDocument(doc), nil
 }
 
-func (r *couchbaseUserRepository) Update(ctx context.Context, user *domain.User) error {
-	doc := UserDocument{
-		ID:        user.ID(),
-		Name:      user.Name(),
-		Email:     user.Email(),
-		Password:  user.Password(),
-		CreatedAt: user.CreatedAt(),
-		UpdatedAt: user.UpdatedAt(),
-		Type:      "user",
+func (r *couchbaseUserRepository) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
+	user, err := r.findByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsDeleted() {
+		return nil, domain.ErrUserNotFound
 	}
+	return user, nil
+}
 
-	_, err := r.collection.Replace(user.ID(), doc, &gocb.ReplaceOptions{
+// findByEmail resolves an address to its user, soft-deleted or not.
+func (r *couchbaseUserRepository) findByEmail(ctx context.Context, email string) (*domain.User, error) {
+	result, err := r.collection.Get(tenantKey(ctx, userEmailKey(email)), &gocb.GetOptions{
 		Context:    ctx,
 		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
 	})
-	return err
+	if err == nil {
+		var lookup UserEmailDocument
+		if err := result.Content(&lookup); err != nil {
+			return nil, err
+		}
+		return r.get(ctx, lookup.UserID)
+	}
+	if !errors.Is(err, gocb.ErrDocumentNotFound) {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("SELECT x.* FROM `%s` x WHERE x.type = 'user' AND x.email = $1 AND %s = $2 LIMIT 1", r.bucket.Name(), tenantExpr)
+	rows, err := r.cluster.Query(query, &gocb.QueryOptions{
+		PositionalParameters: []any{email, tenant.FromContext(ctx)},
+		Context:              ctx,
+		ParentSpan:           cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var doc UserDocument
+	if err := rows.One(&doc); err != nil {
+		if errors.Is(err, gocb.ErrNoResult) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return fromUserDocument(doc), nil
+}
+
+func (r *couchbaseUserRepository) Update(ctx context.Context, user *domain.User) error {
+	doc := toUserDocument(ctx, user)
+
+	_, err := r.cluster.Transactions().Run(func(tac *gocb.TransactionAttemptContext) error {
+		current, err := tac.Get(r.collection, tenantKey(ctx, user.ID()))
+		if err != nil {
+			if errors.Is(err, gocb.ErrDocumentNotFound) {
+				return domain.ErrUserNotFound
+			}
+			return err
+		}
+
+		var existing UserDocument
+		if err := current.Content(&existing); err != nil {
+			return err
+		}
+		if documentVersion(existing) != user.Version() {
+			return domain.ErrVersionMismatch
+		}
+		doc.Version = user.Version() + 1
+
+		if existing.Email != doc.Email {
+			_, err := tac.Insert(r.collection, tenantKey(ctx, userEmailKey(doc.Email)), UserEmailDocument{UserID: doc.ID, Type: "user_email"})
+			if err != nil {
+				if errors.Is(err, gocb.ErrDocumentExists) {
+					return domain.ErrEmailTaken
+				}
+				return err
+			}
+			if err := r.removeEmailLookup(ctx, tac, existing.Email); err != nil {
+				return err
+			}
+		}
+
+		_, err = tac.Replace(current, doc)
+		return err
+	}, nil)
+	if err != nil {
+		return transactionError(err)
+	}
+
+	user.SetVersion(doc.Version)
+	return nil
 }
 
 func (r *couchbaseUserRepository) Delete(ctx context.Context, id string) error {
-	_, err := r.collection.Remove(id, &gocb.RemoveOptions{
+	_, err := r.cluster.Transactions().Run(func(tac *gocb.TransactionAttemptContext) error {
+		current, err := tac.Get(r.collection, tenantKey(ctx, id))
+		if err != nil {
+			if errors.Is(err, gocb.ErrDocumentNotFound) {
+				return domain.ErrUserNotFound
+			}
+			return err
+		}
+
+		var existing UserDocument
+		if err := current.Content(&existing); err != nil {
+			return err
+		}
+
+		if err := r.removeEmailLookup(ctx, tac, existing.Email); err != nil {
+			return err
+		}
+
+		return tac.Remove(current)
+	}, nil)
+	return transactionError(err)
+}
+
+func (r *couchbaseUserRepository) removeEmailLookup(ctx context.Context, tac *gocb.TransactionAttemptContext, email string) error {
+	lookup, err := tac.Get(r.collection, tenantKey(ctx, userEmailKey(email)))
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil
+		}
+		return err
+	}
+	return tac.Remove(lookup)
+}
+
+// userSortExpressions maps each sort to the N1QL expression it orders by.
+// Timestamps compare as epoch millis, matching the cursor values.
+var userSortExpressions = map[repository.UserSort]string{
+	repository.UserSortCreatedAt: "STR_TO_MILLIS(x.created_at)",
+	repository.UserSortName:      "x.name",
+	repository.UserSortEmail:     "x.email",
+}
+
+func (r *couchbaseUserRepository) List(ctx context.Context, query repository.UserQuery) (*repository.UserPage, error) {
+	query.Normalize()
+	after, err := repository.DecodeCursor(query.Cursor, string(query.Sort), query.Descending)
+	if err != nil {
+		return nil, err
+	}
+
+	sortExpr := userSortExpressions[query.Sort]
+	conditions := []string{"x.type = 'user'", "x.deleted_at IS MISSING", tenantExpr + " = $tenant"}
+	params := map[string]any{"tenant": tenant.FromContext(ctx)}
+
+	if query.NamePrefix != "" {
+		conditions = append(conditions, "LOWER(x.name) LIKE $name")
+		params["name"] = likeEscaper.Replace(strings.ToLower(query.NamePrefix)) + "%"
+	}
+	if query.EmailPrefix != "" {
+		conditions = append(conditions, "x.email LIKE $email")
+		params["email"] = likeEscaper.Replace(query.EmailPrefix) + "%"
+	}
+	if query.CreatedFrom != nil {
+		conditions = append(conditions, "STR_TO_MILLIS(x.created_at) >= $created_from")
+		params["created_from"] = query.CreatedFrom.UnixMilli()
+	}
+	if query.CreatedTo != nil {
+		conditions = append(conditions, "STR_TO_MILLIS(x.created_at) < $created_to")
+		params["created_to"] = query.CreatedTo.UnixMilli()
+	}
+
+	direction, op := "ASC", ">"
+	if query.Descending {
+		direction, op = "DESC", "<"
+	}
+
+	if after != nil {
+		var value any
+		if query.Sort == repository.UserSortCreatedAt {
+			value, err = after.Int64()
+		} else {
+			value, err = after.String()
+		}
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, fmt.Sprintf("(%[1]s %[2]s $after_value OR (%[1]s = $after_value AND x.id %[2]s $after_id))", sortExpr, op))
+		params["after_value"] = value
+		params["after_id"] = after.ID
+	}
+
+	statement := fmt.Sprintf("SELECT x.* FROM `%s` x WHERE %s ORDER BY %s %s, x.id %s LIMIT %d",
+		r.bucket.Name(), strings.Join(conditions, " AND "), sortExpr, direction, direction, query.Limit+1)
+	rows, err := r.cluster.Query(statement, &gocb.QueryOptions{
+		NamedParameters: params,
+		Context:         ctx,
+		ParentSpan:      cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []UserDocument
+	for rows.Next() {
+		var doc UserDocument
+		if err := rows.Row(&doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &repository.UserPage{}
+	if len(docs) > query.Limit {
+		docs = docs[:query.Limit]
+		last := docs[len(docs)-1]
+		var value any
+		switch query.Sort {
+		case repository.UserSortName:
+			value = last.Name
+		case repository.UserSortEmail:
+			value = last.Email
+		default:
+			value = last.CreatedAt.UnixMilli()
+		}
+		page.NextCursor = repository.EncodeCursor(repository.Cursor{
+			Sort:       string(query.Sort),
+			Descending: query.Descending,
+			Value:      value,
+			ID:         last.ID,
+		})
+	}
+	for _, doc := range docs {
+		page.Users = append(page.Users, fromUserDocument(doc))
+	}
+
+	return page, nil
+}
+
+func (r *couchbaseUserRepository) ListTenants(ctx context.Context) ([]string, error) {
+	query := fmt.Sprintf("SELECT DISTINCT RAW %s FROM `%s` x WHERE x.type = 'user'", tenantExpr, r.bucket.Name())
+	rows, err := r.cluster.Query(query, &gocb.QueryOptions{
 		Context:    ctx,
 		ParentSpan: cbopentelemetry.NewOpenTelemetryRequestSpan(ctx, oteltrace.SpanFromContext(ctx)),
 	})
-	return err
+	if err != nil {
+		return nil, err
+	}
+
+	var tenants []string
+	for rows.Next() {
+		var id string
+		if err := rows.Row(&id); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, id)
+	}
+	return tenants, rows.Err()
 }
+
+// likeEscaper neutralizes LIKE wildcards in user input; backslash is the
+// default N1QL escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
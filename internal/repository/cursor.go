@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is the keyset position after the last item of a page: the value of
+// the sort key and the ID that breaks ties. It travels to clients as an opaque
+// string and is only valid for the sort it was issued for.
+type Cursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d,omitempty"`
+	Value      any    `json:"v"`
+	ID         string `json:"id"`
+}
+
+func EncodeCursor(c Cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor parses an opaque cursor and checks it belongs to the given
+// sort. An empty string decodes to nil, meaning the first page.
+func DecodeCursor(s string, sort string, descending bool) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sort || c.Descending != descending || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// Int64 returns a numeric cursor value. JSON decoding yields float64, which
+// holds millisecond timestamps exactly.
+func (c *Cursor) Int64() (int64, error) {
+	switch v := c.Value.(type) {
+	case float64:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	default:
+		return 0, ErrInvalidCursor
+	}
+}
+
+func (c *Cursor) String() (string, error) {
+	v, ok := c.Value.(string)
+	if !ok {
+		return "", ErrInvalidCursor
+	}
+	return v, nil
+}
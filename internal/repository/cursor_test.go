@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	// A millisecond timestamp survives the trip through JSON exactly.
+	createdAt := time.Date(2026, 3, 14, 15, 9, 26, 535_000_000, time.UTC).UnixMilli()
+	encoded := EncodeCursor(Cursor{Sort: "created_at", Descending: true, Value: createdAt, ID: "user-1"})
+
+	c, err := DecodeCursor(encoded, "created_at", true)
+	if err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+	if c.ID != "user-1" {
+		t.Fatalf("expected id user-1, got %q", c.ID)
+	}
+	got, err := c.Int64()
+	if err != nil {
+		t.Fatalf("Int64: %v", err)
+	}
+	if got != createdAt {
+		t.Fatalf("expected %d, got %d", createdAt, got)
+	}
+	if _, err := c.String(); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected a numeric value not to read as a string, got %v", err)
+	}
+
+	c, err = DecodeCursor(EncodeCursor(Cursor{Sort: "name", Value: "Ada", ID: "user-1"}), "name", false)
+	if err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+	if name, err := c.String(); err != nil || name != "Ada" {
+		t.Fatalf("expected Ada, got %q, %v", name, err)
+	}
+	if _, err := c.Int64(); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected a string value not to read as a number, got %v", err)
+	}
+}
+
+func TestDecodeCursor_FirstPage(t *testing.T) {
+	c, err := DecodeCursor("", "created_at", false)
+	if err != nil || c != nil {
+		t.Fatalf("expected no cursor for the first page, got %v, %v", c, err)
+	}
+}
+
+func TestDecodeCursor_Rejects(t *testing.T) {
+	issued := EncodeCursor(Cursor{Sort: "created_at", Value: int64(1), ID: "user-1"})
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name       string
+		cursor     string
+		sort       string
+		descending bool
+	}{
+		{"another sort", issued, "name", false},
+		{"another direction", issued, "created_at", true},
+		{"not base64", "!!!", "created_at", false},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"s":"created_at","v":1,"id":"user-1"}`)), "created_at", false},
+		{"not json", encode("created_at:1:user-1"), "created_at", false},
+		{"without id", encode(`{"s":"created_at","v":1}`), "created_at", false},
+		{"truncated", issued[:len(issued)-4], "created_at", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.cursor, tt.sort, tt.descending); !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("expected ErrInvalidCursor, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+// ExportJobRepository stores export jobs and their finished archives. Both
+// disappear once the job's ExpiresAt has passed. A user has at most one
+// pending job: Create fails with domain.ErrExportPending while another is
+// pending, until Update moves that one on.
+type ExportJobRepository interface {
+	Create(ctx context.Context, job *domain.ExportJob) error
+	FindByID(ctx context.Context, id string) (*domain.ExportJob, error)
+	Update(ctx context.Context, job *domain.ExportJob) error
+	SaveArchive(ctx context.Context, job *domain.ExportJob, archive []byte) error
+	FindArchive(ctx context.Context, id string) ([]byte, error)
+}
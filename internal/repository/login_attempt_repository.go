@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+// LoginAttemptRepository tracks failed logins per key. Reserve and Refund must
+// be atomic, since replicas count attempts for the same key concurrently.
+// Reserve fails with a *domain.LoginThrottledError while the key is backing
+// off; Refund on a key with no record is a no-op.
+type LoginAttemptRepository interface {
+	Find(ctx context.Context, key string) (*domain.LoginAttempts, error)
+	Reserve(ctx context.Context, key string, policy domain.LockoutPolicy, now time.Time) (attempts *domain.LoginAttempts, locked bool, err error)
+	Refund(ctx context.Context, key string, policy domain.LockoutPolicy) error
+	Delete(ctx context.Context, key string) error
+}
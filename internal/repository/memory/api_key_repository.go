@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+)
+
+type memoryAPIKeyRepository struct {
+	// tenants maps a tenant to its keys by ID.
+	tenants map[string]map[string]*domain.APIKey
+	mu      sync.RWMutex
+}
+
+func NewAPIKeyRepository() repository.APIKeyRepository {
+	return &memoryAPIKeyRepository{
+		tenants: make(map[string]map[string]*domain.APIKey),
+	}
+}
+
+// keys returns the tenant's keys, creating the map when asked to. Callers
+// hold r.mu.
+func (r *memoryAPIKeyRepository) keys(ctx context.Context, create bool) map[string]*domain.APIKey {
+	id := tenant.FromContext(ctx)
+	keys, ok := r.tenants[id]
+	if !ok && create {
+		keys = make(map[string]*domain.APIKey)
+		r.tenants[id] = keys
+	}
+	return keys
+}
+
+func (r *memoryAPIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := r.keys(ctx, true)
+	if _, exists := keys[key.ID]; exists {
+		return errors.New("api key already exists")
+	}
+
+	keys[key.ID] = cloneAPIKey(key)
+	return nil
+}
+
+func cloneAPIKey(k *domain.APIKey) *domain.APIKey {
+	copied := *k
+	copied.Scopes = slices.Clone(k.Scopes)
+	if k.LastUsedAt != nil {
+		lastUsedAt := *k.LastUsedAt
+		copied.LastUsedAt = &lastUsedAt
+	}
+	if k.RevokedAt != nil {
+		revokedAt := *k.RevokedAt
+		copied.RevokedAt = &revokedAt
+	}
+	return &copied
+}
+
+func (r *memoryAPIKeyRepository) FindByID(ctx context.Context, id string) (*domain.APIKey, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, exists := r.keys(ctx, false)[id]
+	if !exists {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+
+	return cloneAPIKey(key), nil
+}
+
+func (r *memoryAPIKeyRepository) FindAllByUserID(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var keys []*domain.APIKey
+	for _, k := range r.keys(ctx, false) {
+		if k.UserID == userID {
+			keys = append(keys, cloneAPIKey(k))
+		}
+	}
+	return keys, nil
+}
+
+func (r *memoryAPIKeyRepository) Update(ctx context.Context, key *domain.APIKey) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := r.keys(ctx, false)
+	if _, exists := keys[key.ID]; !exists {
+		return domain.ErrAPIKeyNotFound
+	}
+
+	keys[key.ID] = cloneAPIKey(key)
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) Touch(ctx context.Context, id string, usedAt time.Time) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, exists := r.keys(ctx, false)[id]
+	if !exists {
+		return domain.ErrAPIKeyNotFound
+	}
+
+	key.LastUsedAt = &usedAt
+	return nil
+}
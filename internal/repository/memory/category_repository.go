@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+)
+
+type memoryCategoryRepository struct {
+	// tenants maps a tenant to its categories by ID.
+	tenants map[string]map[string]*domain.Category
+	mu      sync.RWMutex
+}
+
+func NewCategoryRepository() repository.CategoryRepository {
+	return &memoryCategoryRepository{
+		tenants: make(map[string]map[string]*domain.Category),
+	}
+}
+
+// categories returns the tenant's categories, creating the map when asked
+// to. Callers hold r.mu.
+func (r *memoryCategoryRepository) categories(ctx context.Context, create bool) map[string]*domain.Category {
+	id := tenant.FromContext(ctx)
+	categories, ok := r.tenants[id]
+	if !ok && create {
+		categories = make(map[string]*domain.Category)
+		r.tenants[id] = categories
+	}
+	return categories
+}
+
+func (r *memoryCategoryRepository) Create(ctx context.Context, category *domain.Category) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	categories := r.categories(ctx, true)
+	if _, exists := categories[category.ID]; exists {
+		return errors.New("category already exists")
+	}
+
+	stored := *category
+	categories[category.ID] = &stored
+	return nil
+}
+
+func (r *memoryCategoryRepository) FindByID(ctx context.Context, id string) (*domain.Category, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	category, exists := r.categories(ctx, false)[id]
+	if !exists {
+		return nil, domain.ErrCategoryNotFound
+	}
+
+	c := *category
+	return &c, nil
+}
+
+func (r *memoryCategoryRepository) FindAll(ctx context.Context) ([]*domain.Category, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	categories := make([]*domain.Category, 0, len(r.categories(ctx, false)))
+	for _, category := range r.categories(ctx, false) {
+		c := *category
+		categories = append(categories, &c)
+	}
+
+	slices.SortFunc(categories, func(a, b *domain.Category) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return categories, nil
+}
+
+func (r *memoryCategoryRepository) Update(ctx context.Context, category *domain.Category) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	categories := r.categories(ctx, false)
+	if _, exists := categories[category.ID]; !exists {
+		return domain.ErrCategoryNotFound
+	}
+
+	stored := *category
+	categories[category.ID] = &stored
+	return nil
+}
+
+func (r *memoryCategoryRepository) Delete(ctx context.Context, id string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	categories := r.categories(ctx, false)
+	if _, exists := categories[id]; !exists {
+		return domain.ErrCategoryNotFound
+	}
+
+	delete(categories, id)
+	return nil
+}
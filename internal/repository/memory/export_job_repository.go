@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+)
+
+type memoryExportJobRepository struct {
+	tenants map[string]*exportJobs
+	mu      sync.RWMutex
+}
+
+// exportJobs holds one tenant's jobs and archives by job ID.
+type exportJobs struct {
+	jobs     map[string]*domain.ExportJob
+	archives map[string][]byte
+}
+
+func NewExportJobRepository() repository.ExportJobRepository {
+	return &memoryExportJobRepository{
+		tenants: make(map[string]*exportJobs),
+	}
+}
+
+// store returns the tenant's jobs, creating them when asked to; otherwise a
+// tenant without jobs gets an empty, read-only set. Callers hold r.mu.
+func (r *memoryExportJobRepository) store(ctx context.Context, create bool) *exportJobs {
+	id := tenant.FromContext(ctx)
+	store, ok := r.tenants[id]
+	if !ok {
+		store = &exportJobs{jobs: make(map[string]*domain.ExportJob), archives: make(map[string][]byte)}
+		if create {
+			r.tenants[id] = store
+		}
+	}
+	return store
+}
+
+func (r *memoryExportJobRepository) Create(ctx context.Context, job *domain.ExportJob) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pruneLocked(time.Now())
+
+	store := r.store(ctx, true)
+	if _, exists := store.jobs[job.ID]; exists {
+		return errors.New("export job already exists")
+	}
+	for _, other := range store.jobs {
+		if other.UserID == job.UserID && other.IsPending() {
+			return domain.ErrExportPending
+		}
+	}
+
+	// Jobs are finished by a background goroutine, so keep a copy rather
+	// than sharing the caller's pointer across goroutines.
+	copied := *job
+	store.jobs[job.ID] = &copied
+	return nil
+}
+
+func (r *memoryExportJobRepository) FindByID(ctx context.Context, id string) (*domain.ExportJob, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	job, exists := r.store(ctx, false).jobs[id]
+	if !exists || !time.Now().Before(job.ExpiresAt) {
+		return nil, domain.ErrExportNotFound
+	}
+
+	copied := *job
+	return &copied, nil
+}
+
+func (r *memoryExportJobRepository) Update(ctx context.Context, job *domain.ExportJob) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	store := r.store(ctx, false)
+	if _, exists := store.jobs[job.ID]; !exists {
+		return domain.ErrExportNotFound
+	}
+
+	copied := *job
+	store.jobs[job.ID] = &copied
+	return nil
+}
+
+func (r *memoryExportJobRepository) SaveArchive(ctx context.Context, job *domain.ExportJob, archive []byte) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	store := r.store(ctx, false)
+	if _, exists := store.jobs[job.ID]; !exists {
+		return domain.ErrExportNotFound
+	}
+
+	store.archives[job.ID] = archive
+	return nil
+}
+
+func (r *memoryExportJobRepository) FindArchive(ctx context.Context, id string) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	store := r.store(ctx, false)
+	job, exists := store.jobs[id]
+	archive, stored := store.archives[id]
+	if !exists || !stored || !time.Now().Before(job.ExpiresAt) {
+		return nil, domain.ErrExportNotFound
+	}
+
+	return archive, nil
+}
+
+// pruneLocked drops expired jobs and their archives, which would otherwise
+// hold memory until restart. Callers must hold the write lock.
+func (r *memoryExportJobRepository) pruneLocked(now time.Time) {
+	for tenantID, store := range r.tenants {
+		for id, job := range store.jobs {
+			if !now.Before(job.ExpiresAt) {
+				delete(store.jobs, id)
+				delete(store.archives, id)
+			}
+		}
+		if len(store.jobs) == 0 {
+			delete(r.tenants, tenantID)
+		}
+	}
+}
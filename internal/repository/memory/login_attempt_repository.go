@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+)
+
+// loginAttemptPruneInterval spaces out the sweeps for expired entries, so a
+// burst of logins does not scan the whole map on every attempt.
+const loginAttemptPruneInterval = time.Minute
+
+type loginAttemptEntry struct {
+	attempts  domain.LoginAttempts
+	expiresAt time.Time
+}
+
+type memoryLoginAttemptRepository struct {
+	entries map[string]*loginAttemptEntry
+	// nextPrune is when Reserve next sweeps expired entries.
+	nextPrune time.Time
+	mu        sync.RWMutex
+}
+
+func NewLoginAttemptRepository() repository.LoginAttemptRepository {
+	return &memoryLoginAttemptRepository{
+		entries: make(map[string]*loginAttemptEntry),
+	}
+}
+
+func (r *memoryLoginAttemptRepository) Find(ctx context.Context, key string) (*domain.LoginAttempts, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, exists := r.entries[key]
+	if !exists || !time.Now().Before(entry.expiresAt) {
+		return nil, domain.ErrAttemptsNotFound
+	}
+
+	attempts := entry.attempts
+	return &attempts, nil
+}
+
+func (r *memoryLoginAttemptRepository) Reserve(ctx context.Context, key string, policy domain.LockoutPolicy, now time.Time) (*domain.LoginAttempts, bool, error) {
+	select {
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, exists := r.entries[key]
+	if !exists || !now.Before(entry.expiresAt) {
+		entry = &loginAttemptEntry{attempts: *domain.NewLoginAttempts(key)}
+	}
+
+	if !now.Before(r.nextPrune) {
+		r.pruneLocked(now)
+		r.nextPrune = now.Add(loginAttemptPruneInterval)
+	}
+
+	locked, err := entry.attempts.Reserve(now, policy)
+	if err != nil {
+		return nil, false, err
+	}
+	entry.expiresAt = now.Add(policy.Retention())
+	r.entries[key] = entry
+
+	attempts := entry.attempts
+	return &attempts, locked, nil
+}
+
+func (r *memoryLoginAttemptRepository) Refund(ctx context.Context, key string, policy domain.LockoutPolicy) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry, exists := r.entries[key]; exists {
+		entry.attempts.Refund(policy)
+	}
+	return nil
+}
+
+func (r *memoryLoginAttemptRepository) Delete(ctx context.Context, key string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.entries, key)
+	return nil
+}
+
+// pruneLocked drops expired entries so addresses that fail once and never
+// return do not accumulate. Reserve runs it at most once per
+// loginAttemptPruneInterval. Callers must hold the write lock.
+func (r *memoryLoginAttemptRepository) pruneLocked(now time.Time) {
+	for key, entry := range r.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(r.entries, key)
+		}
+	}
+}
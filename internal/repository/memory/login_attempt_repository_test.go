@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+func TestLoginAttemptRepository_Prune(t *testing.T) {
+	ctx := context.Background()
+	// Entries live for two seconds, far less than the prune interval.
+	policy := domain.LockoutPolicy{
+		FreeAttempts:     10,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Second,
+		LockoutThreshold: 10,
+		LockoutDuration:  time.Second,
+		ResetAfter:       time.Second,
+	}
+	repo := NewLoginAttemptRepository().(*memoryLoginAttemptRepository)
+
+	start := time.Now()
+	reserve := func(key string, at time.Time) {
+		t.Helper()
+		if _, _, err := repo.Reserve(ctx, key, policy, at); err != nil {
+			t.Fatalf("Reserve(%q): %v", key, err)
+		}
+	}
+
+	reserve("stale", start)
+	reserve("fresh", start.Add(time.Minute/2))
+
+	// The stale entry has expired but waits for the next sweep.
+	if len(repo.entries) != 2 {
+		t.Fatalf("expected no sweep within the interval, got %d entries", len(repo.entries))
+	}
+
+	reserve("late", start.Add(loginAttemptPruneInterval))
+	if _, ok := repo.entries["stale"]; ok {
+		t.Fatal("expected the sweep after the interval to drop the stale entry")
+	}
+	if _, ok := repo.entries["fresh"]; ok {
+		t.Fatal("expected the sweep to drop every expired entry")
+	}
+	if _, ok := repo.entries["late"]; !ok {
+		t.Fatal("expected the new entry to be kept")
+	}
+}
@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+)
+
+type memoryOneTimeTokenRepository struct {
+	// tenants maps a tenant to its tokens by ID.
+	tenants map[string]map[string]*domain.OneTimeToken
+	mu      sync.RWMutex
+}
+
+func NewOneTimeTokenRepository() repository.OneTimeTokenRepository {
+	return &memoryOneTimeTokenRepository{
+		tenants: make(map[string]map[string]*domain.OneTimeToken),
+	}
+}
+
+// tokens returns the tenant's tokens, creating the map when asked to.
+// Callers hold r.mu.
+func (r *memoryOneTimeTokenRepository) tokens(ctx context.Context, create bool) map[string]*domain.OneTimeToken {
+	id := tenant.FromContext(ctx)
+	tokens, ok := r.tenants[id]
+	if !ok && create {
+		tokens = make(map[string]*domain.OneTimeToken)
+		r.tenants[id] = tokens
+	}
+	return tokens
+}
+
+func (r *memoryOneTimeTokenRepository) Create(ctx context.Context, token *domain.OneTimeToken) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tokens := r.tokens(ctx, true)
+	if _, exists := tokens[token.ID]; exists {
+		return errors.New("token already exists")
+	}
+
+	copied := *token
+	tokens[token.ID] = &copied
+	return nil
+}
+
+func (r *memoryOneTimeTokenRepository) FindByID(ctx context.Context, id string) (*domain.OneTimeToken, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	token, exists := r.tokens(ctx, false)[id]
+	if !exists {
+		return nil, domain.ErrTokenNotFound
+	}
+
+	copied := *token
+	return &copied, nil
+}
+
+func (r *memoryOneTimeTokenRepository) MarkUsed(ctx context.Context, id string, usedAt time.Time) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tokens := r.tokens(ctx, false)
+	token, exists := tokens[id]
+	if !exists {
+		return domain.ErrTokenNotFound
+	}
+	if token.UsedAt != nil {
+		return domain.ErrTokenUsed
+	}
+
+	copied := *token
+	copied.MarkUsed(usedAt)
+	tokens[id] = &copied
+	return nil
+}
+
+func (r *memoryOneTimeTokenRepository) DeleteAllByUserID(ctx context.Context, userID string, purpose domain.TokenPurpose) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tokens := r.tokens(ctx, false)
+	for id, t := range tokens {
+		if t.UserID == userID && t.Purpose == purpose {
+			delete(tokens, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+)
+
+type memoryProductImageRepository struct {
+	// tenants maps a tenant to its images by ID.
+	tenants map[string]map[string]*domain.ProductImage
+	mu      sync.RWMutex
+}
+
+func NewProductImageRepository() repository.ProductImageRepository {
+	return &memoryProductImageRepository{
+		tenants: make(map[string]map[string]*domain.ProductImage),
+	}
+}
+
+// images returns the tenant's images, creating the map when asked to.
+// Callers hold r.mu.
+func (r *memoryProductImageRepository) images(ctx context.Context, create bool) map[string]*domain.ProductImage {
+	id := tenant.FromContext(ctx)
+	images, ok := r.tenants[id]
+	if !ok && create {
+		images = make(map[string]*domain.ProductImage)
+		r.tenants[id] = images
+	}
+	return images
+}
+
+func (r *memoryProductImageRepository) Create(ctx context.Context, image *domain.ProductImage, limit int) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	images := r.images(ctx, true)
+	if _, exists := images[image.ID]; exists {
+		return errors.New("image already exists")
+	}
+
+	count := 0
+	for _, existing := range images {
+		if existing.ProductID == image.ProductID {
+			count++
+		}
+	}
+	if count >= limit {
+		return domain.ErrImageLimitReached
+	}
+
+	stored := *image
+	images[image.ID] = &stored
+	return nil
+}
+
+func (r *memoryProductImageRepository) FindByID(ctx context.Context, id string) (*domain.ProductImage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	image, exists := r.images(ctx, false)[id]
+	if !exists {
+		return nil, domain.ErrImageNotFound
+	}
+
+	c := *image
+	return &c, nil
+}
+
+func (r *memoryProductImageRepository) FindAllByProductID(ctx context.Context, productID string) ([]*domain.ProductImage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var images []*domain.ProductImage
+	for _, image := range r.images(ctx, false) {
+		if image.ProductID == productID {
+			c := *image
+			images = append(images, &c)
+		}
+	}
+
+	slices.SortFunc(images, func(a, b *domain.ProductImage) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return images, nil
+}
+
+func (r *memoryProductImageRepository) Delete(ctx context.Context, id string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	images := r.images(ctx, false)
+	if _, exists := images[id]; !exists {
+		return domain.ErrImageNotFound
+	}
+
+	delete(images, id)
+	return nil
+}
+
+func (r *memoryProductImageRepository) DeleteAllByProductIDs(ctx context.Context, productIDs []string) ([]*domain.ProductImage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var removed []*domain.ProductImage
+	images := r.images(ctx, false)
+	for id, image := range images {
+		if slices.Contains(productIDs, image.ProductID) {
+			delete(images, id)
+			removed = append(removed, image)
+		}
+	}
+	return removed, nil
+}
@@ -3,23 +3,40 @@ package memory
 import (
 	"context"
 	"errors"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/yusirdemir/microservice/internal/domain"
 	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
 )
 
 type memoryProductRepository struct {
-	products map[string]*domain.Product
-	mu       sync.RWMutex
+	// tenants maps a tenant to its products by ID.
+	tenants map[string]map[string]*domain.Product
+	mu      sync.RWMutex
 }
 
 func NewProductRepository() repository.ProductRepository {
 	return &memoryProductRepository{
-		products: make(map[string]*domain.Product),
+		tenants: make(map[string]map[string]*domain.Product),
 	}
 }
 
+// products returns the tenant's products, creating the map when asked to.
+// Callers hold r.mu.
+func (r *memoryProductRepository) products(ctx context.Context, create bool) map[string]*domain.Product {
+	id := tenant.FromContext(ctx)
+	products, ok := r.tenants[id]
+	if !ok && create {
+		products = make(map[string]*domain.Product)
+		r.tenants[id] = products
+	}
+	return products
+}
+
 func (r *memoryProductRepository) Create(ctx context.Context, product *domain.Product) error {
 	select {
 	case <-ctx.Done():
@@ -30,14 +47,23 @@ func (r *memoryProductRepository) Create(ctx context.Context, product *domain.Pr
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.products[product.ID]; exists {
-		return errors.New("product already exists")
+	write := repository.ProductWrite{Product: product, Create: true}
+	products := r.products(ctx, true)
+	if err := checkWrite(products, write); err != nil {
+		return err
 	}
-
-	r.products[product.ID] = product
+	applyWrite(products, write)
 	return nil
 }
 
+// cloneProduct hands out a private copy, so callers never share the stored
+// product and a stale copy cannot slip past the version check in Update.
+func cloneProduct(p *domain.Product) *domain.Product {
+	c := *p
+	c.Tags = slices.Clone(p.Tags)
+	return &c
+}
+
 func (r *memoryProductRepository) FindByID(ctx context.Context, id string) (*domain.Product, error) {
 	select {
 	case <-ctx.Done():
@@ -48,12 +74,30 @@ func (r *memoryProductRepository) FindByID(ctx context.Context, id string) (*dom
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	product, exists := r.products[id]
-	if !exists {
-		return nil, errors.New("product not found")
+	product, exists := r.products(ctx, false)[id]
+	if !exists || product.IsDeleted() {
+		return nil, domain.ErrProductNotFound
+	}
+
+	return cloneProduct(product), nil
+}
+
+func (r *memoryProductRepository) FindDeletedByID(ctx context.Context, id string) (*domain.Product, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
 	}
 
-	return product, nil
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	product, exists := r.products(ctx, false)[id]
+	if !exists || !product.IsDeleted() {
+		return nil, domain.ErrProductNotFound
+	}
+
+	return cloneProduct(product), nil
 }
 
 func (r *memoryProductRepository) FindAllByUserID(ctx context.Context, userID string) ([]*domain.Product, error) {
@@ -67,14 +111,146 @@ func (r *memoryProductRepository) FindAllByUserID(ctx context.Context, userID st
 	defer r.mu.RUnlock()
 
 	var products []*domain.Product
-	for _, p := range r.products {
-		if p.UserID == userID {
-			products = append(products, p)
+	for _, p := range r.products(ctx, false) {
+		if p.UserID == userID && !p.IsDeleted() {
+			products = append(products, cloneProduct(p))
 		}
 	}
 	return products, nil
 }
 
+func (r *memoryProductRepository) CountByUserID(ctx context.Context, userID string) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, p := range r.products(ctx, false) {
+		if p.UserID == userID && !p.IsDeleted() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryProductRepository) FindPageByUserID(ctx context.Context, userID string, page repository.PageRequest) (*repository.ProductPage, error) {
+	return r.List(ctx, repository.ProductQuery{OwnerID: userID, PageRequest: page})
+}
+
+func (r *memoryProductRepository) List(ctx context.Context, query repository.ProductQuery) (*repository.ProductPage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	query.Normalize()
+	after, err := repository.DecodeCursor(query.Cursor, string(query.Sort), query.Descending)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var products []*domain.Product
+	for _, p := range r.products(ctx, false) {
+		if !p.IsDeleted() && matchesProductQuery(p, query) {
+			products = append(products, cloneProduct(p))
+		}
+	}
+
+	compare := func(p *domain.Product, value any, id string) int {
+		c := compareSortValues(productSortValue(p, query.Sort), value)
+		if c == 0 {
+			c = strings.Compare(p.ID, id)
+		}
+		if query.Descending {
+			c = -c
+		}
+		return c
+	}
+
+	slices.SortFunc(products, func(a, b *domain.Product) int {
+		return compare(a, productSortValue(b, query.Sort), b.ID)
+	})
+
+	if after != nil {
+		value, err := cursorSortValue(after, query.Sort != repository.ProductSortName)
+		if err != nil {
+			return nil, err
+		}
+		start := slices.IndexFunc(products, func(p *domain.Product) bool {
+			return compare(p, value, after.ID) > 0
+		})
+		if start < 0 {
+			start = len(products)
+		}
+		products = products[start:]
+	}
+
+	page := &repository.ProductPage{}
+	if len(products) > query.Limit {
+		products = products[:query.Limit]
+		last := products[len(products)-1]
+		page.NextCursor = repository.EncodeCursor(repository.Cursor{
+			Sort:       string(query.Sort),
+			Descending: query.Descending,
+			Value:      productSortValue(last, query.Sort),
+			ID:         last.ID,
+		})
+	}
+	page.Products = products
+
+	return page, nil
+}
+
+func matchesProductQuery(p *domain.Product, q repository.ProductQuery) bool {
+	if q.OwnerID != "" && p.UserID != q.OwnerID {
+		return false
+	}
+	if q.MinPrice != nil && p.Price.Amount() < int64(*q.MinPrice) {
+		return false
+	}
+	if q.MaxPrice != nil && p.Price.Amount() > int64(*q.MaxPrice) {
+		return false
+	}
+	if q.Currency != "" && p.Price.Currency() != q.Currency {
+		return false
+	}
+	if len(q.CategoryIDs) > 0 && !slices.Contains(q.CategoryIDs, p.CategoryID) {
+		return false
+	}
+	if q.Tag != "" && !slices.Contains(p.Tags, q.Tag) {
+		return false
+	}
+	if q.InStock && p.Stock <= 0 {
+		return false
+	}
+	if q.CreatedAfter != nil && !p.CreatedAt.After(*q.CreatedAfter) {
+		return false
+	}
+	return true
+}
+
+// productSortValue mirrors the Couchbase driver, which compares timestamps
+// at millisecond precision.
+func productSortValue(p *domain.Product, sort repository.ProductSort) any {
+	switch sort {
+	case repository.ProductSortPrice:
+		return p.Price.Amount()
+	case repository.ProductSortName:
+		return p.Name
+	default:
+		return p.CreatedAt.UnixMilli()
+	}
+}
+
 func (r *memoryProductRepository) Update(ctx context.Context, product *domain.Product) error {
 	select {
 	case <-ctx.Done():
@@ -85,14 +261,109 @@ func (r *memoryProductRepository) Update(ctx context.Context, product *domain.Pr
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.products[product.ID]; !exists {
-		return errors.New("product not found")
+	write := repository.ProductWrite{Product: product}
+	products := r.products(ctx, false)
+	if err := checkWrite(products, write); err != nil {
+		return err
+	}
+	applyWrite(products, write)
+	return nil
+}
+
+func (r *memoryProductRepository) WriteBatch(ctx context.Context, writes []repository.ProductWrite) []error {
+	errs := make([]error, len(writes))
+	for i, w := range writes {
+		if w.Create {
+			errs[i] = r.Create(ctx, w.Product)
+		} else {
+			errs[i] = r.Update(ctx, w.Product)
+		}
+	}
+	return errs
+}
+
+// WriteBatchAtomic checks every write before applying any, all under one
+// write lock, so no reader sees half a batch.
+func (r *memoryProductRepository) WriteBatchAtomic(ctx context.Context, writes []repository.ProductWrite) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	products := r.products(ctx, true)
+	for i, w := range writes {
+		if err := checkWrite(products, w); err != nil {
+			return &repository.BatchWriteError{Index: i, Err: err}
+		}
+	}
+	for _, w := range writes {
+		applyWrite(products, w)
 	}
+	return nil
+}
 
-	r.products[product.ID] = product
+// checkWrite reports whether the write can be applied. Callers hold r.mu.
+func checkWrite(products map[string]*domain.Product, w repository.ProductWrite) error {
+	current, exists := products[w.Product.ID]
+	if w.Create {
+		if exists {
+			return errors.New("product already exists")
+		}
+		return nil
+	}
+	if !exists {
+		return domain.ErrProductNotFound
+	}
+	if current.Version != w.Product.Version {
+		return domain.ErrVersionMismatch
+	}
 	return nil
 }
 
+// applyWrite stores a copy of a checked write and hands its new version back.
+// Callers hold r.mu.
+func applyWrite(products map[string]*domain.Product, w repository.ProductWrite) {
+	stored := cloneProduct(w.Product)
+	stored.Version++
+	if w.Create {
+		stored.Version = 1
+	}
+	products[stored.ID] = stored
+	w.Product.Version = stored.Version
+}
+
+// AdjustStock checks and writes the stock under the write lock, so it needs
+// no version from the caller.
+func (r *memoryProductRepository) AdjustStock(ctx context.Context, id string, delta int) (*domain.Product, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	products := r.products(ctx, false)
+	current, exists := products[id]
+	if !exists || current.IsDeleted() {
+		return nil, domain.ErrProductNotFound
+	}
+
+	updated := *current
+	if err := updated.AdjustStock(delta, time.Now()); err != nil {
+		return nil, err
+	}
+	updated.Version++
+
+	products[id] = &updated
+	return cloneProduct(&updated), nil
+}
+
 func (r *memoryProductRepository) Delete(ctx context.Context, id string) error {
 	select {
 	case <-ctx.Done():
@@ -103,10 +374,93 @@ func (r *memoryProductRepository) Delete(ctx context.Context, id string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.products[id]; !exists {
-		return errors.New("product not found")
+	products := r.products(ctx, false)
+	if _, exists := products[id]; !exists {
+		return domain.ErrProductNotFound
+	}
+
+	delete(products, id)
+	return nil
+}
+
+func (r *memoryProductRepository) SoftDeleteAllByUserID(ctx context.Context, userID string, deletedAt time.Time) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range r.products(ctx, false) {
+		if p.UserID == userID && !p.IsDeleted() {
+			p.SoftDelete(deletedAt)
+			p.Version++
+		}
+	}
+	return nil
+}
+
+func (r *memoryProductRepository) RestoreAllByUserID(ctx context.Context, userID string, deletedAt time.Time) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
 	}
 
-	delete(r.products, id)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, p := range r.products(ctx, false) {
+		if p.UserID == userID && p.IsDeleted() && p.DeletedAt.Equal(deletedAt) {
+			p.DeletedAt = nil
+			p.UpdatedAt = now
+			p.Version++
+		}
+	}
 	return nil
 }
+
+func (r *memoryProductRepository) DeleteAllByUserID(ctx context.Context, userID string) ([]string, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ids []string
+	products := r.products(ctx, false)
+	for id, p := range products {
+		if p.UserID == userID {
+			delete(products, id)
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *memoryProductRepository) DeleteAllDeletedBefore(ctx context.Context, cutoff time.Time) ([]string, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ids []string
+	products := r.products(ctx, false)
+	for id, p := range products {
+		if p.IsDeleted() && p.DeletedAt.Before(cutoff) {
+			delete(products, id)
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
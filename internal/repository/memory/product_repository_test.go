@@ -0,0 +1,180 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+)
+
+func seedProducts(t *testing.T, repo repository.ProductRepository, products ...*domain.Product) {
+	t.Helper()
+	for _, p := range products {
+		if err := repo.Create(context.Background(), p); err != nil {
+			t.Fatalf("Create %s: %v", p.ID, err)
+		}
+	}
+}
+
+func productAt(t *testing.T, id, ownerID, name string, amount int64, createdAt time.Time) *domain.Product {
+	t.Helper()
+	price, err := domain.NewMoney(amount, "USD")
+	if err != nil {
+		t.Fatalf("NewMoney: %v", err)
+	}
+	return domain.ReconstituteProduct(id, ownerID, name, price, 1, "", nil, createdAt, createdAt, nil, 0)
+}
+
+// listAllProducts follows NextCursor to the end and returns the IDs in order.
+func listAllProducts(t *testing.T, repo repository.ProductRepository, query repository.ProductQuery) []string {
+	t.Helper()
+	var ids []string
+	for {
+		page, err := repo.List(context.Background(), query)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(page.Products) > query.Limit {
+			t.Fatalf("expected at most %d products, got %d", query.Limit, len(page.Products))
+		}
+		for _, p := range page.Products {
+			ids = append(ids, p.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func TestProductRepository_ListPagesThroughTies(t *testing.T) {
+	repo := NewProductRepository()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	// Five products share the timestamp, name and price; IDs break the tie.
+	seedProducts(t, repo,
+		productAt(t, "d", "ada", "Lamp", 500, at),
+		productAt(t, "b", "ada", "Lamp", 500, at),
+		productAt(t, "e", "ada", "Lamp", 500, at),
+		productAt(t, "a", "ada", "Lamp", 500, at),
+		productAt(t, "c", "ada", "Lamp", 500, at),
+		productAt(t, "z", "ada", "Chair", 900, at.Add(-time.Second)),
+		productAt(t, "y", "ada", "Table", 100, at.Add(time.Second)),
+	)
+
+	tests := []struct {
+		sort       repository.ProductSort
+		descending bool
+		want       []string
+	}{
+		{repository.ProductSortCreatedAt, false, []string{"z", "a", "b", "c", "d", "e", "y"}},
+		{repository.ProductSortCreatedAt, true, []string{"y", "e", "d", "c", "b", "a", "z"}},
+		{repository.ProductSortName, false, []string{"z", "a", "b", "c", "d", "e", "y"}},
+		{repository.ProductSortPrice, false, []string{"y", "a", "b", "c", "d", "e", "z"}},
+		{repository.ProductSortPrice, true, []string{"z", "e", "d", "c", "b", "a", "y"}},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 3, 7} {
+			query := repository.ProductQuery{Sort: tt.sort, Descending: tt.descending, Currency: "USD"}
+			query.Limit = limit
+			got := listAllProducts(t, repo, query)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("sort %s descending=%v limit %d: expected %v, got %v", tt.sort, tt.descending, limit, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestProductRepository_FindPageByUserID(t *testing.T) {
+	ctx := context.Background()
+	repo := NewProductRepository()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	seedProducts(t, repo,
+		productAt(t, "b", "ada", "Lamp", 500, at),
+		productAt(t, "a", "ada", "Lamp", 500, at),
+		productAt(t, "c", "grace", "Lamp", 500, at),
+		productAt(t, "d", "ada", "Lamp", 500, at.Add(time.Second)),
+	)
+
+	var got []string
+	page := repository.PageRequest{Limit: 1}
+	for {
+		result, err := repo.FindPageByUserID(ctx, "ada", page)
+		if err != nil {
+			t.Fatalf("FindPageByUserID: %v", err)
+		}
+		for _, p := range result.Products {
+			got = append(got, p.ID)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		page.Cursor = result.NextCursor
+	}
+	if want := []string{"a", "b", "d"}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestProductRepository_ListTruncatesToMilliseconds(t *testing.T) {
+	repo := NewProductRepository()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	// a and b fall in the same millisecond, so they tie despite b being older.
+	seedProducts(t, repo,
+		productAt(t, "a", "ada", "Lamp", 500, at.Add(900*time.Microsecond)),
+		productAt(t, "b", "ada", "Lamp", 500, at.Add(100*time.Microsecond)),
+		productAt(t, "c", "ada", "Lamp", 500, at.Add(time.Millisecond)),
+	)
+
+	for _, limit := range []int{1, 2, 3} {
+		query := repository.ProductQuery{}
+		query.Limit = limit
+		got := listAllProducts(t, repo, query)
+		if want := []string{"a", "b", "c"}; !slices.Equal(got, want) {
+			t.Fatalf("limit %d: expected %v, got %v", limit, want, got)
+		}
+	}
+}
+
+func TestProductRepository_ListRejectsForeignCursors(t *testing.T) {
+	ctx := context.Background()
+	repo := NewProductRepository()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	seedProducts(t, repo,
+		productAt(t, "a", "ada", "Lamp", 500, at),
+		productAt(t, "b", "ada", "Chair", 900, at.Add(time.Second)),
+	)
+
+	first := repository.ProductQuery{}
+	first.Limit = 1
+	page, err := repo.List(ctx, first)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if page.NextCursor == "" {
+		t.Fatal("expected a next cursor")
+	}
+
+	tests := []struct {
+		name   string
+		query  repository.ProductQuery
+		cursor string
+	}{
+		{"another sort", repository.ProductQuery{Sort: repository.ProductSortName}, page.NextCursor},
+		{"another direction", repository.ProductQuery{Descending: true}, page.NextCursor},
+		{"malformed", repository.ProductQuery{}, "not-a-cursor"},
+		{"value of the wrong type", repository.ProductQuery{Sort: repository.ProductSortPrice, Currency: "USD"},
+			repository.EncodeCursor(repository.Cursor{Sort: string(repository.ProductSortPrice), Value: "cheap", ID: "a"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Cursor = tt.cursor
+			if _, err := repo.List(ctx, tt.query); !errors.Is(err, repository.ErrInvalidCursor) {
+				t.Fatalf("expected ErrInvalidCursor, got %v", err)
+			}
+		})
+	}
+}
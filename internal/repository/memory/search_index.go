@@ -0,0 +1,254 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+)
+
+type memorySearchIndex struct {
+	tenants map[string]*invertedIndex
+	mu      sync.RWMutex
+}
+
+// invertedIndex holds one tenant's products. postings maps a term to the
+// products containing it and how often; terms remembers each product's terms
+// so it can be unindexed.
+type invertedIndex struct {
+	postings map[string]map[string]int
+	terms    map[string][]string
+}
+
+// NewSearchIndex returns an in-process inverted index over product names. It
+// lives and dies with the process, so it suits the memory driver.
+func NewSearchIndex() repository.SearchIndex {
+	return &memorySearchIndex{
+		tenants: make(map[string]*invertedIndex),
+	}
+}
+
+// index returns the tenant's index, creating it when asked to. Callers hold
+// s.mu; readers get nil for unknown tenants.
+func (s *memorySearchIndex) index(ctx context.Context, create bool) *invertedIndex {
+	id := tenant.FromContext(ctx)
+	idx, ok := s.tenants[id]
+	if !ok && create {
+		idx = &invertedIndex{
+			postings: make(map[string]map[string]int),
+			terms:    make(map[string][]string),
+		}
+		s.tenants[id] = idx
+	}
+	return idx
+}
+
+func (s *memorySearchIndex) Index(ctx context.Context, product *domain.Product) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.index(ctx, true)
+	idx.remove(product.ID)
+
+	terms := tokenize(product.Name)
+	for _, term := range terms {
+		docs, ok := idx.postings[term]
+		if !ok {
+			docs = make(map[string]int)
+			idx.postings[term] = docs
+		}
+		docs[product.ID]++
+	}
+	idx.terms[product.ID] = terms
+	return nil
+}
+
+func (s *memorySearchIndex) Remove(ctx context.Context, id string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if idx := s.index(ctx, false); idx != nil {
+		idx.remove(id)
+	}
+	return nil
+}
+
+func (idx *invertedIndex) remove(id string) {
+	for _, term := range idx.terms[id] {
+		docs := idx.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.terms, id)
+}
+
+// Search scores each product per query word by its best-matching name term,
+// weighted by how rare that term is, and sums over the query words. Products
+// matching more of the query therefore rank first.
+func (s *memorySearchIndex) Search(ctx context.Context, query string, limit int) ([]repository.SearchHit, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	words := tokenize(query)
+	slices.Sort(words)
+	words = slices.Compact(words)
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	idx := s.index(ctx, false)
+	if idx == nil {
+		return nil, nil
+	}
+
+	total := float64(len(idx.terms))
+	scores := make(map[string]float64)
+	for _, word := range words {
+		best := make(map[string]float64)
+		for term, docs := range idx.postings {
+			weight := termWeight(word, term)
+			if weight == 0 {
+				continue
+			}
+			idf := math.Log(1 + total/float64(len(docs)))
+			for id, freq := range docs {
+				if score := weight * idf * (1 + math.Log(float64(freq))); score > best[id] {
+					best[id] = score
+				}
+			}
+		}
+		for id, score := range best {
+			scores[id] += score
+		}
+	}
+
+	hits := make([]repository.SearchHit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, repository.SearchHit{ID: id, Score: score})
+	}
+	slices.SortFunc(hits, func(a, b repository.SearchHit) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits, nil
+}
+
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// termWeight rates how well an indexed term answers a query word: exact
+// matches count fully, prefixes of the term (search as you type) a little
+// less, and typos less still. Zero means no match.
+func termWeight(word, term string) float64 {
+	if term == word {
+		return 1
+	}
+	if strings.HasPrefix(term, word) {
+		return 0.8
+	}
+
+	edits := allowedEdits(word)
+	if edits == 0 {
+		return 0
+	}
+	if d := editDistance(word, term, edits); d <= edits {
+		return 0.6 / float64(d)
+	}
+
+	// A misspelt prefix: compare against the start of the term.
+	wordRunes, termRunes := []rune(word), []rune(term)
+	if len(termRunes) > len(wordRunes) {
+		if d := editDistance(word, string(termRunes[:len(wordRunes)]), edits); d <= edits {
+			return 0.4 / float64(d)
+		}
+	}
+	return 0
+}
+
+// allowedEdits grows the typo budget with the word length; short words
+// would otherwise match almost anything.
+func allowedEdits(word string) int {
+	switch n := len([]rune(word)); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// editDistance returns the optimal string alignment distance between a and
+// b, which counts swapping two adjacent letters as one edit, or bound+1 as
+// soon as the distance is certain to exceed bound.
+func editDistance(a, b string, bound int) int {
+	ar, br := []rune(a), []rune(b)
+	if diff := len(ar) - len(br); diff > bound || -diff > bound {
+		return bound + 1
+	}
+
+	// Three rolling rows: two back for transpositions, the previous one and
+	// the current one.
+	prev2 := make([]int, len(br)+1)
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+		rowMin := i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ar[i-1] == br[j-2] && ar[i-2] == br[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > bound {
+			return bound + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(br)]
+}
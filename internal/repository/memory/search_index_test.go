@@ -0,0 +1,159 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		bound int
+		want  int
+	}{
+		{"lamp", "lamp", 2, 0},
+		{"lamp", "lump", 2, 1},
+		{"lamp", "lam", 2, 1},
+		{"lamp", "clamp", 2, 1},
+		// Swapping adjacent letters is a single edit, not two.
+		{"lamp", "lapm", 2, 1},
+		{"keyboard", "kyeboadr", 2, 2},
+		{"kitten", "sitting", 3, 3},
+		{"naïve", "naive", 2, 1},
+		// Beyond the bound, the answer is only ever bound+1.
+		{"kitten", "sitting", 2, 3},
+		{"lamp", "chandelier", 2, 3},
+		{"abcdef", "badcfe", 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := editDistance(tt.a, tt.b, tt.bound); got != tt.want {
+				t.Fatalf("editDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.bound, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTermWeight(t *testing.T) {
+	tests := []struct {
+		word, term string
+		want       float64
+	}{
+		{"keyboard", "keyboard", 1},
+		{"key", "keyboard", 0.8},
+		{"kayboard", "keyboard", 0.6},
+		{"kaybaord", "keyboard", 0.3},
+		{"keyb0", "keyboard", 0.4},
+		// Short words get no typo budget.
+		{"kex", "key", 0},
+		{"desk", "lamp", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.word+"/"+tt.term, func(t *testing.T) {
+			if got := termWeight(tt.word, tt.term); got != tt.want {
+				t.Fatalf("termWeight(%q, %q) = %v, want %v", tt.word, tt.term, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	ctx := context.Background()
+
+	index := func(t *testing.T, ctx context.Context, idx repository.SearchIndex, id, name string) {
+		t.Helper()
+		product, err := domain.NewProduct(id, "owner", name, domain.ReconstituteMoney(100, "USD"), 1)
+		if err != nil {
+			t.Fatalf("NewProduct: %v", err)
+		}
+		if err := idx.Index(ctx, product); err != nil {
+			t.Fatalf("Index: %v", err)
+		}
+	}
+	search := func(t *testing.T, ctx context.Context, idx repository.SearchIndex, query string) []string {
+		t.Helper()
+		hits, err := idx.Search(ctx, query, 10)
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		var ids []string
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		return ids
+	}
+
+	t.Run("exact beats prefix beats typo", func(t *testing.T) {
+		idx := NewSearchIndex()
+		index(t, ctx, idx, "typo", "Lamb Shade")
+		index(t, ctx, idx, "prefix", "Lamplight")
+		index(t, ctx, idx, "exact", "Lamp")
+		index(t, ctx, idx, "other", "Desk")
+
+		if got, want := search(t, ctx, idx, "lamp"), []string{"exact", "prefix", "typo"}; !slices.Equal(got, want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("more matching words rank first", func(t *testing.T) {
+		idx := NewSearchIndex()
+		index(t, ctx, idx, "one", "Oak Desk")
+		index(t, ctx, idx, "both", "Oak Desk Lamp")
+
+		if got := search(t, ctx, idx, "oak lamp"); len(got) != 2 || got[0] != "both" {
+			t.Fatalf("expected the product matching both words first, got %v", got)
+		}
+	})
+
+	t.Run("tenants do not see each other", func(t *testing.T) {
+		idx := NewSearchIndex()
+		acme := tenant.WithTenant(ctx, "acme")
+		index(t, ctx, idx, "ours", "Lamp")
+		index(t, acme, idx, "theirs", "Lamp")
+
+		if got := search(t, ctx, idx, "lamp"); !slices.Equal(got, []string{"ours"}) {
+			t.Fatalf("expected only the default tenant's product, got %v", got)
+		}
+		if got := search(t, acme, idx, "lamp"); !slices.Equal(got, []string{"theirs"}) {
+			t.Fatalf("expected only acme's product, got %v", got)
+		}
+		if got := search(t, tenant.WithTenant(ctx, "globex"), idx, "lamp"); len(got) != 0 {
+			t.Fatalf("expected nothing for a tenant without products, got %v", got)
+		}
+
+		// Removing an ID in one tenant leaves the other's alone.
+		if err := idx.Remove(acme, "ours"); err != nil {
+			t.Fatalf("Remove: %v", err)
+		}
+		if got := search(t, ctx, idx, "lamp"); !slices.Equal(got, []string{"ours"}) {
+			t.Fatalf("expected the default tenant's product to stay, got %v", got)
+		}
+	})
+
+	t.Run("remove and reindex", func(t *testing.T) {
+		idx := NewSearchIndex()
+		index(t, ctx, idx, "p1", "Lamp")
+		index(t, ctx, idx, "p2", "Lamp")
+
+		if err := idx.Remove(ctx, "p1"); err != nil {
+			t.Fatalf("Remove: %v", err)
+		}
+		if got := search(t, ctx, idx, "lamp"); !slices.Equal(got, []string{"p2"}) {
+			t.Fatalf("expected only p2 after removing p1, got %v", got)
+		}
+
+		// Renaming drops the old terms.
+		index(t, ctx, idx, "p2", "Desk")
+		if got := search(t, ctx, idx, "lamp"); len(got) != 0 {
+			t.Fatalf("expected no hits for the old name, got %v", got)
+		}
+		if got := search(t, ctx, idx, "desk"); !slices.Equal(got, []string{"p2"}) {
+			t.Fatalf("expected p2 under its new name, got %v", got)
+		}
+	})
+}
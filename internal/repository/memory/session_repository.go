@@ -0,0 +1,127 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+)
+
+type memorySessionRepository struct {
+	// tenants maps a tenant to its sessions by ID.
+	tenants map[string]map[string]*domain.Session
+	mu      sync.RWMutex
+}
+
+func NewSessionRepository() repository.SessionRepository {
+	return &memorySessionRepository{
+		tenants: make(map[string]map[string]*domain.Session),
+	}
+}
+
+// sessions returns the tenant's sessions, creating the map when asked to.
+// Callers hold r.mu.
+func (r *memorySessionRepository) sessions(ctx context.Context, create bool) map[string]*domain.Session {
+	id := tenant.FromContext(ctx)
+	sessions, ok := r.tenants[id]
+	if !ok && create {
+		sessions = make(map[string]*domain.Session)
+		r.tenants[id] = sessions
+	}
+	return sessions
+}
+
+func (r *memorySessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sessions := r.sessions(ctx, true)
+	if _, exists := sessions[session.ID]; exists {
+		return errors.New("session already exists")
+	}
+
+	session.Version = 1
+	sessions[session.ID] = cloneSession(session)
+	return nil
+}
+
+func cloneSession(s *domain.Session) *domain.Session {
+	copied := *s
+	copied.PreviousTokenHashes = slices.Clone(s.PreviousTokenHashes)
+	if s.RevokedAt != nil {
+		revokedAt := *s.RevokedAt
+		copied.RevokedAt = &revokedAt
+	}
+	return &copied
+}
+
+func (r *memorySessionRepository) FindByID(ctx context.Context, id string) (*domain.Session, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, exists := r.sessions(ctx, false)[id]
+	if !exists {
+		return nil, domain.ErrSessionNotFound
+	}
+
+	return cloneSession(session), nil
+}
+
+func (r *memorySessionRepository) Update(ctx context.Context, session *domain.Session) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sessions := r.sessions(ctx, false)
+	current, exists := sessions[session.ID]
+	if !exists {
+		return domain.ErrSessionNotFound
+	}
+	if current.Version != session.Version {
+		return domain.ErrVersionMismatch
+	}
+
+	session.Version++
+	sessions[session.ID] = cloneSession(session)
+	return nil
+}
+
+func (r *memorySessionRepository) RevokeAllByUserID(ctx context.Context, userID string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range r.sessions(ctx, false) {
+		if s.UserID == userID && s.RevokedAt == nil {
+			s.Revoke()
+			s.Version++
+		}
+	}
+	return nil
+}
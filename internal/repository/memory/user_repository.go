@@ -1,25 +1,57 @@
 package memory
 
 import (
+	"cmp"
 	"context"
 	"errors"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/yusirdemir/microservice/internal/domain"
 	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
 )
 
 type memoryUserRepository struct {
-	users map[string]*domain.User
-	mu    sync.RWMutex
+	tenants map[string]*userPartition
+	mu      sync.RWMutex
+}
+
+// userPartition holds one tenant's users. emails maps an address to the
+// owning user ID and emailOf is its reverse, so a changed address can be
+// released on Update.
+type userPartition struct {
+	users   map[string]*domain.User
+	emails  map[string]string
+	emailOf map[string]string
 }
 
 func NewUserRepository() repository.UserRepository {
 	return &memoryUserRepository{
-		users: make(map[string]*domain.User),
+		tenants: make(map[string]*userPartition),
 	}
 }
 
+// partition returns the tenant's users, creating the partition when asked
+// to. Callers hold r.mu; readers get an empty partition for unknown tenants.
+func (r *memoryUserRepository) partition(ctx context.Context, create bool) *userPartition {
+	id := tenant.FromContext(ctx)
+	p, ok := r.tenants[id]
+	if !ok {
+		p = &userPartition{
+			users:   make(map[string]*domain.User),
+			emails:  make(map[string]string),
+			emailOf: make(map[string]string),
+		}
+		if create {
+			r.tenants[id] = p
+		}
+	}
+	return p
+}
+
 func (r *memoryUserRepository) Create(ctx context.Context, user *domain.User) error {
 	select {
 	case <-ctx.Done():
@@ -30,14 +62,30 @@ func (r *memoryUserRepository) Create(ctx context.Context, user *domain.User) er
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.users[user.ID()]; exists {
+	p := r.partition(ctx, true)
+	if _, exists := p.users[user.ID()]; exists {
 		return errors.New("user already exists")
 	}
+	if _, taken := p.emails[user.Email()]; taken {
+		return domain.ErrEmailTaken
+	}
 
-	r.users[user.ID()] = user
+	stored := *user
+	stored.SetVersion(1)
+	p.users[user.ID()] = &stored
+	p.emails[user.Email()] = user.ID()
+	p.emailOf[user.ID()] = user.Email()
+	user.SetVersion(stored.Version())
 	return nil
 }
 
+// cloneUser hands out a private copy, so callers never share the stored user
+// and a stale copy cannot slip past the version check in Update.
+func cloneUser(u *domain.User) *domain.User {
+	c := *u
+	return &c
+}
+
 func (r *memoryUserRepository) FindByID(ctx context.Context, id string) (*domain.User, error) {
 	select {
 	case <-ctx.Done():
@@ -48,12 +96,68 @@ func (r *memoryUserRepository) FindByID(ctx context.Context, id string) (*domain
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	user, exists := r.users[id]
-	if !exists {
-		return nil, errors.New("user not found")
+	user, exists := r.partition(ctx, false).users[id]
+	if !exists || user.IsDeleted() {
+		return nil, domain.ErrUserNotFound
+	}
+
+	return cloneUser(user), nil
+}
+
+func (r *memoryUserRepository) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
 	}
 
-	return user, nil
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p := r.partition(ctx, false)
+	id, exists := p.emails[email]
+	if !exists || p.users[id].IsDeleted() {
+		return nil, domain.ErrUserNotFound
+	}
+
+	return cloneUser(p.users[id]), nil
+}
+
+func (r *memoryUserRepository) FindDeletedByID(ctx context.Context, id string) (*domain.User, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, exists := r.partition(ctx, false).users[id]
+	if !exists || !user.IsDeleted() {
+		return nil, domain.ErrUserNotFound
+	}
+
+	return cloneUser(user), nil
+}
+
+func (r *memoryUserRepository) FindAllDeletedBefore(ctx context.Context, cutoff time.Time) ([]*domain.User, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var users []*domain.User
+	for _, u := range r.partition(ctx, false).users {
+		if u.IsDeleted() && u.DeletedAt().Before(cutoff) {
+			users = append(users, cloneUser(u))
+		}
+	}
+	return users, nil
 }
 
 func (r *memoryUserRepository) Update(ctx context.Context, user *domain.User) error {
@@ -66,11 +170,25 @@ func (r *memoryUserRepository) Update(ctx context.Context, user *domain.User) er
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.users[user.ID()]; !exists {
-		return errors.New("user not found")
+	p := r.partition(ctx, false)
+	current, exists := p.users[user.ID()]
+	if !exists {
+		return domain.ErrUserNotFound
+	}
+	if current.Version() != user.Version() {
+		return domain.ErrVersionMismatch
+	}
+	if ownerID, taken := p.emails[user.Email()]; taken && ownerID != user.ID() {
+		return domain.ErrEmailTaken
 	}
 
-	r.users[user.ID()] = user
+	stored := *user
+	stored.SetVersion(user.Version() + 1)
+	delete(p.emails, p.emailOf[user.ID()])
+	p.users[user.ID()] = &stored
+	p.emails[user.Email()] = user.ID()
+	p.emailOf[user.ID()] = user.Email()
+	user.SetVersion(stored.Version())
 	return nil
 }
 
@@ -84,10 +202,147 @@ func (r *memoryUserRepository) Delete(ctx context.Context, id string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.users[id]; !exists {
-		return errors.New("user not found")
+	p := r.partition(ctx, false)
+	if _, exists := p.users[id]; !exists {
+		return domain.ErrUserNotFound
 	}
 
-	delete(r.users, id)
+	delete(p.emails, p.emailOf[id])
+	delete(p.emailOf, id)
+	delete(p.users, id)
 	return nil
 }
+
+func (r *memoryUserRepository) List(ctx context.Context, query repository.UserQuery) (*repository.UserPage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	query.Normalize()
+	after, err := repository.DecodeCursor(query.Cursor, string(query.Sort), query.Descending)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var users []*domain.User
+	for _, u := range r.partition(ctx, false).users {
+		if !u.IsDeleted() && matchesUserQuery(u, query) {
+			users = append(users, cloneUser(u))
+		}
+	}
+
+	compare := func(a *domain.User, value any, id string) int {
+		c := compareSortValues(userSortValue(a, query.Sort), value)
+		if c == 0 {
+			c = strings.Compare(a.ID(), id)
+		}
+		if query.Descending {
+			c = -c
+		}
+		return c
+	}
+
+	slices.SortFunc(users, func(a, b *domain.User) int {
+		return compare(a, userSortValue(b, query.Sort), b.ID())
+	})
+
+	if after != nil {
+		value, err := cursorSortValue(after, query.Sort == repository.UserSortCreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		start := slices.IndexFunc(users, func(u *domain.User) bool {
+			return compare(u, value, after.ID) > 0
+		})
+		if start < 0 {
+			start = len(users)
+		}
+		users = users[start:]
+	}
+
+	page := &repository.UserPage{}
+	if len(users) > query.Limit {
+		users = users[:query.Limit]
+		last := users[len(users)-1]
+		page.NextCursor = repository.EncodeCursor(repository.Cursor{
+			Sort:       string(query.Sort),
+			Descending: query.Descending,
+			Value:      userSortValue(last, query.Sort),
+			ID:         last.ID(),
+		})
+	}
+	page.Users = users
+
+	return page, nil
+}
+
+func (r *memoryUserRepository) ListTenants(ctx context.Context) ([]string, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tenants []string
+	for id, p := range r.tenants {
+		if len(p.users) > 0 {
+			tenants = append(tenants, id)
+		}
+	}
+	return tenants, nil
+}
+
+func matchesUserQuery(u *domain.User, q repository.UserQuery) bool {
+	if q.NamePrefix != "" && !strings.HasPrefix(strings.ToLower(u.Name()), strings.ToLower(q.NamePrefix)) {
+		return false
+	}
+	if !strings.HasPrefix(u.Email(), q.EmailPrefix) {
+		return false
+	}
+	if q.CreatedFrom != nil && u.CreatedAt().Before(*q.CreatedFrom) {
+		return false
+	}
+	if q.CreatedTo != nil && !u.CreatedAt().Before(*q.CreatedTo) {
+		return false
+	}
+	return true
+}
+
+// userSortValue mirrors the Couchbase driver: timestamps sort at millisecond
+// precision so cursors mean the same thing in both.
+func userSortValue(u *domain.User, sort repository.UserSort) any {
+	switch sort {
+	case repository.UserSortName:
+		return u.Name()
+	case repository.UserSortEmail:
+		return u.Email()
+	default:
+		return u.CreatedAt().UnixMilli()
+	}
+}
+
+func compareSortValues(a, b any) int {
+	switch av := a.(type) {
+	case int64:
+		return cmp.Compare(av, b.(int64))
+	case string:
+		return strings.Compare(av, b.(string))
+	default:
+		return 0
+	}
+}
+
+func cursorSortValue(c *repository.Cursor, numeric bool) (any, error) {
+	if numeric {
+		return c.Int64()
+	}
+	return c.String()
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+)
+
+func seedUsers(t *testing.T, repo repository.UserRepository, users ...*domain.User) {
+	t.Helper()
+	for _, u := range users {
+		if err := repo.Create(context.Background(), u); err != nil {
+			t.Fatalf("Create %s: %v", u.ID(), err)
+		}
+	}
+}
+
+func userAt(id, name string, createdAt time.Time) *domain.User {
+	return domain.Reconstitute(id, name, id+"@example.com", "hash", nil, createdAt, createdAt, nil, 0)
+}
+
+// listAllUsers follows NextCursor to the end and returns the IDs in order.
+func listAllUsers(t *testing.T, repo repository.UserRepository, query repository.UserQuery) []string {
+	t.Helper()
+	var ids []string
+	for {
+		page, err := repo.List(context.Background(), query)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(page.Users) > query.Limit {
+			t.Fatalf("expected at most %d users, got %d", query.Limit, len(page.Users))
+		}
+		for _, u := range page.Users {
+			ids = append(ids, u.ID())
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func TestUserRepository_ListPagesThroughTies(t *testing.T) {
+	repo := NewUserRepository()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	// Five users share both the timestamp and the name; IDs break the tie.
+	seedUsers(t, repo,
+		userAt("d", "Ada", at),
+		userAt("b", "Ada", at),
+		userAt("e", "Ada", at),
+		userAt("a", "Ada", at),
+		userAt("c", "Ada", at),
+		userAt("z", "Grace", at.Add(-time.Second)),
+		userAt("y", "Alan", at.Add(time.Second)),
+	)
+
+	tests := []struct {
+		sort       repository.UserSort
+		descending bool
+		want       []string
+	}{
+		{repository.UserSortCreatedAt, false, []string{"z", "a", "b", "c", "d", "e", "y"}},
+		{repository.UserSortCreatedAt, true, []string{"y", "e", "d", "c", "b", "a", "z"}},
+		{repository.UserSortName, false, []string{"a", "b", "c", "d", "e", "y", "z"}},
+		{repository.UserSortName, true, []string{"z", "y", "e", "d", "c", "b", "a"}},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 3, 7} {
+			got := listAllUsers(t, repo, repository.UserQuery{Sort: tt.sort, Descending: tt.descending, Limit: limit})
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("sort %s descending=%v limit %d: expected %v, got %v", tt.sort, tt.descending, limit, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestUserRepository_ListTruncatesToMilliseconds(t *testing.T) {
+	repo := NewUserRepository()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	// a and b fall in the same millisecond, so they tie despite b being older.
+	seedUsers(t, repo,
+		userAt("a", "Ada", at.Add(900*time.Microsecond)),
+		userAt("b", "Ada", at.Add(100*time.Microsecond)),
+		userAt("c", "Ada", at.Add(time.Millisecond)),
+	)
+
+	for _, limit := range []int{1, 2, 3} {
+		got := listAllUsers(t, repo, repository.UserQuery{Limit: limit})
+		if want := []string{"a", "b", "c"}; !slices.Equal(got, want) {
+			t.Fatalf("limit %d: expected %v, got %v", limit, want, got)
+		}
+	}
+}
+
+func TestUserRepository_ListRejectsForeignCursors(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	seedUsers(t, repo, userAt("a", "Ada", at), userAt("b", "Grace", at.Add(time.Second)))
+
+	page, err := repo.List(ctx, repository.UserQuery{Limit: 1})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if page.NextCursor == "" {
+		t.Fatal("expected a next cursor")
+	}
+
+	tests := []struct {
+		name  string
+		query repository.UserQuery
+	}{
+		{"another sort", repository.UserQuery{Sort: repository.UserSortName, Cursor: page.NextCursor}},
+		{"another direction", repository.UserQuery{Descending: true, Cursor: page.NextCursor}},
+		{"malformed", repository.UserQuery{Cursor: "not-a-cursor"}},
+		{"value of the wrong type", repository.UserQuery{Cursor: repository.EncodeCursor(repository.Cursor{Sort: string(repository.UserSortCreatedAt), Value: "yesterday", ID: "a"})}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := repo.List(ctx, tt.query); !errors.Is(err, repository.ErrInvalidCursor) {
+				t.Fatalf("expected ErrInvalidCursor, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+type OneTimeTokenRepository interface {
+	Create(ctx context.Context, token *domain.OneTimeToken) error
+	FindByID(ctx context.Context, id string) (*domain.OneTimeToken, error)
+	// MarkUsed stamps the token as used at usedAt, atomically with checking
+	// that it was unused; of several concurrent calls only one succeeds and
+	// the rest fail with domain.ErrTokenUsed.
+	MarkUsed(ctx context.Context, id string, usedAt time.Time) error
+	DeleteAllByUserID(ctx context.Context, userID string, purpose domain.TokenPurpose) error
+}
@@ -0,0 +1,32 @@
+package repository
+
+import "github.com/yusirdemir/microservice/internal/domain"
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// PageRequest asks for one page of a keyset-paginated listing. Cursor is the
+// NextCursor of the previous page, or empty for the first.
+type PageRequest struct {
+	Limit  int
+	Cursor string
+}
+
+// Normalize fills in the default page size and clamps it.
+func (p *PageRequest) Normalize() {
+	p.Limit = clampLimit(p.Limit)
+}
+
+func clampLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	return min(limit, MaxPageSize)
+}
+
+type ProductPage struct {
+	Products   []*domain.Product
+	NextCursor string
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+// ProductWrite is one write of a batch. Create inserts Product; otherwise it
+// replaces the stored product under the same version check as Update.
+type ProductWrite struct {
+	Product *domain.Product
+	Create  bool
+}
+
+// BatchWriteError names the write that made an atomic batch fail.
+type BatchWriteError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchWriteError) Error() string {
+	return fmt.Sprintf("write %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchWriteError) Unwrap() error {
+	return e.Err
+}
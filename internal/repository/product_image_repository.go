@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+// ProductImageRepository keeps image metadata for the tenant carried by ctx;
+// the files themselves live in a blob store.
+type ProductImageRepository interface {
+	// Create fails with domain.ErrImageLimitReached, storing nothing, when
+	// the product already has limit images. The check and the insert are
+	// atomic, so concurrent uploads cannot overshoot the limit.
+	Create(ctx context.Context, image *domain.ProductImage, limit int) error
+	FindByID(ctx context.Context, id string) (*domain.ProductImage, error)
+	// FindAllByProductID returns the product's images oldest first.
+	FindAllByProductID(ctx context.Context, productID string) ([]*domain.ProductImage, error)
+	Delete(ctx context.Context, id string) error
+	// DeleteAllByProductIDs removes every image of the given products and
+	// returns the removed records, whose files are left to the caller.
+	DeleteAllByProductIDs(ctx context.Context, productIDs []string) ([]*domain.ProductImage, error)
+}
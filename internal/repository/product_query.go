@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"errors"
+	"time"
+)
+
+type ProductSort string
+
+const (
+	ProductSortCreatedAt ProductSort = "created_at"
+	ProductSortPrice     ProductSort = "price"
+	ProductSortName      ProductSort = "name"
+)
+
+// ErrPriceWithoutCurrency rejects a query that compares prices across
+// currencies.
+var ErrPriceWithoutCurrency = errors.New("min_price, max_price and sort by price require currency")
+
+func ParseProductSort(s string) (ProductSort, error) {
+	switch sort := ProductSort(s); sort {
+	case ProductSortCreatedAt, ProductSortPrice, ProductSortName:
+		return sort, nil
+	default:
+		return "", errors.New("unknown sort field: " + s)
+	}
+}
+
+// ProductQuery selects live products page by page. Unset filters match
+// everything; the price bounds are inclusive and CreatedAfter is exclusive.
+// Price bounds and the price sort compare minor units, so they require
+// Currency; Validate refuses them without it.
+// CategoryIDs matches products in any of the listed categories; the service
+// expands a requested category to its whole subtree. Tag matches products
+// carrying that normalized tag.
+type ProductQuery struct {
+	MinPrice     *int
+	MaxPrice     *int
+	Currency     string
+	CategoryIDs  []string
+	Tag          string
+	InStock      bool
+	OwnerID      string
+	CreatedAfter *time.Time
+	Sort         ProductSort
+	Descending   bool
+	PageRequest
+}
+
+// Normalize fills in defaults and clamps the page size.
+func (q *ProductQuery) Normalize() {
+	if q.Sort == "" {
+		q.Sort = ProductSortCreatedAt
+	}
+	q.PageRequest.Normalize()
+}
+
+// Validate rejects combinations of filters the query cannot answer.
+func (q *ProductQuery) Validate() error {
+	if q.Currency == "" && (q.MinPrice != nil || q.MaxPrice != nil || q.Sort == ProductSortPrice) {
+		return ErrPriceWithoutCurrency
+	}
+	return nil
+}
@@ -2,14 +2,49 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/yusirdemir/microservice/internal/domain"
 )
 
+// ProductRepository hides soft-deleted products from FindByID and
+// FindAllByUserID. Delete and the DeleteAll methods remove documents for good;
+// the DeleteAll methods return the IDs they removed, so callers can clean up
+// what hangs off those products. Like UserRepository, every method is scoped
+// to the tenant carried by ctx.
 type ProductRepository interface {
 	Create(ctx context.Context, product *domain.Product) error
 	FindByID(ctx context.Context, id string) (*domain.Product, error)
+	FindDeletedByID(ctx context.Context, id string) (*domain.Product, error)
 	FindAllByUserID(ctx context.Context, userID string) ([]*domain.Product, error)
+	CountByUserID(ctx context.Context, userID string) (int, error)
+	// FindPageByUserID pages through the user's live products oldest first,
+	// with the ID breaking ties between equal creation times.
+	FindPageByUserID(ctx context.Context, userID string, page PageRequest) (*ProductPage, error)
+	// List returns the live products matching the query, ordered by its sort
+	// with the ID as tie-breaker.
+	List(ctx context.Context, query ProductQuery) (*ProductPage, error)
+	// Update fails with domain.ErrVersionMismatch unless the product's
+	// version is still the stored one, and assigns the new version on success.
 	Update(ctx context.Context, product *domain.Product) error
+	// WriteBatch applies every write on its own and returns their errors in
+	// order; one failing write does not stop the rest.
+	WriteBatch(ctx context.Context, writes []ProductWrite) []error
+	// WriteBatchAtomic applies all writes or none, failing with a
+	// *BatchWriteError for the write that could not be applied. Written
+	// products get their new version where the driver can report it, and
+	// zero otherwise.
+	WriteBatchAtomic(ctx context.Context, writes []ProductWrite) error
+	// AdjustStock atomically adds delta to a live product's stock and returns
+	// the result. It fails with domain.ErrInsufficientStock, changing
+	// nothing, when the stock would go negative.
+	AdjustStock(ctx context.Context, id string, delta int) (*domain.Product, error)
+	// SoftDeleteAllByUserID stamps every live product of the user with
+	// deletedAt; RestoreAllByUserID revives exactly the products carrying that
+	// stamp, so products deleted on their own stay deleted.
+	SoftDeleteAllByUserID(ctx context.Context, userID string, deletedAt time.Time) error
+	RestoreAllByUserID(ctx context.Context, userID string, deletedAt time.Time) error
 	Delete(ctx context.Context, id string) error
+	DeleteAllByUserID(ctx context.Context, userID string) ([]string, error)
+	DeleteAllDeletedBefore(ctx context.Context, cutoff time.Time) ([]string, error)
 }
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+// SearchHit is one ranked match; a higher Score is a better match.
+type SearchHit struct {
+	ID    string
+	Score float64
+}
+
+// SearchIndex ranks products by how well their names match free text,
+// tolerating typos and matching word prefixes. Like the repositories it is
+// scoped to the tenant carried by ctx. Hits may be stale: callers re-read
+// them from the ProductRepository, which has the final say.
+type SearchIndex interface {
+	Index(ctx context.Context, product *domain.Product) error
+	Remove(ctx context.Context, id string) error
+	Search(ctx context.Context, query string, limit int) ([]SearchHit, error)
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *domain.Session) error
+	FindByID(ctx context.Context, id string) (*domain.Session, error)
+	// Update fails with domain.ErrVersionMismatch unless the session's
+	// version is still the stored one, and assigns the new version on success.
+	Update(ctx context.Context, session *domain.Session) error
+	RevokeAllByUserID(ctx context.Context, userID string) error
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+type UserSort string
+
+const (
+	UserSortCreatedAt UserSort = "created_at"
+	UserSortName      UserSort = "name"
+	UserSortEmail     UserSort = "email"
+)
+
+func ParseUserSort(s string) (UserSort, error) {
+	switch sort := UserSort(s); sort {
+	case UserSortCreatedAt, UserSortName, UserSortEmail:
+		return sort, nil
+	default:
+		return "", errors.New("unknown sort field: " + s)
+	}
+}
+
+// UserQuery lists users page by page. NamePrefix matches case-insensitively,
+// EmailPrefix matches the start of the normalized address, and the created-at
+// range is inclusive of CreatedFrom and exclusive of CreatedTo.
+type UserQuery struct {
+	NamePrefix  string
+	EmailPrefix string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Sort        UserSort
+	Descending  bool
+	Limit       int
+	Cursor      string
+}
+
+// Normalize fills in defaults, clamps the page size and normalizes the email
+// prefix the way stored addresses are.
+func (q *UserQuery) Normalize() {
+	q.EmailPrefix = strings.ToLower(strings.TrimSpace(q.EmailPrefix))
+	if q.Sort == "" {
+		q.Sort = UserSortCreatedAt
+	}
+	q.Limit = clampLimit(q.Limit)
+}
+
+type UserPage struct {
+	Users      []*domain.User
+	NextCursor string
+}
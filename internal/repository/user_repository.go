@@ -2,13 +2,32 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/yusirdemir/microservice/internal/domain"
 )
 
+// UserRepository hides soft-deleted users from FindByID and FindByEmail; they
+// are only reachable through FindDeletedByID until they are purged with
+// Delete. A soft-deleted user keeps its email reserved so it can be restored.
+//
+// Every method is scoped to the tenant carried by ctx (see package tenant):
+// users of other tenants are invisible, and email addresses are unique per
+// tenant only.
 type UserRepository interface {
 	Create(ctx context.Context, user *domain.User) error
 	FindByID(ctx context.Context, id string) (*domain.User, error)
+	FindByEmail(ctx context.Context, email string) (*domain.User, error)
+	FindDeletedByID(ctx context.Context, id string) (*domain.User, error)
+	FindAllDeletedBefore(ctx context.Context, cutoff time.Time) ([]*domain.User, error)
+	// List returns live users only, ordered by the query's sort with the ID
+	// as tie-breaker.
+	List(ctx context.Context, query UserQuery) (*UserPage, error)
+	// Update fails with domain.ErrVersionMismatch unless the user's version
+	// is still the stored one, and assigns the new version on success.
 	Update(ctx context.Context, user *domain.User) error
 	Delete(ctx context.Context, id string) error
+	// ListTenants is the one method that spans tenants. It names every
+	// tenant holding at least one user, so maintenance jobs can visit each.
+	ListTenants(ctx context.Context) ([]string, error)
 }
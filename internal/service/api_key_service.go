@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var apiKeyTracer = otel.Tracer("microservice/service/api_key")
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, caller *auth.Principal, name string, scopes []domain.Scope) (*domain.APIKey, string, error)
+	ListAPIKeys(ctx context.Context, caller *auth.Principal) ([]*domain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, caller *auth.Principal, id string) error
+}
+
+type apiKeyService struct {
+	repo repository.APIKeyRepository
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository) APIKeyService {
+	return &apiKeyService{
+		repo: repo,
+	}
+}
+
+// CreateAPIKey returns the stored key together with its plaintext value,
+// which is never available again afterwards.
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, caller *auth.Principal, name string, scopes []domain.Scope) (*domain.APIKey, string, error) {
+	ctx, span := apiKeyTracer.Start(ctx, "APIKeyService.CreateAPIKey")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.user.id", caller.UserID))
+
+	id := uuid.New().String()
+	rawKey, secretHash, err := auth.NewOpaqueToken(id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, "", err
+	}
+
+	key, err := domain.NewAPIKey(id, caller.UserID, name, secretHash, scopes)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, "", err
+	}
+
+	span.SetAttributes(attribute.String("app.api_key.id", key.ID))
+
+	if err := s.repo.Create(ctx, key); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, "", err
+	}
+
+	return key, rawKey, nil
+}
+
+func (s *apiKeyService) ListAPIKeys(ctx context.Context, caller *auth.Principal) ([]*domain.APIKey, error) {
+	ctx, span := apiKeyTracer.Start(ctx, "APIKeyService.ListAPIKeys")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.user.id", caller.UserID))
+
+	keys, err := s.repo.FindAllByUserID(ctx, caller.UserID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("app.api_key.count", len(keys)))
+
+	return keys, nil
+}
+
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, caller *auth.Principal, id string) error {
+	ctx, span := apiKeyTracer.Start(ctx, "APIKeyService.RevokeAPIKey")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.api_key.id", id))
+
+	key, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if !caller.CanActOn(key.UserID) {
+		err := domain.ErrForbidden
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	key.Revoke()
+	if err := s.repo.Update(ctx, key); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var authTracer = otel.Tracer("microservice/service/auth")
+
+type TokenPair struct {
+	AccessToken           string
+	AccessTokenExpiresAt  time.Time
+	RefreshToken          string
+	RefreshTokenExpiresAt time.Time
+}
+
+type AuthService interface {
+	Login(ctx context.Context, email, password, ip string) (*TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (*TokenPair, error)
+	Logout(ctx context.Context, principal *auth.Principal) error
+	LogoutAll(ctx context.Context, principal *auth.Principal) error
+	Authenticate(ctx context.Context, accessToken string) (*auth.Principal, error)
+	AuthenticateAPIKey(ctx context.Context, key string) (*auth.Principal, error)
+}
+
+// apiKeyTouchResolution limits how often an API key's last-used timestamp is
+// written, so busy keys do not cost a write per request.
+const apiKeyTouchResolution = time.Minute
+
+type authService struct {
+	users      UserService
+	sessions   repository.SessionRepository
+	apiKeys    repository.APIKeyRepository
+	guard      LoginGuard
+	tokens     *auth.TokenManager
+	refreshTTL time.Duration
+}
+
+func NewAuthService(users UserService, sessions repository.SessionRepository, apiKeys repository.APIKeyRepository, guard LoginGuard, tokens *auth.TokenManager, refreshTTL time.Duration) AuthService {
+	return &authService{
+		users:      users,
+		sessions:   sessions,
+		apiKeys:    apiKeys,
+		guard:      guard,
+		tokens:     tokens,
+		refreshTTL: refreshTTL,
+	}
+}
+
+func (s *authService) Login(ctx context.Context, email, password, ip string) (*TokenPair, error) {
+	ctx, span := authTracer.Start(ctx, "AuthService.Login")
+	defer span.End()
+
+	if err := s.guard.Reserve(ctx, email, ip); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	user, err := s.users.Authenticate(ctx, email, password)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCredentials) {
+			s.guard.RecordFailure(ctx)
+		} else if guardErr := s.guard.Release(ctx, email, ip); guardErr != nil {
+			span.RecordError(guardErr)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if err := s.guard.RecordSuccess(ctx, email, ip); err != nil {
+		span.RecordError(err)
+	}
+
+	span.SetAttributes(attribute.String("app.user.id", user.ID()))
+
+	sessionID := uuid.New().String()
+	refreshToken, tokenHash, err := auth.NewOpaqueToken(refreshTokenID(ctx, sessionID))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	session, err := domain.NewSession(sessionID, user.ID(), tokenHash, time.Now().Add(s.refreshTTL))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if err := s.sessions.Create(ctx, session); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("app.session.id", session.ID))
+
+	pair, err := s.issue(ctx, session, user, refreshToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+func (s *authService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	ctx, span := authTracer.Start(ctx, "AuthService.Refresh")
+	defer span.End()
+
+	tokenID, secret, err := auth.ParseOpaqueToken(refreshToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	// Like an access token, a refresh token only works within the tenant it
+	// was issued for, and the session is looked up there.
+	tenantID, sessionID, err := parseRefreshTokenID(ctx, tokenID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	ctx = tenant.WithTenant(ctx, tenantID)
+
+	span.SetAttributes(attribute.String("app.session.id", sessionID))
+
+	session, err := s.sessions.FindByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			err = auth.ErrInvalidToken
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if !auth.SecretMatches(secret, session.TokenHash) {
+		err := auth.ErrInvalidToken
+		if session.WasRotated(auth.HashSecret(secret)) {
+			// A rotated token came back: either the client or an attacker
+			// holds a stale copy, so nobody in this family can be trusted.
+			err = auth.ErrTokenReused
+			if revokeErr := s.revokeSession(ctx, session); revokeErr != nil {
+				span.RecordError(revokeErr)
+			}
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if !session.IsActive(time.Now()) {
+		err := auth.ErrInvalidToken
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	user, err := s.users.GetUser(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			err = auth.ErrInvalidToken
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	newRefreshToken, newTokenHash, err := auth.NewOpaqueToken(refreshTokenID(ctx, session.ID))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	session.Rotate(newTokenHash)
+	if err := s.sessions.Update(ctx, session); err != nil {
+		if errors.Is(err, domain.ErrVersionMismatch) {
+			// Another refresh rotated or revoked the session since it was
+			// read, so this token was presented twice: treat it as reused.
+			err = auth.ErrTokenReused
+			if revokeErr := s.revokeSession(ctx, session); revokeErr != nil {
+				span.RecordError(revokeErr)
+			}
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	pair, err := s.issue(ctx, session, user, newRefreshToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+func (s *authService) Logout(ctx context.Context, principal *auth.Principal) error {
+	ctx, span := authTracer.Start(ctx, "AuthService.Logout")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("app.user.id", principal.UserID),
+		attribute.String("app.session.id", principal.SessionID),
+	)
+
+	session, err := s.sessions.FindByID(ctx, principal.SessionID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := s.revokeSession(ctx, session); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// revokeSession revokes the session, reading it again whenever a concurrent
+// write changed it first.
+func (s *authService) revokeSession(ctx context.Context, session *domain.Session) error {
+	for {
+		if session.RevokedAt != nil {
+			return nil
+		}
+
+		session.Revoke()
+		err := s.sessions.Update(ctx, session)
+		if !errors.Is(err, domain.ErrVersionMismatch) {
+			return err
+		}
+
+		session, err = s.sessions.FindByID(ctx, session.ID)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *authService) LogoutAll(ctx context.Context, principal *auth.Principal) error {
+	ctx, span := authTracer.Start(ctx, "AuthService.LogoutAll")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.user.id", principal.UserID))
+
+	if err := s.sessions.RevokeAllByUserID(ctx, principal.UserID); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// Authenticate looks the session up in the tenant the token was issued for;
+// whether that tenant may serve the request is the caller's decision.
+func (s *authService) Authenticate(ctx context.Context, accessToken string) (*auth.Principal, error) {
+	principal, err := s.tokens.ParseAccessToken(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.sessions.FindByID(tenant.WithTenant(ctx, principal.TenantID), principal.SessionID)
+	if err != nil {
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	if session.UserID != principal.UserID || !session.IsActive(time.Now()) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	return principal, nil
+}
+
+func (s *authService) AuthenticateAPIKey(ctx context.Context, key string) (*auth.Principal, error) {
+	keyID, secret, err := auth.ParseOpaqueToken(key)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, err := s.apiKeys.FindByID(ctx, keyID)
+	if err != nil {
+		if errors.Is(err, domain.ErrAPIKeyNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	if !auth.SecretMatches(secret, apiKey.SecretHash) || !apiKey.IsActive() {
+		return nil, auth.ErrInvalidToken
+	}
+
+	user, err := s.users.GetUser(ctx, apiKey.UserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	if now := time.Now(); apiKey.Touch(now, apiKeyTouchResolution) {
+		if err := s.apiKeys.Touch(ctx, apiKey.ID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return &auth.Principal{
+		UserID:   user.ID(),
+		Email:    user.Email(),
+		Roles:    user.Roles(),
+		TenantID: tenant.FromContext(ctx),
+		APIKeyID: apiKey.ID,
+		Scopes:   apiKey.Scopes,
+	}, nil
+}
+
+// refreshTokenID names the session and the tenant it lives in, so a refresh
+// token carries its tenant the way an access token's claims do.
+func refreshTokenID(ctx context.Context, sessionID string) string {
+	return tenant.FromContext(ctx) + ":" + sessionID
+}
+
+// parseRefreshTokenID reverses refreshTokenID. Tokens issued before they
+// carried a tenant belong to the tenant the request names. A token presented
+// to another tenant than its own is invalid there.
+func parseRefreshTokenID(ctx context.Context, id string) (tenantID string, sessionID string, err error) {
+	tenantID, sessionID, found := strings.Cut(id, ":")
+	if !found {
+		return tenant.FromContext(ctx), id, nil
+	}
+	if sessionID == "" || tenant.Validate(tenantID) != nil {
+		return "", "", auth.ErrInvalidToken
+	}
+	if requested, ok := tenant.Lookup(ctx); ok && requested != tenantID {
+		return "", "", auth.ErrInvalidToken
+	}
+	return tenantID, sessionID, nil
+}
+
+func (s *authService) issue(ctx context.Context, session *domain.Session, user *domain.User, refreshToken string) (*TokenPair, error) {
+	accessToken, expiresAt, err := s.tokens.IssueAccessToken(&auth.Principal{
+		UserID:    user.ID(),
+		Email:     user.Email(),
+		Roles:     user.Roles(),
+		SessionID: session.ID,
+		TenantID:  tenant.FromContext(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  expiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: session.ExpiresAt,
+	}, nil
+}
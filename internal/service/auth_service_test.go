@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository/memory"
+	"github.com/yusirdemir/microservice/internal/tenant"
+	"github.com/yusirdemir/microservice/pkg/mailer"
+)
+
+const (
+	testEmail    = "ada@example.com"
+	testPassword = "original-password"
+)
+
+// newTestAuthService wires an AuthService and an APIKeyService to memory
+// repositories holding a single user with testEmail and testPassword.
+func newTestAuthService(t *testing.T, policy domain.LockoutPolicy) (AuthService, APIKeyService, *domain.User) {
+	t.Helper()
+
+	users := newTestUserService(t, memory.NewUserRepository(), memory.NewOneTimeTokenRepository(), newTestHasher(t), mailer.NewOutbox("noreply@example.com"), lenientPolicy)
+	user, err := users.CreateUser(context.Background(), "Ada", testEmail, testPassword, domain.RoleCustomer)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	tokens, err := auth.NewTokenManager("test-signing-key", "test", time.Minute)
+	if err != nil {
+		t.Fatalf("NewTokenManager: %v", err)
+	}
+
+	apiKeys := memory.NewAPIKeyRepository()
+	guard := NewLoginGuard(memory.NewLoginAttemptRepository(), policy, policy)
+	return NewAuthService(users, memory.NewSessionRepository(), apiKeys, guard, tokens, time.Hour), NewAPIKeyService(apiKeys), user
+}
+
+// lenientPolicy never throttles within a test.
+var lenientPolicy = domain.LockoutPolicy{
+	FreeAttempts:     100,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Second,
+	LockoutThreshold: 100,
+	LockoutDuration:  time.Minute,
+	ResetAfter:       time.Minute,
+}
+
+func TestAuthService_LoginThrottling(t *testing.T) {
+	ctx := context.Background()
+	// One free attempt, then a backoff no test outlives.
+	strictPolicy := domain.LockoutPolicy{
+		FreeAttempts:     1,
+		BaseDelay:        time.Hour,
+		MaxDelay:         time.Hour,
+		LockoutThreshold: 100,
+		LockoutDuration:  time.Hour,
+		ResetAfter:       time.Hour,
+	}
+
+	t.Run("parallel guesses share the backoff", func(t *testing.T) {
+		svc, _, _ := newTestAuthService(t, strictPolicy)
+
+		const attempts = 16
+		errs := make([]error, attempts)
+		var wg sync.WaitGroup
+		for i := range attempts {
+			wg.Go(func() {
+				_, errs[i] = svc.Login(ctx, testEmail, "wrong-password", "192.0.2.1")
+			})
+		}
+		wg.Wait()
+
+		checked := 0
+		for _, err := range errs {
+			switch {
+			case errors.Is(err, domain.ErrInvalidCredentials):
+				checked++
+			case !errors.Is(err, domain.ErrLoginThrottled):
+				t.Errorf("expected ErrInvalidCredentials or ErrLoginThrottled, got %v", err)
+			}
+		}
+		// The free attempt plus the one that starts the backoff.
+		if checked != 2 {
+			t.Fatalf("expected 2 guesses to reach the password check, got %d", checked)
+		}
+	})
+
+	t.Run("successful logins do not count against the address", func(t *testing.T) {
+		svc, _, _ := newTestAuthService(t, strictPolicy)
+		for i := range 3 {
+			if _, err := svc.Login(ctx, testEmail, testPassword, "192.0.2.1"); err != nil {
+				t.Fatalf("login %d: %v", i+1, err)
+			}
+		}
+	})
+}
+
+func TestAuthService_Refresh(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rotates the refresh token", func(t *testing.T) {
+		svc, _, _ := newTestAuthService(t, lenientPolicy)
+		pair, err := svc.Login(ctx, testEmail, testPassword, "192.0.2.1")
+		if err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+
+		rotated, err := svc.Refresh(ctx, pair.RefreshToken)
+		if err != nil {
+			t.Fatalf("Refresh: %v", err)
+		}
+		if rotated.RefreshToken == pair.RefreshToken {
+			t.Fatal("expected a new refresh token")
+		}
+
+		if _, err := svc.Refresh(ctx, rotated.RefreshToken); err != nil {
+			t.Fatalf("Refresh with the rotated token: %v", err)
+		}
+	})
+
+	t.Run("reuse revokes the session", func(t *testing.T) {
+		svc, _, _ := newTestAuthService(t, lenientPolicy)
+		pair, err := svc.Login(ctx, testEmail, testPassword, "192.0.2.1")
+		if err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+		rotated, err := svc.Refresh(ctx, pair.RefreshToken)
+		if err != nil {
+			t.Fatalf("Refresh: %v", err)
+		}
+
+		if _, err := svc.Refresh(ctx, pair.RefreshToken); !errors.Is(err, auth.ErrTokenReused) {
+			t.Fatalf("expected ErrTokenReused, got %v", err)
+		}
+		if _, err := svc.Refresh(ctx, rotated.RefreshToken); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Fatalf("expected the current token to die with its session, got %v", err)
+		}
+		if _, err := svc.Authenticate(ctx, rotated.AccessToken); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Fatalf("expected the access token to die with its session, got %v", err)
+		}
+	})
+
+	t.Run("concurrent refreshes rotate once", func(t *testing.T) {
+		svc, _, _ := newTestAuthService(t, lenientPolicy)
+		pair, err := svc.Login(ctx, testEmail, testPassword, "192.0.2.1")
+		if err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+
+		const attempts = 16
+		errs := make([]error, attempts)
+		var wg sync.WaitGroup
+		for i := range attempts {
+			wg.Go(func() {
+				_, errs[i] = svc.Refresh(ctx, pair.RefreshToken)
+			})
+		}
+		wg.Wait()
+
+		succeeded := 0
+		for _, err := range errs {
+			switch {
+			case err == nil:
+				succeeded++
+			case !errors.Is(err, auth.ErrTokenReused) && !errors.Is(err, auth.ErrInvalidToken):
+				t.Errorf("expected ErrTokenReused or ErrInvalidToken, got %v", err)
+			}
+		}
+		if succeeded > 1 {
+			t.Fatalf("expected at most one refresh to succeed, got %d", succeeded)
+		}
+
+		// Whichever refresh lost saw the token twice, so the session is gone.
+		if _, err := svc.Authenticate(ctx, pair.AccessToken); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Fatalf("expected the session to be revoked, got %v", err)
+		}
+	})
+
+	t.Run("the token's tenant decides where the session lives", func(t *testing.T) {
+		users := newTestUserService(t, memory.NewUserRepository(), memory.NewOneTimeTokenRepository(), newTestHasher(t), mailer.NewOutbox("noreply@example.com"), lenientPolicy)
+		tokens, err := auth.NewTokenManager("test-signing-key", "test", time.Minute)
+		if err != nil {
+			t.Fatalf("NewTokenManager: %v", err)
+		}
+		guard := NewLoginGuard(memory.NewLoginAttemptRepository(), lenientPolicy, lenientPolicy)
+		svc := NewAuthService(users, memory.NewSessionRepository(), memory.NewAPIKeyRepository(), guard, tokens, time.Hour)
+
+		acme := tenant.WithTenant(ctx, "acme")
+		if _, err := users.CreateUser(acme, "Ada", testEmail, testPassword, domain.RoleCustomer); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		pair, err := svc.Login(acme, testEmail, testPassword, "192.0.2.1")
+		if err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+
+		if _, err := svc.Refresh(tenant.WithTenant(ctx, "globex"), pair.RefreshToken); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken under another tenant, got %v", err)
+		}
+
+		// Without a tenant on the request, the token's own tenant is used.
+		rotated, err := svc.Refresh(ctx, pair.RefreshToken)
+		if err != nil {
+			t.Fatalf("Refresh: %v", err)
+		}
+		principal, err := svc.Authenticate(ctx, rotated.AccessToken)
+		if err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		if principal.TenantID != "acme" {
+			t.Fatalf("expected the new access token to be for acme, got %q", principal.TenantID)
+		}
+		if _, err := svc.Refresh(acme, rotated.RefreshToken); err != nil {
+			t.Fatalf("Refresh under the token's tenant: %v", err)
+		}
+	})
+
+	t.Run("logout revokes the session", func(t *testing.T) {
+		svc, _, _ := newTestAuthService(t, lenientPolicy)
+		pair, err := svc.Login(ctx, testEmail, testPassword, "192.0.2.1")
+		if err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+		principal, err := svc.Authenticate(ctx, pair.AccessToken)
+		if err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+
+		if err := svc.Logout(ctx, principal); err != nil {
+			t.Fatalf("Logout: %v", err)
+		}
+		if _, err := svc.Refresh(ctx, pair.RefreshToken); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken after logout, got %v", err)
+		}
+		if _, err := svc.Authenticate(ctx, pair.AccessToken); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken after logout, got %v", err)
+		}
+	})
+}
+
+func TestAuthService_AuthenticateAPIKey(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("carries the key's scopes", func(t *testing.T) {
+		svc, keys, user := newTestAuthService(t, lenientPolicy)
+		owner := &auth.Principal{UserID: user.ID(), Roles: user.Roles()}
+		_, rawKey, err := keys.CreateAPIKey(ctx, owner, "reader", []domain.Scope{domain.ScopeProductsRead})
+		if err != nil {
+			t.Fatalf("CreateAPIKey: %v", err)
+		}
+
+		principal, err := svc.AuthenticateAPIKey(ctx, rawKey)
+		if err != nil {
+			t.Fatalf("AuthenticateAPIKey: %v", err)
+		}
+		if principal.UserID != user.ID() {
+			t.Fatalf("expected the key to act for %s, got %s", user.ID(), principal.UserID)
+		}
+		if !principal.HasScopes(domain.ScopeProductsRead) {
+			t.Fatal("expected the key to hold products:read")
+		}
+		if principal.HasScopes(domain.ScopeProductsWrite) {
+			t.Fatal("expected the key not to hold products:write")
+		}
+	})
+
+	t.Run("revoked keys are refused", func(t *testing.T) {
+		svc, keys, user := newTestAuthService(t, lenientPolicy)
+		owner := &auth.Principal{UserID: user.ID(), Roles: user.Roles()}
+		key, rawKey, err := keys.CreateAPIKey(ctx, owner, "reader", []domain.Scope{domain.ScopeProductsRead})
+		if err != nil {
+			t.Fatalf("CreateAPIKey: %v", err)
+		}
+
+		// Uses racing the revocation must not bring the key back.
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Go(func() { _, _ = svc.AuthenticateAPIKey(ctx, rawKey) })
+		}
+		if err := keys.RevokeAPIKey(ctx, owner, key.ID); err != nil {
+			t.Fatalf("RevokeAPIKey: %v", err)
+		}
+		wg.Wait()
+
+		if _, err := svc.AuthenticateAPIKey(ctx, rawKey); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken for a revoked key, got %v", err)
+		}
+	})
+}
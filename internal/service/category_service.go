@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var categoryTracer = otel.Tracer("microservice/service/category")
+
+var errUnknownParent = errors.New("parent_id does not name an existing category")
+
+type CategoryService interface {
+	CreateCategory(ctx context.Context, parentID string, name string) (*domain.Category, error)
+	GetCategory(ctx context.Context, id string) (*domain.Category, error)
+	ListCategories(ctx context.Context) ([]*domain.Category, error)
+	UpdateCategory(ctx context.Context, id string, parentID string, name string) (*domain.Category, error)
+	DeleteCategory(ctx context.Context, id string) error
+}
+
+type categoryService struct {
+	repo     repository.CategoryRepository
+	products repository.ProductRepository
+}
+
+// NewCategoryService needs the products only to refuse deleting a category
+// that still has some.
+func NewCategoryService(repo repository.CategoryRepository, products repository.ProductRepository) CategoryService {
+	return &categoryService{
+		repo:     repo,
+		products: products,
+	}
+}
+
+// CreateCategory adds a top-level category when parentID is empty.
+func (s *categoryService) CreateCategory(ctx context.Context, parentID string, name string) (*domain.Category, error) {
+	ctx, span := categoryTracer.Start(ctx, "CategoryService.CreateCategory")
+	defer span.End()
+
+	category, err := domain.NewCategory("", parentID, name)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String("app.category.id", category.ID),
+		attribute.String("app.category.parent_id", parentID),
+	)
+
+	if err := s.checkParent(ctx, parentID); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, category); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return category, nil
+}
+
+func (s *categoryService) GetCategory(ctx context.Context, id string) (*domain.Category, error) {
+	ctx, span := categoryTracer.Start(ctx, "CategoryService.GetCategory")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.category.id", id))
+
+	category, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return category, nil
+}
+
+// ListCategories returns the flat tree; clients nest it by parent_id.
+func (s *categoryService) ListCategories(ctx context.Context) ([]*domain.Category, error) {
+	ctx, span := categoryTracer.Start(ctx, "CategoryService.ListCategories")
+	defer span.End()
+
+	categories, err := s.repo.FindAll(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("app.category.count", len(categories)))
+
+	return categories, nil
+}
+
+// UpdateCategory renames a category and moves it, with its subtree, below
+// parentID. A move below one of its own descendants fails with
+// domain.ErrCategoryCycle.
+func (s *categoryService) UpdateCategory(ctx context.Context, id string, parentID string, name string) (*domain.Category, error) {
+	ctx, span := categoryTracer.Start(ctx, "CategoryService.UpdateCategory")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("app.category.id", id),
+		attribute.String("app.category.parent_id", parentID),
+	)
+
+	category, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	updated, err := domain.NewCategory(category.ID, parentID, name)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	updated.CreatedAt = category.CreatedAt
+
+	if parentID != "" && parentID != category.ParentID {
+		all, err := s.repo.FindAll(ctx)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		if !slices.ContainsFunc(all, func(c *domain.Category) bool { return c.ID == parentID }) {
+			err := errUnknownParent
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		if slices.Contains(domain.Subtree(all, id), parentID) {
+			err := domain.ErrCategoryCycle
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+	}
+
+	updated.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, updated); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// DeleteCategory only removes a leaf without live products, so nothing is
+// left pointing at a category that no longer exists.
+func (s *categoryService) DeleteCategory(ctx context.Context, id string) error {
+	ctx, span := categoryTracer.Start(ctx, "CategoryService.DeleteCategory")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.category.id", id))
+
+	all, err := s.repo.FindAll(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	if !slices.ContainsFunc(all, func(c *domain.Category) bool { return c.ID == id }) {
+		err := domain.ErrCategoryNotFound
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	if len(domain.Subtree(all, id)) > 1 {
+		err := domain.ErrCategoryInUse
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	page, err := s.products.List(ctx, repository.ProductQuery{
+		CategoryIDs: []string{id},
+		PageRequest: repository.PageRequest{Limit: 1},
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	if len(page.Products) > 0 {
+		err := domain.ErrCategoryInUse
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (s *categoryService) checkParent(ctx context.Context, parentID string) error {
+	if parentID == "" {
+		return nil
+	}
+	if _, err := s.repo.FindByID(ctx, parentID); err != nil {
+		if errors.Is(err, domain.ErrCategoryNotFound) {
+			return errUnknownParent
+		}
+		return err
+	}
+	return nil
+}
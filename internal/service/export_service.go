@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/export"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/pkg/concurrency"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var exportTracer = otel.Tracer("microservice/service/export")
+
+// UserExport carries either a finished archive or, for large exports, the
+// background job to poll.
+type UserExport struct {
+	Archive []byte
+	Job     *domain.ExportJob
+}
+
+type ExportService interface {
+	ExportUser(ctx context.Context, caller *auth.Principal, userID string, async bool) (*UserExport, error)
+	GetExportJob(ctx context.Context, caller *auth.Principal, userID, jobID string) (*domain.ExportJob, error)
+	DownloadExport(ctx context.Context, caller *auth.Principal, userID, jobID string) ([]byte, error)
+}
+
+type exportService struct {
+	users     repository.UserRepository
+	products  repository.ProductRepository
+	jobs      repository.ExportJobRepository
+	builders  *concurrency.WorkerPool
+	syncLimit int
+	ttl       time.Duration
+}
+
+// NewExportService builds exports inline while a user has at most syncLimit
+// products and on builders otherwise. Finished archives are kept for ttl.
+func NewExportService(users repository.UserRepository, products repository.ProductRepository, jobs repository.ExportJobRepository, builders *concurrency.WorkerPool, syncLimit int, ttl time.Duration) ExportService {
+	return &exportService{
+		users:     users,
+		products:  products,
+		jobs:      jobs,
+		builders:  builders,
+		syncLimit: syncLimit,
+		ttl:       ttl,
+	}
+}
+
+func (s *exportService) ExportUser(ctx context.Context, caller *auth.Principal, userID string, async bool) (*UserExport, error) {
+	ctx, span := exportTracer.Start(ctx, "ExportService.ExportUser")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.user.id", userID))
+
+	if !caller.CanActOn(userID) {
+		err := domain.ErrForbidden
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	// Only the count is read here; a large export reads its products in the
+	// background.
+	count, err := s.products.CountByUserID(ctx, user.ID())
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("app.product.count", count))
+
+	if !async && count <= s.syncLimit {
+		products, err := s.products.FindAllByUserID(ctx, user.ID())
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+
+		archive, err := export.Build(user, products)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		return &UserExport{Archive: archive}, nil
+	}
+
+	job, err := domain.NewExportJob(uuid.New().String(), user.ID(), time.Now().Add(s.ttl))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if err := s.jobs.Create(ctx, job); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("app.export.id", job.ID))
+
+	created := *job
+	err = s.builders.Submit(ctx, func(ctx context.Context) {
+		s.build(ctx, job, user)
+	})
+	if err != nil {
+		// Fail the job, so it does not block the user's next export.
+		job.Fail(err)
+		if updateErr := s.jobs.Update(ctx, job); updateErr != nil {
+			span.RecordError(updateErr)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return &UserExport{Job: &created}, nil
+}
+
+func (s *exportService) build(ctx context.Context, job *domain.ExportJob, user *domain.User) {
+	ctx, span := exportTracer.Start(ctx, "ExportService.build")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.export.id", job.ID))
+
+	products, err := s.products.FindAllByUserID(ctx, user.ID())
+	var archive []byte
+	if err == nil {
+		archive, err = export.Build(user, products)
+	}
+	if err == nil {
+		err = s.jobs.SaveArchive(ctx, job, archive)
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		job.Fail(err)
+	} else {
+		span.SetAttributes(attribute.Int("app.export.size", len(archive)))
+		job.Complete(len(archive))
+	}
+
+	if err := s.jobs.Update(ctx, job); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+func (s *exportService) GetExportJob(ctx context.Context, caller *auth.Principal, userID, jobID string) (*domain.ExportJob, error) {
+	ctx, span := exportTracer.Start(ctx, "ExportService.GetExportJob")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("app.user.id", userID),
+		attribute.String("app.export.id", jobID),
+	)
+
+	job, err := s.findJob(ctx, caller, userID, jobID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (s *exportService) DownloadExport(ctx context.Context, caller *auth.Principal, userID, jobID string) ([]byte, error) {
+	ctx, span := exportTracer.Start(ctx, "ExportService.DownloadExport")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("app.user.id", userID),
+		attribute.String("app.export.id", jobID),
+	)
+
+	job, err := s.findJob(ctx, caller, userID, jobID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if !job.IsReady() {
+		err := domain.ErrExportNotReady
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	archive, err := s.jobs.FindArchive(ctx, job.ID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return archive, nil
+}
+
+// findJob loads a job the caller may see. A job under another user's path
+// is reported as missing rather than forbidden.
+func (s *exportService) findJob(ctx context.Context, caller *auth.Principal, userID, jobID string) (*domain.ExportJob, error) {
+	if !caller.CanActOn(userID) {
+		return nil, domain.ErrForbidden
+	}
+
+	// Jobs are scoped to the tenant like their owners; resolving the owner
+	// also hides the jobs of users that are gone.
+	if _, err := s.users.FindByID(ctx, userID); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrExportNotFound
+		}
+		return nil, err
+	}
+
+	job, err := s.jobs.FindByID(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.UserID != userID {
+		return nil, domain.ErrExportNotFound
+	}
+
+	return job, nil
+}
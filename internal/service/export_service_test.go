@@ -0,0 +1,169 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository/memory"
+	"github.com/yusirdemir/microservice/pkg/concurrency"
+)
+
+// archivedProducts returns the number of products in an export archive.
+func archivedProducts(t *testing.T, archive []byte) int {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("zip.NewReader: %v", err)
+	}
+	file, err := reader.Open("products.json")
+	if err != nil {
+		t.Fatalf("open products.json: %v", err)
+	}
+	defer file.Close()
+
+	var products []json.RawMessage
+	if err := json.NewDecoder(file).Decode(&products); err != nil {
+		t.Fatalf("decode products.json: %v", err)
+	}
+	return len(products)
+}
+
+func TestExportService(t *testing.T) {
+	ctx := context.Background()
+	hasher := newTestHasher(t)
+
+	// setup returns an ExportService whose builders run on workers goroutines,
+	// and two users, the first owning three products. With no workers, async
+	// jobs stay pending.
+	setup := func(t *testing.T, workers int) (ExportService, *auth.Principal, *auth.Principal) {
+		t.Helper()
+		users := memory.NewUserRepository()
+		products := memory.NewProductRepository()
+		catalog := newProductService(t, products, memory.NewCategoryRepository(), memory.NewSearchIndex())
+
+		var principals []*auth.Principal
+		for _, email := range []string{"ada@example.com", "grace@example.com"} {
+			user, err := domain.NewUser("User", email, testPassword, hasher)
+			if err != nil {
+				t.Fatalf("NewUser: %v", err)
+			}
+			if err := users.Create(ctx, user); err != nil {
+				t.Fatalf("Create user: %v", err)
+			}
+			principals = append(principals, &auth.Principal{UserID: user.ID(), Roles: user.Roles()})
+		}
+		for range 3 {
+			if _, err := catalog.CreateProduct(ctx, principals[0].UserID, "Lamp", 100, "", 1, "", nil); err != nil {
+				t.Fatalf("CreateProduct: %v", err)
+			}
+		}
+
+		builders := concurrency.NewWorkerPool(workers, 4)
+		t.Cleanup(builders.Close)
+		// Two products fit a synchronous export, three do not.
+		return NewExportService(users, products, memory.NewExportJobRepository(), builders, 2, time.Hour), principals[0], principals[1]
+	}
+
+	waitForJob := func(t *testing.T, svc ExportService, caller *auth.Principal, jobID string) *domain.ExportJob {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			job, err := svc.GetExportJob(ctx, caller, caller.UserID, jobID)
+			if err != nil {
+				t.Fatalf("GetExportJob: %v", err)
+			}
+			if !job.IsPending() {
+				return job
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("expected the export job to finish")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	t.Run("small exports are built inline", func(t *testing.T) {
+		svc, _, grace := setup(t, 1)
+		result, err := svc.ExportUser(ctx, grace, grace.UserID, false)
+		if err != nil {
+			t.Fatalf("ExportUser: %v", err)
+		}
+		if result.Job != nil {
+			t.Fatal("expected an inline archive, got a job")
+		}
+		if n := archivedProducts(t, result.Archive); n != 0 {
+			t.Fatalf("expected no products, got %d", n)
+		}
+	})
+
+	t.Run("large exports are built in the background", func(t *testing.T) {
+		svc, ada, _ := setup(t, 1)
+		result, err := svc.ExportUser(ctx, ada, ada.UserID, false)
+		if err != nil {
+			t.Fatalf("ExportUser: %v", err)
+		}
+		if result.Job == nil {
+			t.Fatal("expected a job for an export above the sync limit")
+		}
+
+		job := waitForJob(t, svc, ada, result.Job.ID)
+		if !job.IsReady() {
+			t.Fatalf("expected the job to complete, got %s: %s", job.Status, job.Error)
+		}
+		archive, err := svc.DownloadExport(ctx, ada, ada.UserID, job.ID)
+		if err != nil {
+			t.Fatalf("DownloadExport: %v", err)
+		}
+		if n := archivedProducts(t, archive); n != 3 {
+			t.Fatalf("expected 3 products, got %d", n)
+		}
+	})
+
+	t.Run("async exports wait for the pending one", func(t *testing.T) {
+		svc, _, grace := setup(t, 0)
+		result, err := svc.ExportUser(ctx, grace, grace.UserID, true)
+		if err != nil {
+			t.Fatalf("ExportUser: %v", err)
+		}
+		if result.Job == nil {
+			t.Fatal("expected a job when async is asked for")
+		}
+
+		if _, err := svc.DownloadExport(ctx, grace, grace.UserID, result.Job.ID); !errors.Is(err, domain.ErrExportNotReady) {
+			t.Fatalf("expected ErrExportNotReady before the job is done, got %v", err)
+		}
+		if _, err := svc.ExportUser(ctx, grace, grace.UserID, true); !errors.Is(err, domain.ErrExportPending) {
+			t.Fatalf("expected ErrExportPending for a second job, got %v", err)
+		}
+	})
+
+	t.Run("jobs are private to their user", func(t *testing.T) {
+		svc, ada, grace := setup(t, 1)
+		result, err := svc.ExportUser(ctx, ada, ada.UserID, true)
+		if err != nil {
+			t.Fatalf("ExportUser: %v", err)
+		}
+		waitForJob(t, svc, ada, result.Job.ID)
+
+		// Under their own path, another user's job does not exist.
+		if _, err := svc.GetExportJob(ctx, grace, grace.UserID, result.Job.ID); !errors.Is(err, domain.ErrExportNotFound) {
+			t.Fatalf("expected ErrExportNotFound, got %v", err)
+		}
+		if _, err := svc.DownloadExport(ctx, grace, grace.UserID, result.Job.ID); !errors.Is(err, domain.ErrExportNotFound) {
+			t.Fatalf("expected ErrExportNotFound, got %v", err)
+		}
+		if _, err := svc.GetExportJob(ctx, grace, ada.UserID, result.Job.ID); !errors.Is(err, domain.ErrForbidden) {
+			t.Fatalf("expected ErrForbidden under another user's path, got %v", err)
+		}
+		if _, err := svc.ExportUser(ctx, grace, ada.UserID, false); !errors.Is(err, domain.ErrForbidden) {
+			t.Fatalf("expected ErrForbidden exporting another user, got %v", err)
+		}
+	})
+}
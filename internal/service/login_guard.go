@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/metrics"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var loginGuardTracer = otel.Tracer("microservice/service/login_guard")
+
+const (
+	scopeAccount = "account"
+	scopeIP      = "ip"
+)
+
+// LoginGuard throttles failed logins per account and per client address.
+// Accounts are keyed by the submitted email whether or not it exists, so the
+// throttling itself does not reveal which addresses are registered.
+//
+// Reserve counts every attempt as a failure before the password is checked,
+// so parallel guesses cannot all slip through before the first is recorded.
+// Each reserved attempt then ends in exactly one of RecordFailure,
+// RecordSuccess or Release.
+type LoginGuard interface {
+	Reserve(ctx context.Context, email, ip string) error
+	RecordFailure(ctx context.Context)
+	RecordSuccess(ctx context.Context, email, ip string) error
+	Release(ctx context.Context, email, ip string) error
+	Unlock(ctx context.Context, email, ip string) error
+}
+
+type loginGuard struct {
+	attempts      repository.LoginAttemptRepository
+	accountPolicy domain.LockoutPolicy
+	ipPolicy      domain.LockoutPolicy
+	// scopePrefix keeps the counters of guards sharing a repository apart.
+	scopePrefix string
+}
+
+func NewLoginGuard(attempts repository.LoginAttemptRepository, accountPolicy, ipPolicy domain.LockoutPolicy) LoginGuard {
+	return &loginGuard{
+		attempts:      attempts,
+		accountPolicy: accountPolicy,
+		ipPolicy:      ipPolicy,
+	}
+}
+
+// NewPasswordResetGuard throttles password reset requests per address and per
+// client with the login counters, under keys of their own. Callers only
+// Reserve: every request counts, whatever becomes of it.
+func NewPasswordResetGuard(attempts repository.LoginAttemptRepository, emailPolicy, ipPolicy domain.LockoutPolicy) LoginGuard {
+	return &loginGuard{
+		attempts:      attempts,
+		accountPolicy: emailPolicy,
+		ipPolicy:      ipPolicy,
+		scopePrefix:   "password_reset_",
+	}
+}
+
+type guardKey struct {
+	scope  string
+	key    string
+	policy domain.LockoutPolicy
+}
+
+// keys scopes account counters to the tenant, since the same address can
+// belong to a different account in each. IP counters are shared.
+func (g *loginGuard) keys(ctx context.Context, email, ip string) []guardKey {
+	var keys []guardKey
+	if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+		scope := g.scopePrefix + scopeAccount
+		keys = append(keys, guardKey{scope, scope + ":" + tenant.FromContext(ctx) + ":" + email, g.accountPolicy})
+	}
+	if ip != "" {
+		scope := g.scopePrefix + scopeIP
+		keys = append(keys, guardKey{scope, scope + ":" + ip, g.ipPolicy})
+	}
+	return keys
+}
+
+func (g *loginGuard) Reserve(ctx context.Context, email, ip string) error {
+	ctx, span := loginGuardTracer.Start(ctx, "LoginGuard.Reserve")
+	defer span.End()
+
+	now := time.Now()
+	keys := g.keys(ctx, email, ip)
+	for i, k := range keys {
+		_, locked, err := g.attempts.Reserve(ctx, k.key, k.policy, now)
+		if err != nil {
+			// The attempt is not going ahead, so the keys already counted
+			// get it back.
+			if refundErr := g.refund(ctx, keys[:i]); refundErr != nil {
+				span.RecordError(refundErr)
+			}
+
+			var throttled *domain.LoginThrottledError
+			if errors.As(err, &throttled) {
+				metrics.LoginThrottledTotal.WithLabelValues(k.scope).Inc()
+				span.SetAttributes(attribute.String("app.login.throttled_scope", k.scope))
+			}
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+		if locked {
+			metrics.LoginLockoutsTotal.WithLabelValues(k.scope).Inc()
+			span.SetAttributes(attribute.String("app.login.locked_scope", k.scope))
+		}
+	}
+
+	return nil
+}
+
+// RecordFailure only reports the failure; Reserve already counted it.
+func (g *loginGuard) RecordFailure(ctx context.Context) {
+	metrics.LoginFailuresTotal.Inc()
+}
+
+// RecordSuccess clears the account's failures. The address only gets the
+// reserved attempt back, otherwise an attacker could reset its count by
+// logging into an account they own.
+func (g *loginGuard) RecordSuccess(ctx context.Context, email, ip string) error {
+	ctx, span := loginGuardTracer.Start(ctx, "LoginGuard.RecordSuccess")
+	defer span.End()
+
+	for _, k := range g.keys(ctx, email, "") {
+		if err := g.attempts.Delete(ctx, k.key); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+	}
+
+	if err := g.refund(ctx, g.keys(ctx, "", ip)); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// Release hands a reserved attempt back when the login failed for a reason
+// other than the credentials, such as the user store being unreachable.
+func (g *loginGuard) Release(ctx context.Context, email, ip string) error {
+	ctx, span := loginGuardTracer.Start(ctx, "LoginGuard.Release")
+	defer span.End()
+
+	if err := g.refund(ctx, g.keys(ctx, email, ip)); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (g *loginGuard) refund(ctx context.Context, keys []guardKey) error {
+	for _, k := range keys {
+		if err := g.attempts.Refund(ctx, k.key, k.policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *loginGuard) Unlock(ctx context.Context, email, ip string) error {
+	ctx, span := loginGuardTracer.Start(ctx, "LoginGuard.Unlock")
+	defer span.End()
+
+	keys := g.keys(ctx, email, ip)
+	if len(keys) == 0 {
+		err := errors.New("email or ip is required")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	for _, k := range keys {
+		if err := g.attempts.Delete(ctx, k.key); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// MaxBatchSize bounds the operations of one batch.
+const MaxBatchSize = 1000
+
+var (
+	errBatchEmpty     = errors.New("batch has no operations")
+	errBatchTooLarge  = fmt.Errorf("batch cannot exceed %d operations", MaxBatchSize)
+	errBatchOp        = errors.New("op must be create, update or delete")
+	errBatchID        = errors.New("id is required for update and delete, and not allowed for create")
+	errBatchDuplicate = errors.New("product appears more than once in the batch")
+)
+
+// BatchOp names what a BatchItem does.
+type BatchOp string
+
+const (
+	BatchCreate BatchOp = "create"
+	BatchUpdate BatchOp = "update"
+	BatchDelete BatchOp = "delete"
+)
+
+// BatchItem is one operation of a batch. A create builds a product owned by
+// the caller from Patch, where absent fields count as zero arguments to
+// CreateProduct; an update applies Patch to product ID; a delete soft-deletes
+// it. Version works as in UpdateProduct and DeleteProduct.
+type BatchItem struct {
+	Op      BatchOp
+	ID      string
+	Version uint64
+	Patch   domain.ProductPatch
+}
+
+// BatchResult is the outcome of one item: the product as written, nil after
+// a delete, or the error that stopped it.
+type BatchResult struct {
+	Product *domain.Product
+	Err     error
+}
+
+// ApplyBatch checks every item, reading products one by one, and only then
+// hands the surviving writes to the repository in a single call. A product
+// may appear in one item only, so items never race each other.
+func (s *productService) ApplyBatch(ctx context.Context, caller *auth.Principal, items []BatchItem, atomic bool) ([]BatchResult, error) {
+	ctx, span := productTracer.Start(ctx, "ProductService.ApplyBatch")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.Int("app.batch.size", len(items)),
+		attribute.Bool("app.batch.atomic", atomic),
+	)
+
+	if len(items) == 0 || len(items) > MaxBatchSize {
+		err := errBatchEmpty
+		if len(items) > 0 {
+			err = errBatchTooLarge
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	checked := make(map[string]error)
+	checkCategory := func(id string) error {
+		err, ok := checked[id]
+		if !ok {
+			err = s.checkCategory(ctx, id)
+			checked[id] = err
+		}
+		return err
+	}
+
+	results := make([]BatchResult, len(items))
+	writes := make([]repository.ProductWrite, 0, len(items))
+	// positions maps each write back to its item.
+	positions := make([]int, 0, len(items))
+	seen := make(map[string]bool)
+	for i, item := range items {
+		if item.ID != "" {
+			if seen[item.ID] {
+				results[i].Err = errBatchDuplicate
+				continue
+			}
+			seen[item.ID] = true
+		}
+
+		write, err := s.prepareBatchItem(ctx, caller, item, checkCategory)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		writes = append(writes, write)
+		positions = append(positions, i)
+	}
+
+	switch {
+	case atomic && len(writes) < len(items):
+		abortBatch(results)
+	case atomic:
+		if err := s.repo.WriteBatchAtomic(ctx, writes); err != nil {
+			var failed *repository.BatchWriteError
+			if !errors.As(err, &failed) {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return nil, err
+			}
+			results[positions[failed.Index]].Err = failed.Err
+			abortBatch(results)
+		}
+	default:
+		for j, err := range s.repo.WriteBatch(ctx, writes) {
+			results[positions[j]].Err = err
+		}
+	}
+
+	for j, w := range writes {
+		i := positions[j]
+		if results[i].Err != nil {
+			continue
+		}
+		if items[i].Op == BatchDelete {
+			if err := s.search.Remove(ctx, w.Product.ID); err != nil {
+				span.RecordError(err)
+			}
+			continue
+		}
+		results[i].Product = w.Product
+		if err := s.search.Index(ctx, w.Product); err != nil {
+			span.RecordError(err)
+		}
+	}
+
+	failures := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failures++
+			span.RecordError(r.Err)
+		}
+	}
+	span.SetAttributes(attribute.Int("app.batch.failed", failures))
+	if failures > 0 {
+		span.SetStatus(codes.Error, fmt.Sprintf("%d of %d operations failed", failures, len(items)))
+	}
+
+	return results, nil
+}
+
+// prepareBatchItem turns an item into the write it stands for, applying the
+// same checks as the single-product methods.
+func (s *productService) prepareBatchItem(ctx context.Context, caller *auth.Principal, item BatchItem, checkCategory func(string) error) (repository.ProductWrite, error) {
+	switch item.Op {
+	case BatchCreate:
+		if item.ID != "" {
+			return repository.ProductWrite{}, errBatchID
+		}
+		var (
+			patch      = item.Patch
+			name       string
+			price      int64
+			currency   string
+			stock      int
+			categoryID string
+			tags       []string
+		)
+		if patch.Name != nil {
+			name = *patch.Name
+		}
+		if patch.Price != nil {
+			price = *patch.Price
+		}
+		if patch.Currency != nil {
+			currency = *patch.Currency
+		}
+		if patch.Stock != nil {
+			stock = *patch.Stock
+		}
+		if patch.CategoryID != nil {
+			categoryID = *patch.CategoryID
+		}
+		if patch.Tags != nil {
+			tags = *patch.Tags
+		}
+
+		product, err := s.newProduct(caller.UserID, name, price, currency, stock, categoryID, tags)
+		if err != nil {
+			return repository.ProductWrite{}, err
+		}
+		if err := checkCategory(product.CategoryID); err != nil {
+			return repository.ProductWrite{}, err
+		}
+		return repository.ProductWrite{Product: product, Create: true}, nil
+
+	case BatchUpdate, BatchDelete:
+		if item.ID == "" {
+			return repository.ProductWrite{}, errBatchID
+		}
+		product, err := s.findOwned(ctx, caller, item.ID, item.Version)
+		if err != nil {
+			return repository.ProductWrite{}, err
+		}
+		write := repository.ProductWrite{Product: product}
+
+		if item.Op == BatchDelete {
+			product.SoftDelete(time.Now().UTC())
+			return write, nil
+		}
+
+		if err := product.ApplyPatch(item.Patch, time.Now()); err != nil {
+			return repository.ProductWrite{}, err
+		}
+		if item.Patch.CategoryID != nil {
+			if err := checkCategory(product.CategoryID); err != nil {
+				return repository.ProductWrite{}, err
+			}
+		}
+		return write, nil
+	}
+
+	return repository.ProductWrite{}, errBatchOp
+}
+
+// abortBatch marks every item that has not failed on its own as aborted.
+func abortBatch(results []BatchResult) {
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Err = domain.ErrBatchAborted
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/imaging"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/tenant"
+	"github.com/yusirdemir/microservice/pkg/blobstore"
+	"github.com/yusirdemir/microservice/pkg/concurrency"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+var productImageTracer = otel.Tracer("microservice/service/product_image")
+
+type ProductImageService interface {
+	UploadImage(ctx context.Context, caller *auth.Principal, productID string, data []byte) (*SignedImage, error)
+	ListImages(ctx context.Context, productID string) ([]SignedImage, error)
+	GetImage(ctx context.Context, productID string, imageID string) (*SignedImage, error)
+	DeleteImage(ctx context.Context, caller *auth.Principal, productID string, imageID string) error
+	// PurgeImages removes every image of products that were hard-deleted,
+	// records and files alike.
+	PurgeImages(ctx context.Context, productIDs []string) error
+}
+
+// SignedImage is an image with URLs for it and its thumbnail, both valid
+// until ExpiresAt.
+type SignedImage struct {
+	Image        *domain.ProductImage
+	URL          string
+	ThumbnailURL string
+	ExpiresAt    time.Time
+}
+
+type productImageService struct {
+	repo          repository.ProductImageRepository
+	products      repository.ProductRepository
+	store         blobstore.BlobStore
+	maxSize       int64
+	maxPerProduct int
+	thumbnailSize int
+	processing    concurrency.Semaphore
+	urlTTL        time.Duration
+}
+
+// NewProductImageService accepts uploads of at most maxSize bytes and
+// maxPerProduct images per product, and scales thumbnails to fit a
+// thumbnailSize square. At most maxProcessing uploads are decoded at once,
+// since each may take up to imaging.MaxPixels in memory. Signed URLs stay
+// valid for urlTTL.
+func NewProductImageService(repo repository.ProductImageRepository, products repository.ProductRepository, store blobstore.BlobStore, maxSize int64, maxPerProduct, thumbnailSize, maxProcessing int, urlTTL time.Duration) ProductImageService {
+	return &productImageService{
+		repo:          repo,
+		products:      products,
+		store:         store,
+		maxSize:       maxSize,
+		maxPerProduct: maxPerProduct,
+		thumbnailSize: thumbnailSize,
+		processing:    concurrency.NewSemaphore(maxProcessing),
+		urlTTL:        urlTTL,
+	}
+}
+
+// UploadImage stores the original and its thumbnail before recording them,
+// and removes the files again if any later step fails, so metadata never
+// points at a missing file.
+func (s *productImageService) UploadImage(ctx context.Context, caller *auth.Principal, productID string, data []byte) (*SignedImage, error) {
+	ctx, span := productImageTracer.Start(ctx, "ProductImageService.UploadImage")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("app.product.id", productID),
+		attribute.Int("app.image.size", len(data)),
+	)
+
+	if int64(len(data)) > s.maxSize {
+		err := domain.ErrImageTooLarge
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if err := s.checkOwner(ctx, caller, productID); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	// Refuse early when the product is already full, before any file is
+	// written; the repository enforces the limit for concurrent uploads.
+	existing, err := s.repo.FindAllByProductID(ctx, productID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	if len(existing) >= s.maxPerProduct {
+		err := domain.ErrImageLimitReached
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	info, err := imaging.Inspect(data)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if err := s.processing.Acquire(ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	thumbnail, thumbInfo, err := imaging.Thumbnail(data, s.thumbnailSize)
+	s.processing.Release()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	id := uuid.New().String()
+	prefix := tenant.FromContext(ctx) + "/products/" + productID + "/images/" + id
+	image := &domain.ProductImage{
+		ID:           id,
+		ProductID:    productID,
+		ContentType:  info.ContentType,
+		Size:         int64(len(data)),
+		Width:        info.Width,
+		Height:       info.Height,
+		Key:          prefix + info.Extension,
+		ThumbnailKey: prefix + "-thumb" + thumbInfo.Extension,
+		CreatedAt:    time.Now(),
+	}
+	span.SetAttributes(
+		attribute.String("app.image.id", image.ID),
+		attribute.String("app.image.content_type", image.ContentType),
+	)
+
+	if err := s.store.Put(ctx, image.Key, bytes.NewReader(data), image.Size, image.ContentType); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	if err := s.store.Put(ctx, image.ThumbnailKey, bytes.NewReader(thumbnail), int64(len(thumbnail)), thumbInfo.ContentType); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		s.removeFiles(ctx, image)
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, image, s.maxPerProduct); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		s.removeFiles(ctx, image)
+		return nil, err
+	}
+
+	signed, err := s.sign(ctx, image)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return signed, nil
+}
+
+func (s *productImageService) ListImages(ctx context.Context, productID string) ([]SignedImage, error) {
+	ctx, span := productImageTracer.Start(ctx, "ProductImageService.ListImages")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.product.id", productID))
+
+	if _, err := s.products.FindByID(ctx, productID); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	images, err := s.repo.FindAllByProductID(ctx, productID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	signed := make([]SignedImage, 0, len(images))
+	for _, image := range images {
+		si, err := s.sign(ctx, image)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		signed = append(signed, *si)
+	}
+
+	span.SetAttributes(attribute.Int("app.image.count", len(signed)))
+
+	return signed, nil
+}
+
+func (s *productImageService) GetImage(ctx context.Context, productID string, imageID string) (*SignedImage, error) {
+	ctx, span := productImageTracer.Start(ctx, "ProductImageService.GetImage")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("app.product.id", productID),
+		attribute.String("app.image.id", imageID),
+	)
+
+	if _, err := s.products.FindByID(ctx, productID); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	image, err := s.find(ctx, productID, imageID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	signed, err := s.sign(ctx, image)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return signed, nil
+}
+
+// DeleteImage forgets the image first; files left behind by a failed removal
+// are unreachable and only cost storage.
+func (s *productImageService) DeleteImage(ctx context.Context, caller *auth.Principal, productID string, imageID string) error {
+	ctx, span := productImageTracer.Start(ctx, "ProductImageService.DeleteImage")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("app.product.id", productID),
+		attribute.String("app.image.id", imageID),
+	)
+
+	if err := s.checkOwner(ctx, caller, productID); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	image, err := s.find(ctx, productID, imageID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, image.ID); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	s.removeFiles(ctx, image)
+	return nil
+}
+
+// PurgeImages forgets the images first, like DeleteImage, and then removes
+// their files on a best-effort basis.
+func (s *productImageService) PurgeImages(ctx context.Context, productIDs []string) error {
+	ctx, span := productImageTracer.Start(ctx, "ProductImageService.PurgeImages")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("app.product.count", len(productIDs)))
+
+	if len(productIDs) == 0 {
+		return nil
+	}
+
+	images, err := s.repo.DeleteAllByProductIDs(ctx, productIDs)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	span.SetAttributes(attribute.Int("app.image.count", len(images)))
+
+	for _, image := range images {
+		s.removeFiles(ctx, image)
+	}
+	return nil
+}
+
+// checkOwner lets the product's owner or an admin change its images.
+func (s *productImageService) checkOwner(ctx context.Context, caller *auth.Principal, productID string) error {
+	product, err := s.products.FindByID(ctx, productID)
+	if err != nil {
+		return err
+	}
+	if !caller.CanActOn(product.UserID) {
+		return domain.ErrForbidden
+	}
+	return nil
+}
+
+// find loads an image, treating one that belongs to another product as
+// missing.
+func (s *productImageService) find(ctx context.Context, productID string, imageID string) (*domain.ProductImage, error) {
+	image, err := s.repo.FindByID(ctx, imageID)
+	if err != nil {
+		return nil, err
+	}
+	if image.ProductID != productID {
+		return nil, domain.ErrImageNotFound
+	}
+	return image, nil
+}
+
+func (s *productImageService) sign(ctx context.Context, image *domain.ProductImage) (*SignedImage, error) {
+	expiresAt := time.Now().Add(s.urlTTL)
+	url, err := s.store.SignedURL(ctx, image.Key, s.urlTTL)
+	if err != nil {
+		return nil, err
+	}
+	thumbnailURL, err := s.store.SignedURL(ctx, image.ThumbnailKey, s.urlTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedImage{Image: image, URL: url, ThumbnailURL: thumbnailURL, ExpiresAt: expiresAt}, nil
+}
+
+// removeFiles is best effort; failures are only recorded on the span.
+func (s *productImageService) removeFiles(ctx context.Context, image *domain.ProductImage) {
+	span := oteltrace.SpanFromContext(ctx)
+	for _, key := range []string{image.Key, image.ThumbnailKey} {
+		if err := s.store.Delete(ctx, key); err != nil {
+			span.RecordError(err)
+		}
+	}
+}
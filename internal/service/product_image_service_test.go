@@ -0,0 +1,142 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository/memory"
+	"github.com/yusirdemir/microservice/pkg/blobstore"
+)
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 32, 32))); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestProductService_PurgeCleansUp(t *testing.T) {
+	ctx := context.Background()
+	owner := &auth.Principal{UserID: "owner", Roles: []domain.Role{domain.RoleSeller}}
+
+	products := memory.NewProductRepository()
+	imageRepo := memory.NewProductImageRepository()
+	store, err := blobstore.NewLocalStore(t.TempDir(), "http://localhost:3000", "test-secret")
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	images := NewProductImageService(imageRepo, products, store, 1<<20, 2, 16, 2, time.Minute)
+	// Without a grace period, a soft-deleted product is due for purging at once.
+	search := memory.NewSearchIndex()
+	svc := NewProductService(products, memory.NewCategoryRepository(), search, images, 0, "USD")
+
+	upload := func(t *testing.T) (*domain.Product, *domain.ProductImage) {
+		t.Helper()
+		product, err := svc.CreateProduct(ctx, owner.UserID, "Keyboard", 100, "", 5, "", nil)
+		if err != nil {
+			t.Fatalf("CreateProduct: %v", err)
+		}
+		signed, err := images.UploadImage(ctx, owner, product.ID, testPNG(t))
+		if err != nil {
+			t.Fatalf("UploadImage: %v", err)
+		}
+		return product, signed.Image
+	}
+
+	assertGone := func(t *testing.T, product *domain.Product, img *domain.ProductImage) {
+		t.Helper()
+		hits, err := search.Search(ctx, product.Name, 10)
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		for _, hit := range hits {
+			if hit.ID == product.ID {
+				t.Fatal("expected the product to be gone from the search index")
+			}
+		}
+		if _, err := imageRepo.FindByID(ctx, img.ID); !errors.Is(err, domain.ErrImageNotFound) {
+			t.Fatalf("expected the image record to be gone, got %v", err)
+		}
+		for _, key := range []string{img.Key, img.ThumbnailKey} {
+			if _, err := store.Get(ctx, key); !errors.Is(err, blobstore.ErrNotFound) {
+				t.Fatalf("expected blob %s to be gone, got %v", key, err)
+			}
+		}
+	}
+
+	t.Run("purging deleted products", func(t *testing.T) {
+		product, img := upload(t)
+		if err := svc.DeleteProduct(ctx, owner, product.ID, 0); err != nil {
+			t.Fatalf("DeleteProduct: %v", err)
+		}
+		if err := svc.PurgeDeleted(ctx); err != nil {
+			t.Fatalf("PurgeDeleted: %v", err)
+		}
+		assertGone(t, product, img)
+	})
+
+	t.Run("purging a user's products", func(t *testing.T) {
+		product, img := upload(t)
+		if err := svc.PurgeAllByUserID(ctx, owner.UserID); err != nil {
+			t.Fatalf("PurgeAllByUserID: %v", err)
+		}
+		assertGone(t, product, img)
+	})
+}
+
+func TestProductImageService_UploadLimitConcurrent(t *testing.T) {
+	ctx := context.Background()
+	owner := &auth.Principal{UserID: "owner", Roles: []domain.Role{domain.RoleSeller}}
+
+	products := memory.NewProductRepository()
+	images, _ := newImageService(t, products)
+	svc := NewProductService(products, memory.NewCategoryRepository(), memory.NewSearchIndex(), images, time.Hour, "USD")
+
+	product, err := svc.CreateProduct(ctx, owner.UserID, "Keyboard", 100, "", 5, "", nil)
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+
+	data := testPNG(t)
+	const attempts = 8
+	errs := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := range attempts {
+		wg.Go(func() {
+			_, errs[i] = images.UploadImage(ctx, owner, product.ID, data)
+		})
+	}
+	wg.Wait()
+
+	uploaded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			uploaded++
+		case !errors.Is(err, domain.ErrImageLimitReached):
+			t.Errorf("expected ErrImageLimitReached, got %v", err)
+		}
+	}
+	// newImageService allows two images per product.
+	if uploaded != 2 {
+		t.Fatalf("expected exactly 2 uploads to succeed, got %d", uploaded)
+	}
+
+	listed, err := images.ListImages(ctx, product.ID)
+	if err != nil {
+		t.Fatalf("ListImages: %v", err)
+	}
+	if len(listed) != 2 {
+		t.Fatalf("expected 2 stored images, got %d", len(listed))
+	}
+}
@@ -2,50 +2,101 @@ package service
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"time"
 
+	"github.com/yusirdemir/microservice/internal/auth"
 	"github.com/yusirdemir/microservice/internal/domain"
 	"github.com/yusirdemir/microservice/internal/repository"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 var productTracer = otel.Tracer("microservice/service/product")
 
+var errUnknownCategory = errors.New("category_id does not name an existing category")
+
 type ProductService interface {
-	CreateProduct(ctx context.Context, userID string, name string, price int, stock int) (*domain.Product, error)
+	CreateProduct(ctx context.Context, userID string, name string, price int64, currency string, stock int, categoryID string, tags []string) (*domain.Product, error)
 	GetProduct(ctx context.Context, id string) (*domain.Product, error)
-	GetAllProductsByUserID(ctx context.Context, userID string) ([]*domain.Product, error)
-	UpdateProduct(ctx context.Context, id, name string, price int, stock int) (*domain.Product, error)
-	DeleteProduct(ctx context.Context, id string) error
+	ListProductsByUserID(ctx context.Context, userID string, page repository.PageRequest) (*repository.ProductPage, error)
+	ListProducts(ctx context.Context, query repository.ProductQuery) (*repository.ProductPage, error)
+	SearchProducts(ctx context.Context, query string, limit int) ([]ProductMatch, error)
+	UpdateProduct(ctx context.Context, caller *auth.Principal, id string, version uint64, patch domain.ProductPatch) (*domain.Product, error)
+	AdjustStock(ctx context.Context, caller *auth.Principal, id string, delta int) (*domain.Product, error)
+	DeleteProduct(ctx context.Context, caller *auth.Principal, id string, version uint64) error
+	RestoreProduct(ctx context.Context, caller *auth.Principal, id string) (*domain.Product, error)
+	PurgeDeleted(ctx context.Context) error
+	// PurgeAllByUserID hard-deletes every product of the user, live or not.
+	PurgeAllByUserID(ctx context.Context, userID string) error
+	// ApplyBatch runs up to MaxBatchSize operations for caller and reports
+	// each one's outcome in order. Without atomic, every operation stands on
+	// its own; with it, one failure leaves the catalog untouched and the
+	// other operations fail with domain.ErrBatchAborted. The error is only
+	// set when the batch as a whole could not run.
+	ApplyBatch(ctx context.Context, caller *auth.Principal, items []BatchItem, atomic bool) ([]BatchResult, error)
+}
+
+// ProductMatch is a search result with its relevance score.
+type ProductMatch struct {
+	Product *domain.Product
+	Score   float64
 }
 
 type productService struct {
-	repo repository.ProductRepository
+	repo            repository.ProductRepository
+	categories      repository.CategoryRepository
+	search          repository.SearchIndex
+	images          ProductImageService
+	gracePeriod     time.Duration
+	defaultCurrency string
 }
 
-func NewProductService(repo repository.ProductRepository) ProductService {
+// NewProductService keeps search in step with every product it writes. An
+// index failure does not fail the write; the product is saved either way.
+// Products created without a currency are priced in defaultCurrency.
+// Categories are consulted to check a product's category and to expand a
+// category filter to its subtree. Images go away with products that are
+// hard-deleted.
+func NewProductService(repo repository.ProductRepository, categories repository.CategoryRepository, search repository.SearchIndex, images ProductImageService, gracePeriod time.Duration, defaultCurrency string) ProductService {
 	return &productService{
-		repo: repo,
+		repo:            repo,
+		categories:      categories,
+		search:          search,
+		images:          images,
+		gracePeriod:     gracePeriod,
+		defaultCurrency: defaultCurrency,
 	}
 }
 
-func (s *productService) CreateProduct(ctx context.Context, userID string, name string, price int, stock int) (*domain.Product, error) {
+// CreateProduct takes the price in minor units of currency. An empty
+// categoryID leaves the product uncategorized.
+func (s *productService) CreateProduct(ctx context.Context, userID string, name string, price int64, currency string, stock int, categoryID string, tags []string) (*domain.Product, error) {
 	ctx, span := productTracer.Start(ctx, "ProductService.CreateProduct")
 	defer span.End()
 
 	span.SetAttributes(attribute.String("app.user.id", userID))
 
-	product, err := domain.NewProduct("", userID, name, price, stock)
+	product, err := s.newProduct(userID, name, price, currency, stock, categoryID, tags)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
 
+	if err := s.checkCategory(ctx, product.CategoryID); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
 	span.SetAttributes(
 		attribute.String("app.product.id", product.ID),
-		attribute.Int("app.product.price", product.Price),
+		attribute.Int64("app.product.price", product.Price.Amount()),
+		attribute.String("app.product.currency", product.Price.Currency()),
 	)
 
 	if err := s.repo.Create(ctx, product); err != nil {
@@ -54,6 +105,33 @@ func (s *productService) CreateProduct(ctx context.Context, userID string, name
 		return nil, err
 	}
 
+	if err := s.search.Index(ctx, product); err != nil {
+		span.RecordError(err)
+	}
+
+	return product, nil
+}
+
+// newProduct builds a product the way CreateProduct describes, leaving the
+// category unchecked.
+func (s *productService) newProduct(userID string, name string, price int64, currency string, stock int, categoryID string, tags []string) (*domain.Product, error) {
+	if currency == "" {
+		currency = s.defaultCurrency
+	}
+	money, err := domain.NewMoney(price, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	product, err := domain.NewProduct("", userID, name, money, stock)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := product.ApplyPatch(domain.ProductPatch{CategoryID: &categoryID, Tags: &tags}, product.CreatedAt); err != nil {
+		return nil, err
+	}
+
 	return product, nil
 }
 
@@ -73,45 +151,138 @@ func (s *productService) GetProduct(ctx context.Context, id string) (*domain.Pro
 	return product, nil
 }
 
-func (s *productService) GetAllProductsByUserID(ctx context.Context, userID string) ([]*domain.Product, error) {
-	ctx, span := productTracer.Start(ctx, "ProductService.GetAllByUserID")
+func (s *productService) ListProductsByUserID(ctx context.Context, userID string, page repository.PageRequest) (*repository.ProductPage, error) {
+	ctx, span := productTracer.Start(ctx, "ProductService.ListByUserID")
 	defer span.End()
 
-	span.SetAttributes(attribute.String("app.user.id", userID))
+	page.Normalize()
+	span.SetAttributes(
+		attribute.String("app.user.id", userID),
+		attribute.Int("app.query.limit", page.Limit),
+	)
 
-	products, err := s.repo.FindAllByUserID(ctx, userID)
+	result, err := s.repo.FindPageByUserID(ctx, userID, page)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
 
-	span.SetAttributes(attribute.Int("app.product.count", len(products)))
+	span.SetAttributes(attribute.Int("app.product.count", len(result.Products)))
 
-	return products, nil
+	return result, nil
 }
 
-func (s *productService) UpdateProduct(ctx context.Context, id, name string, price int, stock int) (*domain.Product, error) {
+func (s *productService) ListProducts(ctx context.Context, query repository.ProductQuery) (*repository.ProductPage, error) {
+	ctx, span := productTracer.Start(ctx, "ProductService.ListProducts")
+	defer span.End()
+
+	query.Normalize()
+	span.SetAttributes(
+		attribute.String("app.query.sort", string(query.Sort)),
+		attribute.Int("app.query.limit", query.Limit),
+	)
+
+	if err := query.Validate(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if len(query.CategoryIDs) > 0 {
+		categories, err := s.categories.FindAll(ctx)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+
+		var subtrees []string
+		for _, id := range query.CategoryIDs {
+			subtrees = append(subtrees, domain.Subtree(categories, id)...)
+		}
+		slices.Sort(subtrees)
+		query.CategoryIDs = slices.Compact(subtrees)
+		span.SetAttributes(attribute.Int("app.query.categories", len(query.CategoryIDs)))
+	}
+
+	page, err := s.repo.List(ctx, query)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("app.product.count", len(page.Products)))
+
+	return page, nil
+}
+
+// SearchProducts ranks products by name relevance. Hits are re-read from the
+// repository, which drops any the index still holds for products that are
+// soft-deleted along with their owner's account.
+func (s *productService) SearchProducts(ctx context.Context, query string, limit int) ([]ProductMatch, error) {
+	ctx, span := productTracer.Start(ctx, "ProductService.SearchProducts")
+	defer span.End()
+
+	page := repository.PageRequest{Limit: limit}
+	page.Normalize()
+	span.SetAttributes(attribute.Int("app.query.limit", page.Limit))
+
+	hits, err := s.search.Search(ctx, query, page.Limit)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	matches := make([]ProductMatch, 0, len(hits))
+	for _, hit := range hits {
+		product, err := s.repo.FindByID(ctx, hit.ID)
+		if err != nil {
+			if errors.Is(err, domain.ErrProductNotFound) {
+				continue
+			}
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		matches = append(matches, ProductMatch{Product: product, Score: hit.Score})
+	}
+
+	span.SetAttributes(attribute.Int("app.product.count", len(matches)))
+
+	return matches, nil
+}
+
+// UpdateProduct and DeleteProduct take the version the caller last saw, or
+// zero to skip that check; either way a concurrent write in between fails
+// with domain.ErrVersionMismatch rather than being overwritten.
+func (s *productService) UpdateProduct(ctx context.Context, caller *auth.Principal, id string, version uint64, patch domain.ProductPatch) (*domain.Product, error) {
 	ctx, span := productTracer.Start(ctx, "ProductService.UpdateProduct")
 	defer span.End()
 
 	span.SetAttributes(attribute.String("app.product.id", id))
 
-	product, err := s.repo.FindByID(ctx, id)
+	product, err := s.findOwned(ctx, caller, id, version)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
 
-	if name != "" {
-		product.Name = name
-	}
-	if price > 0 {
-		product.Price = price
+	if err := product.ApplyPatch(patch, time.Now()); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
 	}
-	if stock >= 0 {
-		product.Stock = stock
+
+	if patch.CategoryID != nil {
+		if err := s.checkCategory(ctx, product.CategoryID); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
 	}
 
 	if err := s.repo.Update(ctx, product); err != nil {
@@ -120,20 +291,203 @@ func (s *productService) UpdateProduct(ctx context.Context, id, name string, pri
 		return nil, err
 	}
 
+	if err := s.search.Index(ctx, product); err != nil {
+		span.RecordError(err)
+	}
+
 	return product, nil
 }
 
-func (s *productService) DeleteProduct(ctx context.Context, id string) error {
+// AdjustStock changes the stock by delta without a read-modify-write in the
+// service, so concurrent adjustments cannot overwrite each other.
+func (s *productService) AdjustStock(ctx context.Context, caller *auth.Principal, id string, delta int) (*domain.Product, error) {
+	ctx, span := productTracer.Start(ctx, "ProductService.AdjustStock")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("app.product.id", id),
+		attribute.Int("app.stock.delta", delta),
+	)
+
+	if _, err := s.findOwned(ctx, caller, id, 0); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	product, err := s.repo.AdjustStock(ctx, id, delta)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return product, nil
+}
+
+func (s *productService) DeleteProduct(ctx context.Context, caller *auth.Principal, id string, version uint64) error {
 	ctx, span := productTracer.Start(ctx, "ProductService.DeleteProduct")
 	defer span.End()
 
 	span.SetAttributes(attribute.String("app.product.id", id))
 
-	if err := s.repo.Delete(ctx, id); err != nil {
+	product, err := s.findOwned(ctx, caller, id, version)
+	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return err
 	}
 
+	product.SoftDelete(time.Now().UTC())
+	if err := s.repo.Update(ctx, product); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := s.search.Remove(ctx, product.ID); err != nil {
+		span.RecordError(err)
+	}
+
+	return nil
+}
+
+func (s *productService) RestoreProduct(ctx context.Context, caller *auth.Principal, id string) (*domain.Product, error) {
+	ctx, span := productTracer.Start(ctx, "ProductService.RestoreProduct")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.product.id", id))
+
+	product, err := s.repo.FindDeletedByID(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if !caller.CanActOn(product.UserID) {
+		err := domain.ErrForbidden
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if err := product.Restore(time.Now(), s.gracePeriod); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, product); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if err := s.search.Index(ctx, product); err != nil {
+		span.RecordError(err)
+	}
+
+	return product, nil
+}
+
+// PurgeDeleted hard-deletes products whose restore grace period has run out.
+func (s *productService) PurgeDeleted(ctx context.Context) error {
+	ctx, span := productTracer.Start(ctx, "ProductService.PurgeDeleted")
+	defer span.End()
+
+	ids, err := s.repo.DeleteAllDeletedBefore(ctx, time.Now().Add(-s.gracePeriod))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	span.SetAttributes(attribute.Int("app.product.purged", len(ids)))
+
+	if err := s.forget(ctx, ids); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (s *productService) PurgeAllByUserID(ctx context.Context, userID string) error {
+	ctx, span := productTracer.Start(ctx, "ProductService.PurgeAllByUserID")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.user.id", userID))
+
+	ids, err := s.repo.DeleteAllByUserID(ctx, userID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	span.SetAttributes(attribute.Int("app.product.purged", len(ids)))
+
+	if err := s.forget(ctx, ids); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// forget removes what hangs off hard-deleted products: their search entries
+// and their images. Like every other index write, a failed removal from
+// search is only recorded.
+func (s *productService) forget(ctx context.Context, ids []string) error {
+	span := oteltrace.SpanFromContext(ctx)
+	for _, id := range ids {
+		if err := s.search.Remove(ctx, id); err != nil {
+			span.RecordError(err)
+		}
+	}
+	return s.images.PurgeImages(ctx, ids)
+}
+
+// findOwned loads a product the caller is allowed to modify: its owner or an
+// admin. A non-zero version must match the stored one.
+func (s *productService) findOwned(ctx context.Context, caller *auth.Principal, id string, version uint64) (*domain.Product, error) {
+	product, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !caller.CanActOn(product.UserID) {
+		return nil, domain.ErrForbidden
+	}
+
+	if err := matchVersion(product.Version, version); err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+// checkCategory accepts an empty ID, which leaves a product uncategorized.
+func (s *productService) checkCategory(ctx context.Context, id string) error {
+	if id == "" {
+		return nil
+	}
+	if _, err := s.categories.FindByID(ctx, id); err != nil {
+		if errors.Is(err, domain.ErrCategoryNotFound) {
+			return errUnknownCategory
+		}
+		return err
+	}
+	return nil
+}
+
+// matchVersion checks an If-Match style precondition; zero expects nothing.
+func matchVersion(current, expected uint64) error {
+	if expected != 0 && expected != current {
+		return domain.ErrVersionMismatch
+	}
 	return nil
 }
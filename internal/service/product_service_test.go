@@ -0,0 +1,716 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/repository/couchbase"
+	"github.com/yusirdemir/microservice/internal/repository/memory"
+	"github.com/yusirdemir/microservice/pkg/blobstore"
+	"github.com/yusirdemir/microservice/pkg/config"
+)
+
+// productRepositories returns a constructor per driver. The Couchbase driver
+// only runs when COUCHBASE_TEST_HOST points at a reachable cluster.
+func productRepositories() map[string]func(t *testing.T) repository.ProductRepository {
+	return map[string]func(t *testing.T) repository.ProductRepository{
+		"memory": func(t *testing.T) repository.ProductRepository {
+			return memory.NewProductRepository()
+		},
+		"couchbase": func(t *testing.T) repository.ProductRepository {
+			host := os.Getenv("COUCHBASE_TEST_HOST")
+			if host == "" {
+				t.Skip("COUCHBASE_TEST_HOST is not set")
+			}
+
+			conn, err := couchbase.Connect(config.DatabaseConfig{
+				Driver:   "couchbase",
+				Host:     host,
+				Bucket:   os.Getenv("COUCHBASE_TEST_BUCKET"),
+				Username: os.Getenv("COUCHBASE_TEST_USERNAME"),
+				Password: os.Getenv("COUCHBASE_TEST_PASSWORD"),
+			})
+			if err != nil {
+				t.Fatalf("failed to connect to couchbase: %v", err)
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+
+			repo, err := couchbase.NewProductRepository(conn, "USD")
+			if err != nil {
+				t.Fatalf("NewProductRepository: %v", err)
+			}
+			return repo
+		},
+	}
+}
+
+// newImageService stores image files under a temporary directory.
+func newImageService(t *testing.T, products repository.ProductRepository) (ProductImageService, blobstore.BlobStore) {
+	t.Helper()
+	store, err := blobstore.NewLocalStore(t.TempDir(), "http://localhost:3000", "test-secret")
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	return NewProductImageService(memory.NewProductImageRepository(), products, store, 1<<20, 2, 16, 2, time.Minute), store
+}
+
+// newProductService uses a one hour grace period and prices in USD by
+// default.
+func newProductService(t *testing.T, repo repository.ProductRepository, categories repository.CategoryRepository, search repository.SearchIndex) ProductService {
+	t.Helper()
+	images, _ := newImageService(t, repo)
+	return NewProductService(repo, categories, search, images, time.Hour, "USD")
+}
+
+// racingProductRepository runs beforeAtomic once, right before the first
+// WriteBatchAtomic call goes through.
+type racingProductRepository struct {
+	repository.ProductRepository
+	beforeAtomic func()
+}
+
+func (r *racingProductRepository) WriteBatchAtomic(ctx context.Context, writes []repository.ProductWrite) error {
+	if r.beforeAtomic != nil {
+		r.beforeAtomic()
+		r.beforeAtomic = nil
+	}
+	return r.ProductRepository.WriteBatchAtomic(ctx, writes)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestProductService_Ownership(t *testing.T) {
+	owner := &auth.Principal{UserID: "owner", Roles: []domain.Role{domain.RoleSeller}}
+	other := &auth.Principal{UserID: "other", Roles: []domain.Role{domain.RoleSeller}}
+	admin := &auth.Principal{UserID: "admin", Roles: []domain.Role{domain.RoleAdmin}}
+
+	for driver, newRepo := range productRepositories() {
+		t.Run(driver, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+			svc := newProductService(t, repo, memory.NewCategoryRepository(), memory.NewSearchIndex())
+
+			product, err := svc.CreateProduct(ctx, owner.UserID, "Keyboard", 100, "", 5, "", nil)
+			if err != nil {
+				t.Fatalf("CreateProduct: %v", err)
+			}
+			t.Cleanup(func() { _ = repo.Delete(context.Background(), product.ID) })
+
+			t.Run("other user cannot update", func(t *testing.T) {
+				_, err := svc.UpdateProduct(ctx, other, product.ID, 0, domain.ProductPatch{Name: ptr("Stolen"), Price: ptr(int64(1)), Stock: ptr(0)})
+				if !errors.Is(err, domain.ErrForbidden) {
+					t.Fatalf("expected ErrForbidden, got %v", err)
+				}
+
+				got, err := svc.GetProduct(ctx, product.ID)
+				if err != nil {
+					t.Fatalf("GetProduct: %v", err)
+				}
+				if got.Name != "Keyboard" {
+					t.Fatalf("product was modified: name = %q", got.Name)
+				}
+			})
+
+			t.Run("other user cannot delete", func(t *testing.T) {
+				err := svc.DeleteProduct(ctx, other, product.ID, 0)
+				if !errors.Is(err, domain.ErrForbidden) {
+					t.Fatalf("expected ErrForbidden, got %v", err)
+				}
+				if _, err := svc.GetProduct(ctx, product.ID); err != nil {
+					t.Fatalf("product was deleted: %v", err)
+				}
+			})
+
+			t.Run("owner can update", func(t *testing.T) {
+				got, err := svc.UpdateProduct(ctx, owner, product.ID, 0, domain.ProductPatch{Name: ptr("Mechanical Keyboard"), Price: ptr(int64(120)), Stock: ptr(4)})
+				if err != nil {
+					t.Fatalf("UpdateProduct: %v", err)
+				}
+				if got.Name != "Mechanical Keyboard" || got.Price.Amount() != 120 || got.Stock != 4 {
+					t.Fatalf("unexpected product after update: %+v", got)
+				}
+			})
+
+			t.Run("admin can update", func(t *testing.T) {
+				if _, err := svc.UpdateProduct(ctx, admin, product.ID, 0, domain.ProductPatch{Name: ptr("Moderated"), Stock: ptr(4)}); err != nil {
+					t.Fatalf("UpdateProduct: %v", err)
+				}
+			})
+
+			t.Run("owner can delete", func(t *testing.T) {
+				if err := svc.DeleteProduct(ctx, owner, product.ID, 0); err != nil {
+					t.Fatalf("DeleteProduct: %v", err)
+				}
+				if _, err := svc.GetProduct(ctx, product.ID); !errors.Is(err, domain.ErrProductNotFound) {
+					t.Fatalf("expected ErrProductNotFound, got %v", err)
+				}
+			})
+
+			t.Run("other user cannot restore", func(t *testing.T) {
+				_, err := svc.RestoreProduct(ctx, other, product.ID)
+				if !errors.Is(err, domain.ErrForbidden) {
+					t.Fatalf("expected ErrForbidden, got %v", err)
+				}
+			})
+
+			t.Run("owner can restore", func(t *testing.T) {
+				if _, err := svc.RestoreProduct(ctx, owner, product.ID); err != nil {
+					t.Fatalf("RestoreProduct: %v", err)
+				}
+				if _, err := svc.GetProduct(ctx, product.ID); err != nil {
+					t.Fatalf("GetProduct after restore: %v", err)
+				}
+			})
+
+			t.Run("missing product", func(t *testing.T) {
+				err := svc.DeleteProduct(ctx, admin, "does-not-exist", 0)
+				if !errors.Is(err, domain.ErrProductNotFound) {
+					t.Fatalf("expected ErrProductNotFound, got %v", err)
+				}
+			})
+		})
+	}
+}
+
+func TestProductService_UpdateIsPartial(t *testing.T) {
+	owner := &auth.Principal{UserID: "owner", Roles: []domain.Role{domain.RoleSeller}}
+
+	for driver, newRepo := range productRepositories() {
+		t.Run(driver, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+			svc := newProductService(t, repo, memory.NewCategoryRepository(), memory.NewSearchIndex())
+
+			product, err := svc.CreateProduct(ctx, owner.UserID, "Notebook", 15, "", 7, "", nil)
+			if err != nil {
+				t.Fatalf("CreateProduct: %v", err)
+			}
+			t.Cleanup(func() { _ = repo.Delete(context.Background(), product.ID) })
+
+			t.Run("omitted fields are kept", func(t *testing.T) {
+				got, err := svc.UpdateProduct(ctx, owner, product.ID, 0, domain.ProductPatch{Price: ptr(int64(18))})
+				if err != nil {
+					t.Fatalf("UpdateProduct: %v", err)
+				}
+				if got.Name != "Notebook" || got.Price.Amount() != 18 || got.Stock != 7 {
+					t.Fatalf("unexpected product after patch: %+v", got)
+				}
+			})
+
+			t.Run("currency change keeps the amount", func(t *testing.T) {
+				got, err := svc.UpdateProduct(ctx, owner, product.ID, 0, domain.ProductPatch{Currency: ptr("eur")})
+				if err != nil {
+					t.Fatalf("UpdateProduct: %v", err)
+				}
+				if got.Price.Amount() != 18 || got.Price.Currency() != "EUR" {
+					t.Fatalf("price = %v, want 0.18 EUR", got.Price)
+				}
+			})
+
+			t.Run("invalid patch changes nothing", func(t *testing.T) {
+				_, err := svc.UpdateProduct(ctx, owner, product.ID, 0, domain.ProductPatch{Name: ptr("Renamed"), Stock: ptr(-1)})
+				if err == nil {
+					t.Fatal("expected a validation error")
+				}
+
+				got, err := svc.GetProduct(ctx, product.ID)
+				if err != nil {
+					t.Fatalf("GetProduct: %v", err)
+				}
+				if got.Name != "Notebook" || got.Stock != 7 {
+					t.Fatalf("product was modified: %+v", got)
+				}
+			})
+		})
+	}
+}
+
+func TestProductService_VersionPreconditions(t *testing.T) {
+	owner := &auth.Principal{UserID: "owner", Roles: []domain.Role{domain.RoleSeller}}
+
+	for driver, newRepo := range productRepositories() {
+		t.Run(driver, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+			svc := newProductService(t, repo, memory.NewCategoryRepository(), memory.NewSearchIndex())
+
+			product, err := svc.CreateProduct(ctx, owner.UserID, "Desk Lamp", 40, "", 3, "", nil)
+			if err != nil {
+				t.Fatalf("CreateProduct: %v", err)
+			}
+			t.Cleanup(func() { _ = repo.Delete(context.Background(), product.ID) })
+			if product.Version == 0 {
+				t.Fatal("created product has no version")
+			}
+
+			t.Run("matching version updates", func(t *testing.T) {
+				got, err := svc.UpdateProduct(ctx, owner, product.ID, product.Version, domain.ProductPatch{Name: ptr("Floor Lamp")})
+				if err != nil {
+					t.Fatalf("UpdateProduct: %v", err)
+				}
+				if got.Version == product.Version {
+					t.Fatal("version did not change on update")
+				}
+			})
+
+			t.Run("stale version is rejected", func(t *testing.T) {
+				_, err := svc.UpdateProduct(ctx, owner, product.ID, product.Version, domain.ProductPatch{Name: ptr("Stale")})
+				if !errors.Is(err, domain.ErrVersionMismatch) {
+					t.Fatalf("expected ErrVersionMismatch, got %v", err)
+				}
+				if err := svc.DeleteProduct(ctx, owner, product.ID, product.Version); !errors.Is(err, domain.ErrVersionMismatch) {
+					t.Fatalf("expected ErrVersionMismatch, got %v", err)
+				}
+			})
+
+			t.Run("stale read loses to a concurrent write", func(t *testing.T) {
+				first, err := repo.FindByID(ctx, product.ID)
+				if err != nil {
+					t.Fatalf("FindByID: %v", err)
+				}
+				second, err := repo.FindByID(ctx, product.ID)
+				if err != nil {
+					t.Fatalf("FindByID: %v", err)
+				}
+
+				first.Name = "First"
+				if err := repo.Update(ctx, first); err != nil {
+					t.Fatalf("Update: %v", err)
+				}
+				second.Name = "Second"
+				if err := repo.Update(ctx, second); !errors.Is(err, domain.ErrVersionMismatch) {
+					t.Fatalf("expected ErrVersionMismatch, got %v", err)
+				}
+
+				got, err := svc.GetProduct(ctx, product.ID)
+				if err != nil {
+					t.Fatalf("GetProduct: %v", err)
+				}
+				if got.Name != "First" {
+					t.Fatalf("name = %q, want %q", got.Name, "First")
+				}
+			})
+		})
+	}
+}
+
+func TestProductService_AdjustStockConcurrent(t *testing.T) {
+	owner := &auth.Principal{UserID: "owner", Roles: []domain.Role{domain.RoleSeller}}
+	const initial, buyers = 20, 50
+
+	for driver, newRepo := range productRepositories() {
+		t.Run(driver, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+			svc := newProductService(t, repo, memory.NewCategoryRepository(), memory.NewSearchIndex())
+
+			product, err := svc.CreateProduct(ctx, owner.UserID, "Limited Edition", 100, "", initial, "", nil)
+			if err != nil {
+				t.Fatalf("CreateProduct: %v", err)
+			}
+			t.Cleanup(func() { _ = repo.Delete(context.Background(), product.ID) })
+
+			t.Run("no oversell", func(t *testing.T) {
+				var (
+					wg       sync.WaitGroup
+					mu       sync.Mutex
+					sold     int
+					rejected int
+				)
+				for range buyers {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						_, err := svc.AdjustStock(ctx, owner, product.ID, -1)
+
+						mu.Lock()
+						defer mu.Unlock()
+						switch {
+						case err == nil:
+							sold++
+						case errors.Is(err, domain.ErrInsufficientStock):
+							rejected++
+						default:
+							t.Errorf("AdjustStock: %v", err)
+						}
+					}()
+				}
+				wg.Wait()
+
+				if sold != initial || rejected != buyers-initial {
+					t.Fatalf("sold %d and rejected %d, want %d and %d", sold, rejected, initial, buyers-initial)
+				}
+				got, err := svc.GetProduct(ctx, product.ID)
+				if err != nil {
+					t.Fatalf("GetProduct: %v", err)
+				}
+				if got.Stock != 0 {
+					t.Fatalf("stock = %d, want 0", got.Stock)
+				}
+			})
+
+			t.Run("no lost updates", func(t *testing.T) {
+				var wg sync.WaitGroup
+				for i := range buyers {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						delta := 3
+						if i%2 == 1 {
+							delta = -1
+						}
+						// Restocks and sales race each other; a sale may
+						// legitimately find the shelf empty, so retry it.
+						for {
+							_, err := svc.AdjustStock(ctx, owner, product.ID, delta)
+							if !errors.Is(err, domain.ErrInsufficientStock) {
+								if err != nil {
+									t.Errorf("AdjustStock: %v", err)
+								}
+								return
+							}
+						}
+					}()
+				}
+				wg.Wait()
+
+				got, err := svc.GetProduct(ctx, product.ID)
+				if err != nil {
+					t.Fatalf("GetProduct: %v", err)
+				}
+				if want := buyers / 2 * (3 - 1); got.Stock != want {
+					t.Fatalf("stock = %d, want %d", got.Stock, want)
+				}
+			})
+
+			t.Run("rejected adjustment changes nothing", func(t *testing.T) {
+				before, err := svc.GetProduct(ctx, product.ID)
+				if err != nil {
+					t.Fatalf("GetProduct: %v", err)
+				}
+				_, err = svc.AdjustStock(ctx, owner, product.ID, -before.Stock-1)
+				if !errors.Is(err, domain.ErrInsufficientStock) {
+					t.Fatalf("expected ErrInsufficientStock, got %v", err)
+				}
+				after, err := svc.GetProduct(ctx, product.ID)
+				if err != nil {
+					t.Fatalf("GetProduct: %v", err)
+				}
+				if after.Stock != before.Stock {
+					t.Fatalf("stock = %d, want %d", after.Stock, before.Stock)
+				}
+			})
+		})
+	}
+}
+
+func TestProductService_CategoryAndTagFilters(t *testing.T) {
+	ctx := context.Background()
+	products := memory.NewProductRepository()
+	categoryRepo := memory.NewCategoryRepository()
+	categories := NewCategoryService(categoryRepo, products)
+	svc := newProductService(t, products, categoryRepo, memory.NewSearchIndex())
+
+	newCategory := func(parentID, name string) string {
+		c, err := categories.CreateCategory(ctx, parentID, name)
+		if err != nil {
+			t.Fatalf("CreateCategory(%q): %v", name, err)
+		}
+		return c.ID
+	}
+	electronics := newCategory("", "Electronics")
+	computers := newCategory(electronics, "Computers")
+	laptops := newCategory(computers, "Laptops")
+	books := newCategory("", "Books")
+
+	newProduct := func(name, categoryID string, tags ...string) string {
+		p, err := svc.CreateProduct(ctx, "owner", name, 100, "", 1, categoryID, tags)
+		if err != nil {
+			t.Fatalf("CreateProduct(%q): %v", name, err)
+		}
+		return p.ID
+	}
+	laptop := newProduct("Laptop", laptops, " Sale", "new", "sale")
+	desktop := newProduct("Desktop", computers)
+	novel := newProduct("Novel", books, "SALE")
+	loose := newProduct("Sticker", "")
+
+	list := func(query repository.ProductQuery) []string {
+		t.Helper()
+		query.Sort = repository.ProductSortName
+		page, err := svc.ListProducts(ctx, query)
+		if err != nil {
+			t.Fatalf("ListProducts: %v", err)
+		}
+		ids := make([]string, 0, len(page.Products))
+		for _, p := range page.Products {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	cases := []struct {
+		name  string
+		query repository.ProductQuery
+		want  []string
+	}{
+		{"no filter", repository.ProductQuery{}, []string{desktop, laptop, novel, loose}},
+		{"root category covers its subtree", repository.ProductQuery{CategoryIDs: []string{electronics}}, []string{desktop, laptop}},
+		{"leaf category", repository.ProductQuery{CategoryIDs: []string{laptops}}, []string{laptop}},
+		{"several categories", repository.ProductQuery{CategoryIDs: []string{laptops, books}}, []string{laptop, novel}},
+		{"unknown category", repository.ProductQuery{CategoryIDs: []string{"missing"}}, []string{}},
+		{"tag", repository.ProductQuery{Tag: "sale"}, []string{laptop, novel}},
+		{"category and tag", repository.ProductQuery{CategoryIDs: []string{electronics}, Tag: "sale"}, []string{laptop}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := list(tc.query); !slices.Equal(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("tags are normalized", func(t *testing.T) {
+		got, err := svc.GetProduct(ctx, laptop)
+		if err != nil {
+			t.Fatalf("GetProduct: %v", err)
+		}
+		if !slices.Equal(got.Tags, []string{"new", "sale"}) {
+			t.Fatalf("tags = %v, want [new sale]", got.Tags)
+		}
+	})
+
+	t.Run("unknown category is refused", func(t *testing.T) {
+		if _, err := svc.CreateProduct(ctx, "owner", "Ghost", 100, "", 1, "missing", nil); !errors.Is(err, errUnknownCategory) {
+			t.Fatalf("err = %v, want errUnknownCategory", err)
+		}
+	})
+
+	t.Run("category cannot move below itself", func(t *testing.T) {
+		if _, err := categories.UpdateCategory(ctx, electronics, laptops, "Electronics"); !errors.Is(err, domain.ErrCategoryCycle) {
+			t.Fatalf("err = %v, want ErrCategoryCycle", err)
+		}
+	})
+
+	t.Run("category in use cannot be deleted", func(t *testing.T) {
+		if err := categories.DeleteCategory(ctx, computers); !errors.Is(err, domain.ErrCategoryInUse) {
+			t.Fatalf("delete with subcategories: err = %v, want ErrCategoryInUse", err)
+		}
+		if err := categories.DeleteCategory(ctx, books); !errors.Is(err, domain.ErrCategoryInUse) {
+			t.Fatalf("delete with products: err = %v, want ErrCategoryInUse", err)
+		}
+	})
+}
+
+func TestProductService_PriceFilters(t *testing.T) {
+	ctx := context.Background()
+	svc := newProductService(t, memory.NewProductRepository(), memory.NewCategoryRepository(), memory.NewSearchIndex())
+
+	newProduct := func(name string, price int64, currency string) string {
+		p, err := svc.CreateProduct(ctx, "owner", name, price, currency, 1, "", nil)
+		if err != nil {
+			t.Fatalf("CreateProduct(%q): %v", name, err)
+		}
+		return p.ID
+	}
+	cheap := newProduct("Cheap", 100, "USD")
+	dear := newProduct("Dear", 900, "USD")
+	newProduct("Yen", 500, "JPY")
+
+	t.Run("prices compare within a currency", func(t *testing.T) {
+		page, err := svc.ListProducts(ctx, repository.ProductQuery{
+			MinPrice: ptr(50),
+			Currency: "USD",
+			Sort:     repository.ProductSortPrice,
+		})
+		if err != nil {
+			t.Fatalf("ListProducts: %v", err)
+		}
+		var ids []string
+		for _, p := range page.Products {
+			ids = append(ids, p.ID)
+		}
+		if want := []string{cheap, dear}; !slices.Equal(ids, want) {
+			t.Fatalf("got %v, want %v", ids, want)
+		}
+	})
+
+	cases := []struct {
+		name  string
+		query repository.ProductQuery
+	}{
+		{"min_price", repository.ProductQuery{MinPrice: ptr(50)}},
+		{"max_price", repository.ProductQuery{MaxPrice: ptr(500)}},
+		{"sort by price", repository.ProductQuery{Sort: repository.ProductSortPrice}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name+" needs a currency", func(t *testing.T) {
+			if _, err := svc.ListProducts(ctx, tc.query); !errors.Is(err, repository.ErrPriceWithoutCurrency) {
+				t.Fatalf("err = %v, want ErrPriceWithoutCurrency", err)
+			}
+		})
+	}
+}
+
+func TestProductService_ApplyBatch(t *testing.T) {
+	owner := &auth.Principal{UserID: "owner", Roles: []domain.Role{domain.RoleSeller}}
+	other := &auth.Principal{UserID: "other", Roles: []domain.Role{domain.RoleSeller}}
+
+	for driver, newRepo := range productRepositories() {
+		t.Run(driver, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+			svc := newProductService(t, repo, memory.NewCategoryRepository(), memory.NewSearchIndex())
+
+			lamp, err := svc.CreateProduct(ctx, owner.UserID, "Desk Lamp", 40, "", 3, "", nil)
+			if err != nil {
+				t.Fatalf("CreateProduct: %v", err)
+			}
+			t.Cleanup(func() { _ = repo.Delete(context.Background(), lamp.ID) })
+			foreign, err := svc.CreateProduct(ctx, other.UserID, "Armchair", 300, "", 1, "", nil)
+			if err != nil {
+				t.Fatalf("CreateProduct: %v", err)
+			}
+			t.Cleanup(func() { _ = repo.Delete(context.Background(), foreign.ID) })
+
+			t.Run("failures do not stop the rest", func(t *testing.T) {
+				results, err := svc.ApplyBatch(ctx, owner, []BatchItem{
+					{Op: BatchCreate, Patch: domain.ProductPatch{Name: ptr("Bookshelf"), Price: ptr(int64(90)), Stock: ptr(2)}},
+					{Op: BatchUpdate, ID: lamp.ID, Patch: domain.ProductPatch{Stock: ptr(7)}},
+					{Op: BatchUpdate, ID: foreign.ID, Patch: domain.ProductPatch{Stock: ptr(0)}},
+					{Op: BatchDelete, ID: "missing"},
+					{Op: BatchCreate, Patch: domain.ProductPatch{Name: ptr("Free")}},
+				}, false)
+				if err != nil {
+					t.Fatalf("ApplyBatch: %v", err)
+				}
+				if results[0].Err != nil || results[1].Err != nil {
+					t.Fatalf("valid items failed: %v, %v", results[0].Err, results[1].Err)
+				}
+				t.Cleanup(func() { _ = repo.Delete(context.Background(), results[0].Product.ID) })
+				if !errors.Is(results[2].Err, domain.ErrForbidden) {
+					t.Fatalf("foreign update: err = %v, want ErrForbidden", results[2].Err)
+				}
+				if !errors.Is(results[3].Err, domain.ErrProductNotFound) {
+					t.Fatalf("missing delete: err = %v, want ErrProductNotFound", results[3].Err)
+				}
+				if results[4].Err == nil {
+					t.Fatal("create without a price succeeded")
+				}
+
+				got, err := svc.GetProduct(ctx, lamp.ID)
+				if err != nil {
+					t.Fatalf("GetProduct: %v", err)
+				}
+				if got.Stock != 7 {
+					t.Fatalf("stock = %d, want 7", got.Stock)
+				}
+				if _, err := svc.GetProduct(ctx, results[0].Product.ID); err != nil {
+					t.Fatalf("created product is missing: %v", err)
+				}
+			})
+
+			t.Run("atomic batch applies nothing on failure", func(t *testing.T) {
+				current, err := svc.GetProduct(ctx, lamp.ID)
+				if err != nil {
+					t.Fatalf("GetProduct: %v", err)
+				}
+
+				results, err := svc.ApplyBatch(ctx, owner, []BatchItem{
+					{Op: BatchUpdate, ID: lamp.ID, Version: current.Version, Patch: domain.ProductPatch{Name: ptr("Floor Lamp")}},
+					{Op: BatchCreate, Patch: domain.ProductPatch{Name: ptr("Stool"), Price: ptr(int64(25))}},
+					{Op: BatchDelete, ID: foreign.ID},
+				}, true)
+				if err != nil {
+					t.Fatalf("ApplyBatch: %v", err)
+				}
+				if !errors.Is(results[2].Err, domain.ErrForbidden) {
+					t.Fatalf("foreign delete: err = %v, want ErrForbidden", results[2].Err)
+				}
+				for _, i := range []int{0, 1} {
+					if !errors.Is(results[i].Err, domain.ErrBatchAborted) {
+						t.Fatalf("item %d: err = %v, want ErrBatchAborted", i, results[i].Err)
+					}
+				}
+
+				got, err := svc.GetProduct(ctx, lamp.ID)
+				if err != nil {
+					t.Fatalf("GetProduct: %v", err)
+				}
+				if got.Name != "Desk Lamp" {
+					t.Fatalf("name = %q, want it unchanged", got.Name)
+				}
+			})
+
+			t.Run("atomic batch rejects a stale write", func(t *testing.T) {
+				// The concurrent update lands after ApplyBatch has read and
+				// checked the lamp, so only the repository can catch it.
+				racing := &racingProductRepository{
+					ProductRepository: repo,
+					beforeAtomic: func() {
+						if _, err := svc.UpdateProduct(ctx, owner, lamp.ID, 0, domain.ProductPatch{Stock: ptr(8)}); err != nil {
+							t.Fatalf("UpdateProduct: %v", err)
+						}
+					},
+				}
+				racingSvc := newProductService(t, racing, memory.NewCategoryRepository(), memory.NewSearchIndex())
+
+				results, err := racingSvc.ApplyBatch(ctx, owner, []BatchItem{
+					{Op: BatchUpdate, ID: lamp.ID, Patch: domain.ProductPatch{Stock: ptr(1)}},
+					{Op: BatchCreate, Patch: domain.ProductPatch{Name: ptr("Ottoman"), Price: ptr(int64(60))}},
+				}, true)
+				if err != nil {
+					t.Fatalf("ApplyBatch: %v", err)
+				}
+				if !errors.Is(results[0].Err, domain.ErrVersionMismatch) {
+					t.Fatalf("stale update: err = %v, want ErrVersionMismatch", results[0].Err)
+				}
+				if !errors.Is(results[1].Err, domain.ErrBatchAborted) {
+					t.Fatalf("create: err = %v, want ErrBatchAborted", results[1].Err)
+				}
+
+				got, err := svc.GetProduct(ctx, lamp.ID)
+				if err != nil {
+					t.Fatalf("GetProduct: %v", err)
+				}
+				if got.Stock != 8 {
+					t.Fatalf("stock = %d, want the concurrent write's 8", got.Stock)
+				}
+			})
+
+			t.Run("atomic batch applies everything", func(t *testing.T) {
+				results, err := svc.ApplyBatch(ctx, owner, []BatchItem{
+					{Op: BatchUpdate, ID: lamp.ID, Patch: domain.ProductPatch{Name: ptr("Floor Lamp")}},
+					{Op: BatchCreate, Patch: domain.ProductPatch{Name: ptr("Stool"), Price: ptr(int64(25))}},
+				}, true)
+				if err != nil {
+					t.Fatalf("ApplyBatch: %v", err)
+				}
+				for i, r := range results {
+					if r.Err != nil {
+						t.Fatalf("item %d: %v", i, r.Err)
+					}
+				}
+				t.Cleanup(func() { _ = repo.Delete(context.Background(), results[1].Product.ID) })
+
+				got, err := svc.GetProduct(ctx, lamp.ID)
+				if err != nil {
+					t.Fatalf("GetProduct: %v", err)
+				}
+				if got.Name != "Floor Lamp" {
+					t.Fatalf("name = %q, want %q", got.Name, "Floor Lamp")
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/repository/memory"
+	"github.com/yusirdemir/microservice/internal/tenant"
+	"github.com/yusirdemir/microservice/pkg/concurrency"
+	"github.com/yusirdemir/microservice/pkg/mailer"
+)
+
+// TestTenantIsolation runs two tenants side by side on shared memory
+// repositories. Each holds a user with the same address; acme's user also
+// owns a product with an image. Nothing one tenant does may reach the other.
+func TestTenantIsolation(t *testing.T) {
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+
+	userRepo := memory.NewUserRepository()
+	productRepo := memory.NewProductRepository()
+	sessions := memory.NewSessionRepository()
+
+	images, _ := newImageService(t, productRepo)
+	catalog := NewProductService(productRepo, memory.NewCategoryRepository(), memory.NewSearchIndex(), images, time.Hour, "USD")
+	mailQueue := concurrency.NewWorkerPool(1, 4)
+	t.Cleanup(mailQueue.Close)
+	resets := NewPasswordResetGuard(memory.NewLoginAttemptRepository(), lenientPolicy, lenientPolicy)
+	// No grace period, so soft-deleted users are purged on the next sweep.
+	users := NewUserService(userRepo, productRepo, catalog, sessions, memory.NewOneTimeTokenRepository(), newTestHasher(t), mailer.NewOutbox("noreply@example.com"), resets, mailQueue, time.Hour, time.Hour, 0)
+	tokens, err := auth.NewTokenManager("test-signing-key", "test", time.Minute)
+	if err != nil {
+		t.Fatalf("NewTokenManager: %v", err)
+	}
+	authService := NewAuthService(users, sessions, memory.NewAPIKeyRepository(), NewLoginGuard(memory.NewLoginAttemptRepository(), lenientPolicy, lenientPolicy), tokens, time.Hour)
+	builders := concurrency.NewWorkerPool(1, 4)
+	t.Cleanup(builders.Close)
+	exports := NewExportService(userRepo, productRepo, memory.NewExportJobRepository(), builders, 100, time.Hour)
+
+	newUser := func(ctx context.Context) (*domain.User, *auth.Principal) {
+		t.Helper()
+		user, err := users.CreateUser(ctx, "Ada", testEmail, testPassword, domain.RoleSeller)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		return user, &auth.Principal{UserID: user.ID(), Roles: user.Roles(), TenantID: tenant.FromContext(ctx)}
+	}
+	acmeUser, acmeCaller := newUser(acme)
+	globexUser, globexCaller := newUser(globex)
+
+	product, err := catalog.CreateProduct(acme, acmeUser.ID(), "Desk Lamp", 100, "USD", 1, "", nil)
+	if err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	image, err := images.UploadImage(acme, acmeCaller, product.ID, testPNG(t))
+	if err != nil {
+		t.Fatalf("UploadImage: %v", err)
+	}
+
+	// globex holds a product under the same ID, owned by an ID equal to
+	// acme's user, so lookups by either key meet a record in both tenants.
+	twin, err := domain.NewProduct(product.ID, acmeUser.ID(), "Desk Lamp", domain.ReconstituteMoney(100, "USD"), 1)
+	if err != nil {
+		t.Fatalf("NewProduct: %v", err)
+	}
+	if err := productRepo.Create(globex, twin); err != nil {
+		t.Fatalf("Create twin: %v", err)
+	}
+
+	t.Run("users", func(t *testing.T) {
+		if _, err := users.GetUser(globex, acmeUser.ID()); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Fatalf("expected ErrUserNotFound across tenants, got %v", err)
+		}
+		page, err := users.ListUsers(globex, repository.UserQuery{})
+		if err != nil {
+			t.Fatalf("ListUsers: %v", err)
+		}
+		if len(page.Users) != 1 || page.Users[0].ID() != globexUser.ID() {
+			t.Fatalf("expected only globex's user, got %d users", len(page.Users))
+		}
+	})
+
+	t.Run("products", func(t *testing.T) {
+		if _, err := catalog.GetProduct(globex, product.ID); err != nil {
+			t.Fatalf("GetProduct: %v", err)
+		}
+		page, err := catalog.ListProducts(globex, repository.ProductQuery{OwnerID: acmeUser.ID()})
+		if err != nil {
+			t.Fatalf("ListProducts: %v", err)
+		}
+		if len(page.Products) != 1 {
+			t.Fatalf("expected globex's own product only, got %d", len(page.Products))
+		}
+
+		// The twin was written past the service, so only acme's search
+		// index knows the name.
+		matches, err := catalog.SearchProducts(globex, "lamp", 10)
+		if err != nil {
+			t.Fatalf("SearchProducts: %v", err)
+		}
+		if len(matches) != 0 {
+			t.Fatalf("expected no hits from acme's index, got %d", len(matches))
+		}
+
+		if _, err := catalog.GetProduct(tenant.WithTenant(context.Background(), "initech"), product.ID); !errors.Is(err, domain.ErrProductNotFound) {
+			t.Fatalf("expected ErrProductNotFound in a third tenant, got %v", err)
+		}
+	})
+
+	t.Run("images", func(t *testing.T) {
+		listed, err := images.ListImages(globex, product.ID)
+		if err != nil {
+			t.Fatalf("ListImages: %v", err)
+		}
+		if len(listed) != 0 {
+			t.Fatalf("expected no images on globex's twin, got %d", len(listed))
+		}
+		if _, err := images.GetImage(globex, product.ID, image.Image.ID); !errors.Is(err, domain.ErrImageNotFound) {
+			t.Fatalf("expected ErrImageNotFound across tenants, got %v", err)
+		}
+
+		if err := images.PurgeImages(globex, []string{product.ID}); err != nil {
+			t.Fatalf("PurgeImages: %v", err)
+		}
+		if _, err := images.GetImage(acme, product.ID, image.Image.ID); err != nil {
+			t.Fatalf("expected acme's image to survive globex's purge, got %v", err)
+		}
+	})
+
+	t.Run("exports", func(t *testing.T) {
+		if _, err := exports.ExportUser(globex, acmeCaller, acmeUser.ID(), false); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Fatalf("expected ErrUserNotFound across tenants, got %v", err)
+		}
+
+		result, err := exports.ExportUser(acme, acmeCaller, acmeUser.ID(), false)
+		if err != nil {
+			t.Fatalf("ExportUser: %v", err)
+		}
+		if n := archivedProducts(t, result.Archive); n != 1 {
+			t.Fatalf("expected acme's one product, got %d", n)
+		}
+	})
+
+	t.Run("sessions", func(t *testing.T) {
+		acmePair, err := authService.Login(acme, testEmail, testPassword, "192.0.2.1")
+		if err != nil {
+			t.Fatalf("Login acme: %v", err)
+		}
+		globexPair, err := authService.Login(globex, testEmail, testPassword, "192.0.2.1")
+		if err != nil {
+			t.Fatalf("Login globex: %v", err)
+		}
+
+		// Revoking by user ID in one tenant leaves the other's sessions,
+		// even for a caller naming acme's user.
+		if err := authService.LogoutAll(globex, acmeCaller); err != nil {
+			t.Fatalf("LogoutAll: %v", err)
+		}
+		if _, err := authService.Authenticate(acme, acmePair.AccessToken); err != nil {
+			t.Fatalf("expected acme's session to survive, got %v", err)
+		}
+		if err := authService.LogoutAll(globex, globexCaller); err != nil {
+			t.Fatalf("LogoutAll: %v", err)
+		}
+		if _, err := authService.Authenticate(globex, globexPair.AccessToken); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Fatalf("expected globex's session to be revoked, got %v", err)
+		}
+		if _, err := authService.Authenticate(acme, acmePair.AccessToken); err != nil {
+			t.Fatalf("expected acme's session to survive, got %v", err)
+		}
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		if err := users.PurgeUser(globex, acmeUser.ID()); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Fatalf("expected ErrUserNotFound across tenants, got %v", err)
+		}
+
+		tenants, err := userRepo.ListTenants(context.Background())
+		if err != nil {
+			t.Fatalf("ListTenants: %v", err)
+		}
+		slices.Sort(tenants)
+		if want := []string{"acme", "globex"}; !slices.Equal(tenants, want) {
+			t.Fatalf("expected tenants %v, got %v", want, tenants)
+		}
+
+		if err := users.DeleteUser(acme, acmeCaller, acmeUser.ID(), 0); err != nil {
+			t.Fatalf("DeleteUser: %v", err)
+		}
+		if purged, err := users.PurgeDeleted(globex); err != nil || purged != 0 {
+			t.Fatalf("expected globex's sweep to purge nothing, got %d, %v", purged, err)
+		}
+		if purged, err := users.PurgeDeleted(acme); err != nil || purged != 1 {
+			t.Fatalf("expected acme's sweep to purge its user, got %d, %v", purged, err)
+		}
+
+		if _, err := productRepo.FindByID(acme, product.ID); !errors.Is(err, domain.ErrProductNotFound) {
+			t.Fatalf("expected acme's product to go with its owner, got %v", err)
+		}
+		if _, err := productRepo.FindByID(globex, product.ID); err != nil {
+			t.Fatalf("expected globex's twin to survive, got %v", err)
+		}
+
+		tenants, err = userRepo.ListTenants(context.Background())
+		if err != nil {
+			t.Fatalf("ListTenants: %v", err)
+		}
+		if want := []string{"globex"}; !slices.Equal(tenants, want) {
+			t.Fatalf("expected tenants %v once acme is empty, got %v", want, tenants)
+		}
+	})
+}
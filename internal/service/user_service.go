@@ -2,9 +2,16 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/yusirdemir/microservice/internal/auth"
 	"github.com/yusirdemir/microservice/internal/domain"
 	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/pkg/concurrency"
+	"github.com/yusirdemir/microservice/pkg/mailer"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -13,27 +20,75 @@ import (
 var userTracer = otel.Tracer("microservice/service/user")
 
 type UserService interface {
-	CreateUser(ctx context.Context, name, email, password string) (*domain.User, error)
+	CreateUser(ctx context.Context, name, email, password string, role domain.Role) (*domain.User, error)
+	EnsureAdmin(ctx context.Context, name, email, password string) error
 	GetUser(ctx context.Context, id string) (*domain.User, error)
-	UpdateUser(ctx context.Context, id, name, email string) (*domain.User, error)
-	DeleteUser(ctx context.Context, id string) error
+	ListUsers(ctx context.Context, query repository.UserQuery) (*repository.UserPage, error)
+	Authenticate(ctx context.Context, email, password string) (*domain.User, error)
+	UpdateUser(ctx context.Context, caller *auth.Principal, id string, version uint64, patch domain.UserPatch) (*domain.User, error)
+	UpdateRoles(ctx context.Context, id string, roles []domain.Role) (*domain.User, error)
+	RequestEmailChange(ctx context.Context, caller *auth.Principal, id, newEmail string) error
+	ConfirmEmailChange(ctx context.Context, token string) (*domain.User, error)
+	ChangePassword(ctx context.Context, caller *auth.Principal, id, currentPassword, newPassword string) error
+	RequestPasswordReset(ctx context.Context, email, ip string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	DeleteUser(ctx context.Context, caller *auth.Principal, id string, version uint64) error
+	RestoreUser(ctx context.Context, id string) (*domain.User, error)
+	PurgeUser(ctx context.Context, id string) error
+	PurgeDeleted(ctx context.Context) (int, error)
 }
 
 type userService struct {
-	repo repository.UserRepository
+	repo             repository.UserRepository
+	products         repository.ProductRepository
+	catalog          ProductService
+	sessions         repository.SessionRepository
+	tokens           repository.OneTimeTokenRepository
+	hasher           domain.PasswordHasher
+	mailer           mailer.Mailer
+	resets           LoginGuard
+	mailQueue        *concurrency.WorkerPool
+	emailChangeTTL   time.Duration
+	passwordResetTTL time.Duration
+	gracePeriod      time.Duration
 }
 
-func NewUserService(repo repository.UserRepository) UserService {
+// NewUserService soft-deletes and restores a user's products through products
+// directly and hard-deletes them through catalog, which also removes what
+// hangs off them. Password reset requests are throttled by resets and mailed
+// from mailQueue.
+func NewUserService(repo repository.UserRepository, products repository.ProductRepository, catalog ProductService, sessions repository.SessionRepository, tokens repository.OneTimeTokenRepository, hasher domain.PasswordHasher, mailer mailer.Mailer, resets LoginGuard, mailQueue *concurrency.WorkerPool, emailChangeTTL, passwordResetTTL, gracePeriod time.Duration) UserService {
 	return &userService{
-		repo: repo,
+		repo:             repo,
+		products:         products,
+		catalog:          catalog,
+		sessions:         sessions,
+		tokens:           tokens,
+		hasher:           hasher,
+		mailer:           mailer,
+		resets:           resets,
+		mailQueue:        mailQueue,
+		emailChangeTTL:   emailChangeTTL,
+		passwordResetTTL: passwordResetTTL,
+		gracePeriod:      gracePeriod,
 	}
 }
 
-func (s *userService) CreateUser(ctx context.Context, name, email, password string) (*domain.User, error) {
+func (s *userService) CreateUser(ctx context.Context, name, email, password string, role domain.Role) (*domain.User, error) {
 	ctx, span := userTracer.Start(ctx, "UserService.CreateUser")
 	defer span.End()
 
-	user, err := domain.NewUser(name, email, password)
+	if role == "" {
+		role = domain.RoleCustomer
+	}
+	if role == domain.RoleAdmin {
+		err := errors.New("admin role cannot be self-assigned")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	user, err := domain.NewUser(name, email, password, s.hasher, role)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
@@ -51,6 +106,54 @@ func (s *userService) CreateUser(ctx context.Context, name, email, password stri
 	return user, nil
 }
 
+// EnsureAdmin creates the bootstrap administrator if no account uses the
+// given email yet. An existing account is never promoted, since anyone could
+// have registered that address first.
+func (s *userService) EnsureAdmin(ctx context.Context, name, email, password string) error {
+	ctx, span := userTracer.Start(ctx, "UserService.EnsureAdmin")
+	defer span.End()
+
+	normalized, err := domain.NormalizeEmail(email)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	existing, err := s.repo.FindByEmail(ctx, normalized)
+	if err == nil {
+		if !existing.HasRole(domain.RoleAdmin) {
+			err := errors.New("bootstrap admin email belongs to a non-admin account")
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+		return nil
+	}
+	if !errors.Is(err, domain.ErrUserNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	user, err := domain.NewUser(name, normalized, password, s.hasher, domain.RoleAdmin)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	span.SetAttributes(attribute.String("app.user.id", user.ID()))
+
+	if err := s.repo.Create(ctx, user); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return nil
+}
+
 func (s *userService) GetUser(ctx context.Context, id string) (*domain.User, error) {
 	ctx, span := userTracer.Start(ctx, "UserService.GetUser")
 	defer span.End()
@@ -67,12 +170,121 @@ func (s *userService) GetUser(ctx context.Context, id string) (*domain.User, err
 	return user, nil
 }
 
-func (s *userService) UpdateUser(ctx context.Context, id, name, email string) (*domain.User, error) {
+func (s *userService) ListUsers(ctx context.Context, query repository.UserQuery) (*repository.UserPage, error) {
+	ctx, span := userTracer.Start(ctx, "UserService.ListUsers")
+	defer span.End()
+
+	query.Normalize()
+	span.SetAttributes(
+		attribute.String("app.query.sort", string(query.Sort)),
+		attribute.Int("app.query.limit", query.Limit),
+	)
+
+	page, err := s.repo.List(ctx, query)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (s *userService) Authenticate(ctx context.Context, email, password string) (*domain.User, error) {
+	ctx, span := userTracer.Start(ctx, "UserService.Authenticate")
+	defer span.End()
+
+	email, err := domain.NormalizeEmail(email)
+	if err != nil {
+		err = domain.ErrInvalidCredentials
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	user, err := s.repo.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			err = domain.ErrInvalidCredentials
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("app.user.id", user.ID()))
+
+	if !user.CheckPassword(password, s.hasher) {
+		err := domain.ErrInvalidCredentials
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	// The plaintext is only available here, so this is where hashes written
+	// under an older policy get upgraded. A failure must not block the login.
+	rehashed, err := user.RehashPassword(password, s.hasher)
+	if err != nil {
+		span.RecordError(err)
+	} else if rehashed {
+		span.SetAttributes(attribute.Bool("app.user.password_rehashed", true))
+		if err := s.repo.Update(ctx, user); err != nil {
+			span.RecordError(err)
+		}
+	}
+
+	return user, nil
+}
+
+// UpdateUser and DeleteUser take the version the caller last saw, or zero to
+// skip that check, like their ProductService counterparts.
+func (s *userService) UpdateUser(ctx context.Context, caller *auth.Principal, id string, version uint64, patch domain.UserPatch) (*domain.User, error) {
 	ctx, span := userTracer.Start(ctx, "UserService.UpdateUser")
 	defer span.End()
 
 	span.SetAttributes(attribute.String("app.user.id", id))
 
+	if !caller.CanActOn(id) {
+		err := domain.ErrForbidden
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	user, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if err := matchVersion(user.Version(), version); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if err := user.ApplyPatch(patch); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, user); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *userService) UpdateRoles(ctx context.Context, id string, roles []domain.Role) (*domain.User, error) {
+	ctx, span := userTracer.Start(ctx, "UserService.UpdateRoles")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.user.id", id))
+
 	user, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		span.RecordError(err)
@@ -80,12 +292,472 @@ func (s *userService) UpdateUser(ctx context.Context, id, name, email string) (*
 		return nil, err
 	}
 
-	if name != "" {
-		if err := user.UpdateName(name); err != nil {
+	if err := user.UpdateRoles(roles); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, user); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	// Roles travel inside access tokens, so force a fresh login.
+	if err := s.sessions.RevokeAllByUserID(ctx, user.ID()); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *userService) RequestEmailChange(ctx context.Context, caller *auth.Principal, id, newEmail string) error {
+	ctx, span := userTracer.Start(ctx, "UserService.RequestEmailChange")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.user.id", id))
+
+	if caller.UserID != id {
+		err := domain.ErrForbidden
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	newEmail, err := domain.NormalizeEmail(newEmail)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	user, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if newEmail == user.Email() {
+		err := errors.New("new email must differ from the current email")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if _, err := s.repo.FindByEmail(ctx, newEmail); err == nil {
+		err = domain.ErrEmailTaken
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	} else if !errors.Is(err, domain.ErrUserNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	// Only the most recent request stays confirmable.
+	if err := s.tokens.DeleteAllByUserID(ctx, id, domain.TokenPurposeEmailChange); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	tokenID := uuid.New().String()
+	rawToken, tokenHash, err := auth.NewOpaqueToken(tokenID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	token, err := domain.NewOneTimeToken(tokenID, user.ID(), domain.TokenPurposeEmailChange, tokenHash, newEmail, time.Now().Add(s.emailChangeTTL))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := s.tokens.Create(ctx, token); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the token below to confirm %s as your new email address. It expires at %s.\n\n%s\n",
+			user.Name(), newEmail, token.ExpiresAt.UTC().Format(time.RFC1123), rawToken),
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (s *userService) ConfirmEmailChange(ctx context.Context, rawToken string) (*domain.User, error) {
+	ctx, span := userTracer.Start(ctx, "UserService.ConfirmEmailChange")
+	defer span.End()
+
+	token, err := s.redeemToken(ctx, rawToken, domain.TokenPurposeEmailChange)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("app.user.id", token.UserID))
+
+	user, err := s.repo.FindByID(ctx, token.UserID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	oldEmail := user.Email()
+	if err := user.UpdateEmail(token.Email); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, user); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      oldEmail,
+		Subject: "Your email address was changed",
+		Body:    fmt.Sprintf("Hi %s,\n\nThe email address on your account was changed to %s.\n", user.Name(), user.Email()),
+	})
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	return user, nil
+}
+
+// redeemToken verifies a one-time token and marks it used, so a second
+// redemption of the same token fails, also when both race.
+func (s *userService) redeemToken(ctx context.Context, rawToken string, purpose domain.TokenPurpose) (*domain.OneTimeToken, error) {
+	tokenID, secret, err := auth.ParseOpaqueToken(rawToken)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.tokens.FindByID(ctx, tokenID)
+	if err != nil {
+		if errors.Is(err, domain.ErrTokenNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if !auth.SecretMatches(secret, token.TokenHash) || !token.IsUsable(purpose, now) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	if err := s.tokens.MarkUsed(ctx, token.ID, now); err != nil {
+		if errors.Is(err, domain.ErrTokenUsed) || errors.Is(err, domain.ErrTokenNotFound) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, err
+	}
+	token.MarkUsed(now)
+
+	return token, nil
+}
+
+func (s *userService) ChangePassword(ctx context.Context, caller *auth.Principal, id, currentPassword, newPassword string) error {
+	ctx, span := userTracer.Start(ctx, "UserService.ChangePassword")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.user.id", id))
+
+	if caller.UserID != id {
+		err := domain.ErrForbidden
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	user, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := user.ChangePassword(currentPassword, newPassword, s.hasher); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := s.repo.Update(ctx, user); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := s.sessions.RevokeAllByUserID(ctx, id); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// RequestPasswordReset mails a reset token to the account behind email. The
+// lookup and the mail happen on the mail queue and their failures only reach
+// the trace, so neither the outcome nor the response time tells callers which
+// accounts exist. Requests are throttled per address and per client whether or
+// not the account exists, and refused while the queue is full.
+func (s *userService) RequestPasswordReset(ctx context.Context, email, ip string) error {
+	ctx, span := userTracer.Start(ctx, "UserService.RequestPasswordReset")
+	defer span.End()
+
+	if err := s.resets.Reserve(ctx, email, ip); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	err := s.mailQueue.Submit(ctx, func(ctx context.Context) {
+		s.sendPasswordReset(ctx, email)
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// sendPasswordReset replaces any outstanding reset token of the account behind
+// email with a fresh one and mails it.
+func (s *userService) sendPasswordReset(ctx context.Context, email string) {
+	ctx, span := userTracer.Start(ctx, "UserService.sendPasswordReset")
+	defer span.End()
+
+	email, err := domain.NormalizeEmail(email)
+	if err != nil {
+		span.RecordError(err)
+		return
+	}
+
+	user, err := s.repo.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
 			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
-			return nil, err
+			return
 		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+
+	span.SetAttributes(attribute.String("app.user.id", user.ID()))
+
+	if err := s.tokens.DeleteAllByUserID(ctx, user.ID(), domain.TokenPurposePasswordReset); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+
+	tokenID := uuid.New().String()
+	rawToken, tokenHash, err := auth.NewOpaqueToken(tokenID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+
+	token, err := domain.NewOneTimeToken(tokenID, user.ID(), domain.TokenPurposePasswordReset, tokenHash, user.Email(), time.Now().Add(s.passwordResetTTL))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+
+	if err := s.tokens.Create(ctx, token); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email(),
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the token below to choose a new password. It expires at %s. If you did not ask for this, you can ignore this message.\n\n%s\n",
+			user.Name(), token.ExpiresAt.UTC().Format(time.RFC1123), rawToken),
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+func (s *userService) ResetPassword(ctx context.Context, rawToken, newPassword string) error {
+	ctx, span := userTracer.Start(ctx, "UserService.ResetPassword")
+	defer span.End()
+
+	// Check the policy before redeeming, so a rejected password does not burn
+	// the token.
+	if err := domain.ValidatePassword(newPassword, s.hasher); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	token, err := s.redeemToken(ctx, rawToken, domain.TokenPurposePasswordReset)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	span.SetAttributes(attribute.String("app.user.id", token.UserID))
+
+	user, err := s.repo.FindByID(ctx, token.UserID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	// The token was mailed to a specific address; it is void once the
+	// account has moved to another one.
+	if user.Email() != token.Email {
+		err := auth.ErrInvalidToken
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := user.UpdatePassword(newPassword, s.hasher); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := s.repo.Update(ctx, user); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := s.sessions.RevokeAllByUserID(ctx, user.ID()); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email(),
+		Subject: "Your password was changed",
+		Body:    fmt.Sprintf("Hi %s,\n\nThe password on your account was reset and all devices were signed out.\n", user.Name()),
+	})
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	return nil
+}
+
+func (s *userService) DeleteUser(ctx context.Context, caller *auth.Principal, id string, version uint64) error {
+	ctx, span := userTracer.Start(ctx, "UserService.DeleteUser")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.user.id", id))
+
+	if !caller.CanActOn(id) {
+		err := domain.ErrForbidden
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	user, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := matchVersion(user.Version(), version); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	// Products share the user's deletion stamp so a restore brings back
+	// exactly these and not ones the seller had deleted before.
+	now := time.Now().UTC()
+	if err := s.products.SoftDeleteAllByUserID(ctx, user.ID(), now); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	user.SoftDelete(now)
+	if err := s.repo.Update(ctx, user); err != nil {
+		if restoreErr := s.products.RestoreAllByUserID(ctx, user.ID(), now); restoreErr != nil {
+			span.RecordError(restoreErr)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := s.sessions.RevokeAllByUserID(ctx, user.ID()); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (s *userService) RestoreUser(ctx context.Context, id string) (*domain.User, error) {
+	ctx, span := userTracer.Start(ctx, "UserService.RestoreUser")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("app.user.id", id))
+
+	user, err := s.repo.FindDeletedByID(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	deletedAt := *user.DeletedAt()
+	if err := user.Restore(time.Now(), s.gracePeriod); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	// Products go first: if saving the user fails, a retry finds the user
+	// still deleted and the product restore is a no-op.
+	if err := s.products.RestoreAllByUserID(ctx, user.ID(), deletedAt); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
 	}
 
 	if err := s.repo.Update(ctx, user); err != nil {
@@ -97,13 +769,31 @@ func (s *userService) UpdateUser(ctx context.Context, id, name, email string) (*
 	return user, nil
 }
 
-func (s *userService) DeleteUser(ctx context.Context, id string) error {
-	ctx, span := userTracer.Start(ctx, "UserService.DeleteUser")
+// PurgeUser hard-deletes a user, soft-deleted or not, with all of their
+// products. Products go first so an interrupted purge can be retried.
+func (s *userService) PurgeUser(ctx context.Context, id string) error {
+	ctx, span := userTracer.Start(ctx, "UserService.PurgeUser")
 	defer span.End()
 
 	span.SetAttributes(attribute.String("app.user.id", id))
 
-	if err := s.repo.Delete(ctx, id); err != nil {
+	user, err := s.repo.FindByID(ctx, id)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		user, err = s.repo.FindDeletedByID(ctx, id)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := s.purge(ctx, user); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := s.sessions.RevokeAllByUserID(ctx, user.ID()); err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return err
@@ -111,3 +801,38 @@ func (s *userService) DeleteUser(ctx context.Context, id string) error {
 
 	return nil
 }
+
+// PurgeDeleted hard-deletes every user whose restore grace period has run
+// out and reports how many were removed.
+func (s *userService) PurgeDeleted(ctx context.Context) (int, error) {
+	ctx, span := userTracer.Start(ctx, "UserService.PurgeDeleted")
+	defer span.End()
+
+	users, err := s.repo.FindAllDeletedBefore(ctx, time.Now().Add(-s.gracePeriod))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, err
+	}
+
+	purged := 0
+	for _, user := range users {
+		if err := s.purge(ctx, user); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return purged, err
+		}
+		purged++
+	}
+
+	span.SetAttributes(attribute.Int("app.user.purged", purged))
+
+	return purged, nil
+}
+
+func (s *userService) purge(ctx context.Context, user *domain.User) error {
+	if err := s.catalog.PurgeAllByUserID(ctx, user.ID()); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, user.ID())
+}
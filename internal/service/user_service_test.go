@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/internal/repository/memory"
+	"github.com/yusirdemir/microservice/pkg/concurrency"
+	"github.com/yusirdemir/microservice/pkg/mailer"
+	"github.com/yusirdemir/microservice/pkg/password"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestHasher(t *testing.T) domain.PasswordHasher {
+	t.Helper()
+	algorithm, err := password.NewBcrypt(bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("NewBcrypt: %v", err)
+	}
+	return password.NewHasher(algorithm)
+}
+
+// newTestUserService wires a UserService to memory repositories, apart from
+// the users and tokens a test inspects directly. Password resets are throttled
+// by resetPolicy.
+func newTestUserService(t *testing.T, users repository.UserRepository, tokens repository.OneTimeTokenRepository, hasher domain.PasswordHasher, mail mailer.Mailer, resetPolicy domain.LockoutPolicy) UserService {
+	t.Helper()
+
+	products := memory.NewProductRepository()
+	catalog := newProductService(t, products, memory.NewCategoryRepository(), memory.NewSearchIndex())
+	resets := NewPasswordResetGuard(memory.NewLoginAttemptRepository(), resetPolicy, resetPolicy)
+	mailQueue := concurrency.NewWorkerPool(2, 16)
+	t.Cleanup(mailQueue.Close)
+
+	return NewUserService(users, products, catalog, memory.NewSessionRepository(), tokens, hasher, mail, resets, mailQueue, time.Hour, time.Hour, time.Hour)
+}
+
+func TestUserService_ResetPasswordRedeemsTokenOnce(t *testing.T) {
+	ctx := context.Background()
+	hasher := newTestHasher(t)
+	users := memory.NewUserRepository()
+	tokens := memory.NewOneTimeTokenRepository()
+	svc := newTestUserService(t, users, tokens, hasher, mailer.NewOutbox("noreply@example.com"), lenientPolicy)
+
+	user, err := domain.NewUser("Ada", "ada@example.com", "original-password", hasher)
+	if err != nil {
+		t.Fatalf("NewUser: %v", err)
+	}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("Create user: %v", err)
+	}
+
+	tokenID := uuid.New().String()
+	rawToken, tokenHash, err := auth.NewOpaqueToken(tokenID)
+	if err != nil {
+		t.Fatalf("NewOpaqueToken: %v", err)
+	}
+	token, err := domain.NewOneTimeToken(tokenID, user.ID(), domain.TokenPurposePasswordReset, tokenHash, user.Email(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("NewOneTimeToken: %v", err)
+	}
+	if err := tokens.Create(ctx, token); err != nil {
+		t.Fatalf("Create token: %v", err)
+	}
+
+	const attempts = 16
+	errs := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := range attempts {
+		wg.Go(func() {
+			errs[i] = svc.ResetPassword(ctx, rawToken, "new-password-1")
+		})
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, auth.ErrInvalidToken):
+			t.Errorf("expected ErrInvalidToken, got %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("expected exactly one redemption to succeed, got %d", succeeded)
+	}
+
+	if err := svc.ResetPassword(ctx, rawToken, "new-password-2"); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("expected a used token to be rejected, got %v", err)
+	}
+}
+
+func TestUserService_AuthenticateMigratesHashes(t *testing.T) {
+	ctx := context.Background()
+	users := memory.NewUserRepository()
+
+	bcryptHasher, err := password.NewBcrypt(bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("NewBcrypt: %v", err)
+	}
+	argon2Hasher, err := password.NewArgon2id(password.Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1})
+	if err != nil {
+		t.Fatalf("NewArgon2id: %v", err)
+	}
+
+	// The account predates the switch to argon2id.
+	user, err := domain.NewUser("Ada", testEmail, testPassword, password.NewHasher(bcryptHasher))
+	if err != nil {
+		t.Fatalf("NewUser: %v", err)
+	}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("Create user: %v", err)
+	}
+
+	svc := newTestUserService(t, users, memory.NewOneTimeTokenRepository(), password.NewHasher(argon2Hasher, bcryptHasher), mailer.NewOutbox("noreply@example.com"), lenientPolicy)
+
+	if _, err := svc.Authenticate(ctx, testEmail, "wrong-password"); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	stored, err := users.FindByID(ctx, user.ID())
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if !bcryptHasher.Identifies(stored.Password()) {
+		t.Fatal("expected a failed login to leave the hash alone")
+	}
+
+	if _, err := svc.Authenticate(ctx, testEmail, testPassword); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	stored, err = users.FindByID(ctx, user.ID())
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if !argon2Hasher.Identifies(stored.Password()) {
+		t.Fatalf("expected the login to store an argon2id hash, got %q", stored.Password())
+	}
+
+	// The new hash is used from then on.
+	if _, err := svc.Authenticate(ctx, testEmail, testPassword); err != nil {
+		t.Fatalf("Authenticate after migrating: %v", err)
+	}
+}
+
+func TestUserService_ListUsersByEmail(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestUserService(t, memory.NewUserRepository(), memory.NewOneTimeTokenRepository(), newTestHasher(t), mailer.NewOutbox("noreply@example.com"), lenientPolicy)
+
+	for _, email := range []string{"ada@example.com", "grace@example.com", "adalyn@example.org"} {
+		if _, err := svc.CreateUser(ctx, "User", email, "original-password", domain.RoleCustomer); err != nil {
+			t.Fatalf("CreateUser %s: %v", email, err)
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		want   int
+	}{
+		{"ada", 2},
+		{"  ADA@Example.com ", 1},
+		// Only the start of the address matches.
+		{"example", 0},
+		{"", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			page, err := svc.ListUsers(ctx, repository.UserQuery{EmailPrefix: tt.prefix})
+			if err != nil {
+				t.Fatalf("ListUsers: %v", err)
+			}
+			if len(page.Users) != tt.want {
+				t.Fatalf("expected %d users, got %d", tt.want, len(page.Users))
+			}
+		})
+	}
+}
+
+func TestUserService_RequestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	hasher := newTestHasher(t)
+	// One free request, then a backoff no test outlives.
+	strictPolicy := domain.LockoutPolicy{
+		FreeAttempts: 1,
+		BaseDelay:    time.Hour,
+		MaxDelay:     time.Hour,
+		ResetAfter:   time.Hour,
+	}
+
+	t.Run("mails the account", func(t *testing.T) {
+		users := memory.NewUserRepository()
+		outbox := mailer.NewOutbox("noreply@example.com")
+		svc := newTestUserService(t, users, memory.NewOneTimeTokenRepository(), hasher, outbox, strictPolicy)
+		if _, err := svc.CreateUser(ctx, "Ada", testEmail, testPassword, domain.RoleCustomer); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		if err := svc.RequestPasswordReset(ctx, testEmail, "192.0.2.1"); err != nil {
+			t.Fatalf("RequestPasswordReset: %v", err)
+		}
+
+		// The mail goes out from the queue.
+		deadline := time.Now().Add(5 * time.Second)
+		for len(outbox.Messages()) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("expected a reset mail")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if to := outbox.Messages()[0].To; to != testEmail {
+			t.Fatalf("expected the mail to go to %s, got %s", testEmail, to)
+		}
+	})
+
+	t.Run("throttles every address alike", func(t *testing.T) {
+		svc := newTestUserService(t, memory.NewUserRepository(), memory.NewOneTimeTokenRepository(), hasher, mailer.NewOutbox("noreply@example.com"), strictPolicy)
+
+		// Nobody owns these addresses; they are throttled all the same.
+		for i, ip := range []string{"192.0.2.1", "192.0.2.2"} {
+			if err := svc.RequestPasswordReset(ctx, "nobody@example.com", ip); err != nil {
+				t.Fatalf("request %d: %v", i+1, err)
+			}
+		}
+		if err := svc.RequestPasswordReset(ctx, "nobody@example.com", "192.0.2.3"); !errors.Is(err, domain.ErrLoginThrottled) {
+			t.Fatalf("expected the address to be throttled, got %v", err)
+		}
+
+		for i, email := range []string{"a@example.com", "b@example.com"} {
+			if err := svc.RequestPasswordReset(ctx, email, "198.51.100.1"); err != nil {
+				t.Fatalf("request %d: %v", i+1, err)
+			}
+		}
+		if err := svc.RequestPasswordReset(ctx, "c@example.com", "198.51.100.1"); !errors.Is(err, domain.ErrLoginThrottled) {
+			t.Fatalf("expected the client to be throttled, got %v", err)
+		}
+	})
+}
+
+func TestUserService_EmailUniqueness(t *testing.T) {
+	ctx := context.Background()
+	hasher := newTestHasher(t)
+
+	// confirmationToken pulls the token out of the last confirmation mail.
+	confirmationToken := func(t *testing.T, outbox *mailer.Outbox) string {
+		t.Helper()
+		messages := outbox.Messages()
+		if len(messages) == 0 {
+			t.Fatal("expected a confirmation mail")
+		}
+		fields := strings.Fields(messages[len(messages)-1].Body)
+		return fields[len(fields)-1]
+	}
+
+	t.Run("on create", func(t *testing.T) {
+		svc := newTestUserService(t, memory.NewUserRepository(), memory.NewOneTimeTokenRepository(), hasher, mailer.NewOutbox("noreply@example.com"), lenientPolicy)
+		if _, err := svc.CreateUser(ctx, "Ada", testEmail, testPassword, domain.RoleCustomer); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		// Addresses are compared once normalized.
+		for _, email := range []string{testEmail, "  ADA@Example.com "} {
+			if _, err := svc.CreateUser(ctx, "Impostor", email, testPassword, domain.RoleCustomer); !errors.Is(err, domain.ErrEmailTaken) {
+				t.Fatalf("expected ErrEmailTaken for %q, got %v", email, err)
+			}
+		}
+	})
+
+	t.Run("on update to a taken address", func(t *testing.T) {
+		outbox := mailer.NewOutbox("noreply@example.com")
+		svc := newTestUserService(t, memory.NewUserRepository(), memory.NewOneTimeTokenRepository(), hasher, outbox, lenientPolicy)
+		ada, err := svc.CreateUser(ctx, "Ada", testEmail, testPassword, domain.RoleCustomer)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if _, err := svc.CreateUser(ctx, "Grace", "grace@example.com", testPassword, domain.RoleCustomer); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		caller := &auth.Principal{UserID: ada.ID()}
+
+		if err := svc.RequestEmailChange(ctx, caller, ada.ID(), "Grace@example.com"); !errors.Is(err, domain.ErrEmailTaken) {
+			t.Fatalf("expected ErrEmailTaken, got %v", err)
+		}
+
+		// The address is free when requested but taken before confirmation.
+		if err := svc.RequestEmailChange(ctx, caller, ada.ID(), "lovelace@example.com"); err != nil {
+			t.Fatalf("RequestEmailChange: %v", err)
+		}
+		if _, err := svc.CreateUser(ctx, "Squatter", "lovelace@example.com", testPassword, domain.RoleCustomer); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if _, err := svc.ConfirmEmailChange(ctx, confirmationToken(t, outbox)); !errors.Is(err, domain.ErrEmailTaken) {
+			t.Fatalf("expected ErrEmailTaken, got %v", err)
+		}
+
+		user, err := svc.GetUser(ctx, ada.ID())
+		if err != nil {
+			t.Fatalf("GetUser: %v", err)
+		}
+		if user.Email() != testEmail {
+			t.Fatalf("expected the email to stay %s, got %s", testEmail, user.Email())
+		}
+	})
+
+	t.Run("releases the old address", func(t *testing.T) {
+		outbox := mailer.NewOutbox("noreply@example.com")
+		svc := newTestUserService(t, memory.NewUserRepository(), memory.NewOneTimeTokenRepository(), hasher, outbox, lenientPolicy)
+		ada, err := svc.CreateUser(ctx, "Ada", testEmail, testPassword, domain.RoleCustomer)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		if err := svc.RequestEmailChange(ctx, &auth.Principal{UserID: ada.ID()}, ada.ID(), "lovelace@example.com"); err != nil {
+			t.Fatalf("RequestEmailChange: %v", err)
+		}
+		if _, err := svc.ConfirmEmailChange(ctx, confirmationToken(t, outbox)); err != nil {
+			t.Fatalf("ConfirmEmailChange: %v", err)
+		}
+
+		if _, err := svc.CreateUser(ctx, "Newcomer", testEmail, testPassword, domain.RoleCustomer); err != nil {
+			t.Fatalf("expected the old address to be free again, got %v", err)
+		}
+		if _, err := svc.CreateUser(ctx, "Impostor", "lovelace@example.com", testPassword, domain.RoleCustomer); !errors.Is(err, domain.ErrEmailTaken) {
+			t.Fatalf("expected the new address to be taken, got %v", err)
+		}
+		if _, err := svc.Authenticate(ctx, "lovelace@example.com", testPassword); err != nil {
+			t.Fatalf("expected to sign in with the new address, got %v", err)
+		}
+	})
+}
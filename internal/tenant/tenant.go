@@ -0,0 +1,83 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Default owns requests that name no tenant, and every record written
+// before tenancy existed.
+const Default = "default"
+
+var (
+	ErrInvalid = errors.New("invalid tenant identifier")
+	ErrUnknown = errors.New("unknown tenant")
+)
+
+// Validate accepts DNS-label style identifiers: 1 to 63 lowercase letters,
+// digits or hyphens, neither starting nor ending with a hyphen. The
+// restriction keeps identifiers safe inside document keys and host names.
+func Validate(id string) error {
+	if len(id) == 0 || len(id) > 63 || id[0] == '-' || id[len(id)-1] == '-' {
+		return ErrInvalid
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return ErrInvalid
+		}
+	}
+	return nil
+}
+
+// Registry is the set of tenants the service serves. Default always
+// belongs to it.
+type Registry struct {
+	ids []string
+}
+
+func NewRegistry(ids []string) (*Registry, error) {
+	registry := &Registry{ids: []string{Default}}
+	for _, id := range ids {
+		if err := Validate(id); err != nil {
+			return nil, fmt.Errorf("%w: %q", err, id)
+		}
+		if !slices.Contains(registry.ids, id) {
+			registry.ids = append(registry.ids, id)
+		}
+	}
+	slices.Sort(registry.ids)
+	return registry, nil
+}
+
+func (r *Registry) Contains(id string) bool {
+	_, found := slices.BinarySearch(r.ids, id)
+	return found
+}
+
+// IDs lists the registered tenants in order.
+func (r *Registry) IDs() []string {
+	return slices.Clone(r.ids)
+}
+
+type tenantKey struct{}
+
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// Lookup reports the tenant explicitly set on ctx.
+func Lookup(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok && id != ""
+}
+
+// FromContext returns the tenant set on ctx, or Default when there is none.
+func FromContext(ctx context.Context) string {
+	if id, ok := Lookup(ctx); ok {
+		return id
+	}
+	return Default
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/dto"
+	"github.com/yusirdemir/microservice/internal/service"
+	"github.com/yusirdemir/microservice/internal/transport/http/router"
+)
+
+type APIKeyHandler struct {
+	service service.APIKeyService
+}
+
+func NewAPIKeyHandler(service service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		service: service,
+	}
+}
+
+func (h *APIKeyHandler) Routes() []router.Route {
+	return []router.Route{
+		{Method: fiber.MethodPost, Path: "/api-keys", Handler: h.CreateAPIKey, Auth: true},
+		{Method: fiber.MethodGet, Path: "/api-keys", Handler: h.ListAPIKeys, Auth: true},
+		{Method: fiber.MethodDelete, Path: "/api-keys/:id", Handler: h.RevokeAPIKey, Auth: true},
+	}
+}
+
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	var req dto.CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	scopes := make([]domain.Scope, 0, len(req.Scopes))
+	for _, sc := range req.Scopes {
+		scope, err := domain.ParseScope(sc)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		scopes = append(scopes, scope)
+	}
+
+	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	key, rawKey, err := h.service.CreateAPIKey(ctx, principal, req.Name, scopes)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusBadRequest)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(dto.CreateAPIKeyResponse{
+		APIKeyResponse: toAPIKeyResponse(key),
+		Key:            rawKey,
+	})
+}
+
+func (h *APIKeyHandler) ListAPIKeys(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	keys, err := h.service.ListAPIKeys(ctx, principal)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	response := make([]dto.APIKeyResponse, len(keys))
+	for i, k := range keys {
+		response[i] = toAPIKeyResponse(k)
+	}
+
+	return c.JSON(response)
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	if err := h.service.RevokeAPIKey(ctx, principal, id); err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func toAPIKeyResponse(k *domain.APIKey) dto.APIKeyResponse {
+	scopes := make([]string, 0, len(k.Scopes))
+	for _, sc := range k.Scopes {
+		scopes = append(scopes, string(sc))
+	}
+
+	return dto.APIKeyResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		Scopes:     scopes,
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
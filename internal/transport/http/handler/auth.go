@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/dto"
+	"github.com/yusirdemir/microservice/internal/service"
+	"github.com/yusirdemir/microservice/internal/transport/http/router"
+)
+
+type AuthHandler struct {
+	service service.AuthService
+	guard   service.LoginGuard
+}
+
+func NewAuthHandler(service service.AuthService, guard service.LoginGuard) *AuthHandler {
+	return &AuthHandler{
+		service: service,
+		guard:   guard,
+	}
+}
+
+func (h *AuthHandler) Routes() []router.Route {
+	return []router.Route{
+		{Method: fiber.MethodPost, Path: "/auth/login", Handler: h.Login},
+		{Method: fiber.MethodPost, Path: "/auth/refresh", Handler: h.Refresh},
+		{Method: fiber.MethodPost, Path: "/auth/logout", Handler: h.Logout, Auth: true},
+		{Method: fiber.MethodPost, Path: "/auth/logout-all", Handler: h.LogoutAll, Auth: true},
+		{Method: fiber.MethodPost, Path: "/auth/unlock", Handler: h.Unlock, Roles: []domain.Role{domain.RoleAdmin}},
+	}
+}
+
+func (h *AuthHandler) Login(c *fiber.Ctx) error {
+	var req dto.LoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx := c.UserContext()
+	tokens, err := h.service.Login(ctx, req.Email, req.Password, c.IP())
+	if err != nil {
+		setRetryAfter(c, err)
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(toTokenResponse(tokens))
+}
+
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req dto.RefreshRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx := c.UserContext()
+	tokens, err := h.service.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(toTokenResponse(tokens))
+}
+
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	if err := h.service.Logout(ctx, principal); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *AuthHandler) LogoutAll(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	if err := h.service.LogoutAll(ctx, principal); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *AuthHandler) Unlock(c *fiber.Ctx) error {
+	var req dto.UnlockRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx := c.UserContext()
+	if err := h.guard.Unlock(ctx, req.Email, req.IP); err != nil {
+		return errorResponse(c, err, fiber.StatusBadRequest)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func toTokenResponse(t *service.TokenPair) dto.TokenResponse {
+	return dto.TokenResponse{
+		AccessToken:      t.AccessToken,
+		TokenType:        "Bearer",
+		ExpiresAt:        t.AccessTokenExpiresAt,
+		RefreshToken:     t.RefreshToken,
+		RefreshExpiresAt: t.RefreshTokenExpiresAt,
+	}
+}
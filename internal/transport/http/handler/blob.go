@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/transport/http/router"
+	"github.com/yusirdemir/microservice/pkg/blobstore"
+)
+
+// BlobHandler serves signed URLs for stores that cannot serve their own,
+// such as the local filesystem. The signature is the only credential, so the
+// route is public.
+type BlobHandler struct {
+	store    blobstore.BlobStore
+	verifier blobstore.URLVerifier
+}
+
+func NewBlobHandler(store blobstore.BlobStore) *BlobHandler {
+	verifier, _ := store.(blobstore.URLVerifier)
+	return &BlobHandler{
+		store:    store,
+		verifier: verifier,
+	}
+}
+
+// Routes is empty when the store's signed URLs point elsewhere.
+func (h *BlobHandler) Routes() []router.Route {
+	if h.verifier == nil {
+		return nil
+	}
+	return []router.Route{
+		{Method: fiber.MethodGet, Path: "/blobs/*", Handler: h.ServeBlob},
+	}
+}
+
+func (h *BlobHandler) ServeBlob(c *fiber.Ctx) error {
+	key, err := url.PathUnescape(c.Params("*"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": blobstore.ErrNotFound.Error()})
+	}
+
+	if err := h.verifier.Verify(key, c.Query("expires"), c.Query("signature"), time.Now()); err != nil {
+		return errorResponse(c, err, fiber.StatusForbidden)
+	}
+
+	blob, err := h.store.Get(c.UserContext(), key)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	c.Set(fiber.HeaderContentType, blob.ContentType)
+	c.Set(fiber.HeaderCacheControl, "private")
+	return c.SendStream(blob.Body, int(blob.Size))
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/dto"
+	"github.com/yusirdemir/microservice/internal/service"
+	"github.com/yusirdemir/microservice/internal/transport/http/router"
+)
+
+type CategoryHandler struct {
+	service service.CategoryService
+}
+
+func NewCategoryHandler(service service.CategoryService) *CategoryHandler {
+	return &CategoryHandler{
+		service: service,
+	}
+}
+
+// Routes lets anyone who may browse products read the tree; only admins
+// shape it.
+func (h *CategoryHandler) Routes() []router.Route {
+	admins := []domain.Role{domain.RoleAdmin}
+	read := []domain.Scope{domain.ScopeProductsRead}
+	write := []domain.Scope{domain.ScopeProductsWrite}
+	return []router.Route{
+		{Method: fiber.MethodPost, Path: "/categories", Handler: h.CreateCategory, Roles: admins, Scopes: write},
+		{Method: fiber.MethodGet, Path: "/categories", Handler: h.ListCategories, Scopes: read},
+		{Method: fiber.MethodGet, Path: "/categories/:id", Handler: h.GetCategory, Scopes: read},
+		{Method: fiber.MethodPut, Path: "/categories/:id", Handler: h.UpdateCategory, Roles: admins, Scopes: write},
+		{Method: fiber.MethodDelete, Path: "/categories/:id", Handler: h.DeleteCategory, Roles: admins, Scopes: write},
+	}
+}
+
+func (h *CategoryHandler) CreateCategory(c *fiber.Ctx) error {
+	var req dto.CategoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx := c.UserContext()
+	category, err := h.service.CreateCategory(ctx, req.ParentID, req.Name)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusBadRequest)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(toCategoryResponse(category))
+}
+
+func (h *CategoryHandler) ListCategories(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	categories, err := h.service.ListCategories(ctx)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	resp := dto.CategoryListResponse{Items: make([]dto.CategoryResponse, 0, len(categories))}
+	for _, category := range categories {
+		resp.Items = append(resp.Items, toCategoryResponse(category))
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *CategoryHandler) GetCategory(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx := c.UserContext()
+
+	category, err := h.service.GetCategory(ctx, id)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(toCategoryResponse(category))
+}
+
+// UpdateCategory replaces the name and parent; moving a category carries its
+// subcategories and products along.
+func (h *CategoryHandler) UpdateCategory(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var req dto.CategoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx := c.UserContext()
+	category, err := h.service.UpdateCategory(ctx, id, req.ParentID, req.Name)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusBadRequest)
+	}
+
+	return c.JSON(toCategoryResponse(category))
+}
+
+func (h *CategoryHandler) DeleteCategory(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx := c.UserContext()
+
+	if err := h.service.DeleteCategory(ctx, id); err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func toCategoryResponse(category *domain.Category) dto.CategoryResponse {
+	return dto.CategoryResponse{
+		ID:        category.ID,
+		ParentID:  category.ParentID,
+		Name:      category.Name,
+		CreatedAt: category.CreatedAt,
+		UpdatedAt: category.UpdatedAt,
+	}
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/imaging"
+	"github.com/yusirdemir/microservice/internal/repository"
+	"github.com/yusirdemir/microservice/pkg/blobstore"
+	"github.com/yusirdemir/microservice/pkg/concurrency"
+)
+
+var errorStatuses = []struct {
+	err    error
+	status int
+}{
+	{domain.ErrUserNotFound, fiber.StatusNotFound},
+	{domain.ErrProductNotFound, fiber.StatusNotFound},
+	{domain.ErrCategoryNotFound, fiber.StatusNotFound},
+	{domain.ErrImageNotFound, fiber.StatusNotFound},
+	{blobstore.ErrNotFound, fiber.StatusNotFound},
+	{domain.ErrAPIKeyNotFound, fiber.StatusNotFound},
+	{domain.ErrExportNotFound, fiber.StatusNotFound},
+	{domain.ErrExportNotReady, fiber.StatusConflict},
+	{domain.ErrExportPending, fiber.StatusConflict},
+	{domain.ErrForbidden, fiber.StatusForbidden},
+	{domain.ErrIncorrectPassword, fiber.StatusForbidden},
+	{domain.ErrEmailTaken, fiber.StatusConflict},
+	{domain.ErrInvalidCredentials, fiber.StatusUnauthorized},
+	{auth.ErrInvalidToken, fiber.StatusUnauthorized},
+	{auth.ErrTokenReused, fiber.StatusUnauthorized},
+	{domain.ErrLoginThrottled, fiber.StatusTooManyRequests},
+	{domain.ErrRestoreExpired, fiber.StatusGone},
+	{domain.ErrInsufficientStock, fiber.StatusConflict},
+	{domain.ErrCategoryInUse, fiber.StatusConflict},
+	{domain.ErrCategoryCycle, fiber.StatusConflict},
+	{domain.ErrImageLimitReached, fiber.StatusConflict},
+	{domain.ErrImageTooLarge, fiber.StatusRequestEntityTooLarge},
+	{imaging.ErrUnsupportedFormat, fiber.StatusUnsupportedMediaType},
+	{imaging.ErrTooManyPixels, fiber.StatusRequestEntityTooLarge},
+	{blobstore.ErrURLSignature, fiber.StatusForbidden},
+	{blobstore.ErrURLExpired, fiber.StatusForbidden},
+	{domain.ErrVersionMismatch, fiber.StatusPreconditionFailed},
+	{domain.ErrBatchAborted, fiber.StatusFailedDependency},
+	{errMergePatchMediaType, fiber.StatusUnsupportedMediaType},
+	{repository.ErrInvalidCursor, fiber.StatusBadRequest},
+	{repository.ErrPriceWithoutCurrency, fiber.StatusBadRequest},
+	{concurrency.ErrQueueFull, fiber.StatusServiceUnavailable},
+}
+
+// errorStatus maps well-known domain errors to their HTTP status and falls
+// back to the given status for everything else.
+func errorStatus(err error, fallback int) int {
+	for _, e := range errorStatuses {
+		if errors.Is(err, e.err) {
+			return e.status
+		}
+	}
+	return fallback
+}
+
+// setRetryAfter tells throttled callers when to come back.
+func setRetryAfter(c *fiber.Ctx, err error) {
+	var throttled *domain.LoginThrottledError
+	if errors.As(err, &throttled) {
+		seconds := math.Ceil(time.Until(throttled.RetryAt).Seconds())
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(int(seconds), 1)))
+	}
+}
+
+func errorResponse(c *fiber.Ctx, err error, fallback int) error {
+	return c.Status(errorStatus(err, fallback)).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+// setETag exposes a resource version as a strong entity tag. Version zero
+// means the store did not report one, so no tag is sent.
+func setETag(c *fiber.Ctx, version uint64) {
+	if tag := formatETag(version); tag != "" {
+		c.Set(fiber.HeaderETag, tag)
+	}
+}
+
+func formatETag(version uint64) string {
+	if version == 0 {
+		return ""
+	}
+	return strconv.Quote(strconv.FormatUint(version, 10))
+}
+
+// ifMatch reads the If-Match precondition as a version, where zero means
+// there is none: the header is absent or "*". Weak or foreign tags can never
+// match under the strong comparison If-Match requires, so they fail with
+// domain.ErrVersionMismatch, as does a list of tags.
+func ifMatch(c *fiber.Ctx) (uint64, error) {
+	return parseIfMatch(c.Get(fiber.HeaderIfMatch))
+}
+
+// parseIfMatch reads an If-Match value the way ifMatch describes, wherever
+// it comes from.
+func parseIfMatch(value string) (uint64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+
+	tag, err := strconv.Unquote(value)
+	if err != nil {
+		return 0, domain.ErrVersionMismatch
+	}
+	version, err := strconv.ParseUint(tag, 10, 64)
+	if err != nil || version == 0 {
+		return 0, domain.ErrVersionMismatch
+	}
+	return version, nil
+}
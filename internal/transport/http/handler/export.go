@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/dto"
+	"github.com/yusirdemir/microservice/internal/service"
+	"github.com/yusirdemir/microservice/internal/transport/http/router"
+)
+
+type ExportHandler struct {
+	service service.ExportService
+}
+
+func NewExportHandler(service service.ExportService) *ExportHandler {
+	return &ExportHandler{
+		service: service,
+	}
+}
+
+func (h *ExportHandler) Routes() []router.Route {
+	return []router.Route{
+		{Method: fiber.MethodGet, Path: "/users/:id/export", Handler: h.ExportUser, Auth: true},
+		{Method: fiber.MethodGet, Path: "/users/:id/exports/:exportId", Handler: h.GetExportJob, Auth: true},
+		{Method: fiber.MethodGet, Path: "/users/:id/exports/:exportId/download", Handler: h.DownloadExport, Auth: true},
+	}
+}
+
+// ExportUser answers with the ZIP archive directly, or with 202 and a job to
+// poll when the export is large or ?async=true is given. While a job is still
+// pending, another one is refused with 409.
+func (h *ExportHandler) ExportUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	result, err := h.service.ExportUser(ctx, principal, id, c.QueryBool("async"))
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	if result.Job != nil {
+		response := toExportJobResponse(result.Job)
+		c.Location(fmt.Sprintf("/users/%s/exports/%s", result.Job.UserID, result.Job.ID))
+		return c.Status(fiber.StatusAccepted).JSON(response)
+	}
+
+	return sendArchive(c, id, result.Archive)
+}
+
+func (h *ExportHandler) GetExportJob(c *fiber.Ctx) error {
+	id := c.Params("id")
+	exportID := c.Params("exportId")
+	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	job, err := h.service.GetExportJob(ctx, principal, id, exportID)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(toExportJobResponse(job))
+}
+
+func (h *ExportHandler) DownloadExport(c *fiber.Ctx) error {
+	id := c.Params("id")
+	exportID := c.Params("exportId")
+	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	archive, err := h.service.DownloadExport(ctx, principal, id, exportID)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	return sendArchive(c, id, archive)
+}
+
+func sendArchive(c *fiber.Ctx, userID string, archive []byte) error {
+	c.Attachment(fmt.Sprintf("user-%s-export.zip", userID))
+	return c.Send(archive)
+}
+
+func toExportJobResponse(j *domain.ExportJob) dto.ExportJobResponse {
+	response := dto.ExportJobResponse{
+		ID:          j.ID,
+		UserID:      j.UserID,
+		Status:      string(j.Status),
+		Error:       j.Error,
+		Size:        j.Size,
+		CreatedAt:   j.CreatedAt,
+		CompletedAt: j.CompletedAt,
+		ExpiresAt:   j.ExpiresAt,
+	}
+	if j.IsReady() {
+		response.DownloadURL = fmt.Sprintf("/users/%s/exports/%s/download", j.UserID, j.ID)
+	}
+	return response
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/transport/http/router"
 )
 
 type HealthHandler struct{}
@@ -10,9 +11,11 @@ func NewHealthHandler() *HealthHandler {
 	return &HealthHandler{}
 }
 
-func (h *HealthHandler) Register(r fiber.Router) {
-	r.Get("/health/live", h.Live)
-	r.Get("/health/ready", h.Ready)
+func (h *HealthHandler) Routes() []router.Route {
+	return []router.Route{
+		{Method: fiber.MethodGet, Path: "/health/live", Handler: h.Live},
+		{Method: fiber.MethodGet, Path: "/health/ready", Handler: h.Ready},
+	}
 }
 
 func (h *HealthHandler) Live(c *fiber.Ctx) error {
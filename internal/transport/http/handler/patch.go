@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const mimeMergePatchJSON = "application/merge-patch+json"
+
+var errMergePatchMediaType = errors.New("Content-Type must be " + mimeMergePatchJSON)
+
+// parseMergePatch decodes an RFC 7386 merge patch into a DTO of pointers, where
+// a member left out leaves its field alone. Plain JSON is accepted as well.
+// The patch must be an object naming known fields only. A null member asks
+// for a removal, which only the removable fields allow; parseMergePatch
+// reports those in the returned set, since the DTO cannot tell them from an
+// absent member. Any other null is refused.
+func parseMergePatch(c *fiber.Ctx, out any, removable ...string) (map[string]bool, error) {
+	mediaType, _, err := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+	if err != nil || (mediaType != mimeMergePatchJSON && mediaType != fiber.MIMEApplicationJSON) {
+		return nil, errMergePatchMediaType
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(c.Body(), &members); err != nil || members == nil {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	removed := make(map[string]bool)
+	for _, name := range names {
+		if !bytes.Equal(members[name], []byte("null")) {
+			continue
+		}
+		if !slices.Contains(removable, name) {
+			return nil, fmt.Errorf("%s cannot be removed", name)
+		}
+		removed[name] = true
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(c.Body()))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+	return removed, nil
+}
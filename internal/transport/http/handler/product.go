@@ -1,10 +1,15 @@
 package handler
 
 import (
+	"fmt"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/auth"
 	"github.com/yusirdemir/microservice/internal/domain"
 	"github.com/yusirdemir/microservice/internal/dto"
+	"github.com/yusirdemir/microservice/internal/repository"
 	"github.com/yusirdemir/microservice/internal/service"
+	"github.com/yusirdemir/microservice/internal/transport/http/router"
 )
 
 type ProductHandler struct {
@@ -17,12 +22,23 @@ func NewProductHandler(service service.ProductService) *ProductHandler {
 	}
 }
 
-func (h *ProductHandler) Register(r fiber.Router) {
-	r.Post("/products", h.CreateProduct)
-	r.Get("/products/:id", h.GetProduct)
-	r.Get("/users/:id/products", h.GetUserProducts)
-	r.Put("/products/:id", h.UpdateProduct)
-	r.Delete("/products/:id", h.DeleteProduct)
+func (h *ProductHandler) Routes() []router.Route {
+	sellers := []domain.Role{domain.RoleSeller, domain.RoleAdmin}
+	read := []domain.Scope{domain.ScopeProductsRead}
+	write := []domain.Scope{domain.ScopeProductsWrite}
+	return []router.Route{
+		{Method: fiber.MethodPost, Path: "/products", Handler: h.CreateProduct, Roles: sellers, Scopes: write},
+		{Method: fiber.MethodGet, Path: "/products", Handler: h.ListProducts, Scopes: read},
+		{Method: fiber.MethodPost, Path: "/products\\:batch", Handler: h.BatchProducts, Roles: sellers, Scopes: write},
+		{Method: fiber.MethodGet, Path: "/products/search", Handler: h.SearchProducts, Scopes: read},
+		{Method: fiber.MethodGet, Path: "/products/:id", Handler: h.GetProduct, Scopes: read},
+		{Method: fiber.MethodGet, Path: "/users/:id/products", Handler: h.GetUserProducts, Scopes: read},
+		{Method: fiber.MethodPut, Path: "/products/:id", Handler: h.UpdateProduct, Roles: sellers, Scopes: write},
+		{Method: fiber.MethodPatch, Path: "/products/:id", Handler: h.PatchProduct, Roles: sellers, Scopes: write},
+		{Method: fiber.MethodPost, Path: "/products/:id/stock/adjust", Handler: h.AdjustStock, Roles: sellers, Scopes: write},
+		{Method: fiber.MethodDelete, Path: "/products/:id", Handler: h.DeleteProduct, Roles: sellers, Scopes: write},
+		{Method: fiber.MethodPost, Path: "/products/:id/restore", Handler: h.RestoreProduct, Roles: sellers, Scopes: write},
+	}
 }
 
 func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
@@ -32,17 +48,14 @@ func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
 	}
 
 	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
 
-	userID := c.Get("X-User-ID")
-	if userID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "X-User-ID header is required"})
-	}
-
-	product, err := h.service.CreateProduct(ctx, userID, req.Name, req.Price, req.Stock)
+	product, err := h.service.CreateProduct(ctx, principal.UserID, req.Name, req.Price, req.Currency, req.Stock, req.CategoryID, req.Tags)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	setETag(c, product.Version)
 	return c.Status(fiber.StatusCreated).JSON(toProductResponse(product))
 }
 
@@ -57,24 +70,108 @@ func (h *ProductHandler) GetProduct(c *fiber.Ctx) error {
 		})
 	}
 
+	setETag(c, product.Version)
 	return c.JSON(toProductResponse(product))
 }
 
-func (h *ProductHandler) GetUserProducts(c *fiber.Ctx) error {
-	userID := c.Params("id")
+// ListProducts serves the catalog. Filters: min_price and max_price
+// (inclusive, in minor units of currency), currency, category (including its
+// subcategories), tag, in_stock, owner and created_after (RFC 3339). sort is
+// price, name or created_at, prefixed with "-" for descending order. Prices
+// are not converted between currencies, so the price filters and sort=price
+// are refused without currency.
+func (h *ProductHandler) ListProducts(c *fiber.Ctx) error {
+	query := repository.ProductQuery{
+		InStock: c.QueryBool("in_stock"),
+		OwnerID: c.Query("owner"),
+		PageRequest: repository.PageRequest{
+			Limit:  c.QueryInt("limit"),
+			Cursor: c.Query("cursor"),
+		},
+	}
+
+	if sort, descending := querySort(c); sort != "" {
+		parsed, err := repository.ParseProductSort(sort)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		query.Sort, query.Descending = parsed, descending
+	}
+
+	var err error
+	if query.MinPrice, err = queryInt(c, "min_price"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if query.MaxPrice, err = queryInt(c, "max_price"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "min_price cannot exceed max_price"})
+	}
+	if query.CreatedAfter, err = queryTime(c, "created_after"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if currency := c.Query("currency"); currency != "" {
+		if query.Currency, err = domain.NormalizeCurrency(currency); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if category := c.Query("category"); category != "" {
+		query.CategoryIDs = []string{category}
+	}
+	if tag := c.Query("tag"); tag != "" {
+		tags, err := domain.NormalizeTags([]string{tag})
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		query.Tag = tags[0]
+	}
+
 	ctx := c.UserContext()
+	page, err := h.service.ListProducts(ctx, query)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(toProductListResponse(page))
+}
+
+func (h *ProductHandler) SearchProducts(c *fiber.Ctx) error {
+	q := c.Query("q")
+	if q == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Query parameter q is required"})
+	}
 
-	products, err := h.service.GetAllProductsByUserID(ctx, userID)
+	ctx := c.UserContext()
+	matches, err := h.service.SearchProducts(ctx, q, c.QueryInt("limit"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return errorResponse(c, err, fiber.StatusInternalServerError)
 	}
 
-	response := make([]dto.ProductResponse, len(products))
-	for i, p := range products {
-		response[i] = toProductResponse(p)
+	resp := dto.ProductSearchResponse{Items: make([]dto.ProductSearchResult, 0, len(matches))}
+	for _, m := range matches {
+		resp.Items = append(resp.Items, dto.ProductSearchResult{
+			ProductResponse: toProductResponse(m.Product),
+			Score:           m.Score,
+		})
 	}
 
-	return c.JSON(response)
+	return c.JSON(resp)
+}
+
+func (h *ProductHandler) GetUserProducts(c *fiber.Ctx) error {
+	userID := c.Params("id")
+	ctx := c.UserContext()
+
+	page, err := h.service.ListProductsByUserID(ctx, userID, repository.PageRequest{
+		Limit:  c.QueryInt("limit"),
+		Cursor: c.Query("cursor"),
+	})
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(toProductListResponse(page))
 }
 
 func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
@@ -83,34 +180,218 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
+	if req.Name == nil || req.Price == nil || req.Stock == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "name, price and stock are required"})
+	}
+
+	return h.updateProduct(c, id, domain.ProductPatch{
+		Name:       req.Name,
+		Price:      req.Price,
+		Currency:   req.Currency,
+		Stock:      req.Stock,
+		CategoryID: &req.CategoryID,
+		Tags:       &req.Tags,
+	})
+}
+
+// PatchProduct applies a merge patch: only the fields present change, and a
+// null category_id or tags clears it.
+func (h *ProductHandler) PatchProduct(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var req dto.PatchProductRequest
+	removed, err := parseMergePatch(c, &req, "category_id", "tags")
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusBadRequest)
+	}
+	if removed["category_id"] {
+		req.CategoryID = new(string)
+	}
+	if removed["tags"] {
+		req.Tags = new([]string)
+	}
+
+	return h.updateProduct(c, id, domain.ProductPatch{
+		Name:       req.Name,
+		Price:      req.Price,
+		Currency:   req.Currency,
+		Stock:      req.Stock,
+		CategoryID: req.CategoryID,
+		Tags:       req.Tags,
+	})
+}
+
+func (h *ProductHandler) updateProduct(c *fiber.Ctx, id string, patch domain.ProductPatch) error {
+	version, err := ifMatch(c)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusPreconditionFailed)
+	}
+
+	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	product, err := h.service.UpdateProduct(ctx, principal, id, version, patch)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusBadRequest)
+	}
+
+	setETag(c, product.Version)
+	return c.JSON(toProductResponse(product))
+}
+
+func (h *ProductHandler) AdjustStock(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var req dto.AdjustStockRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.Delta == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "delta must not be zero"})
+	}
 
 	ctx := c.UserContext()
-	product, err := h.service.UpdateProduct(ctx, id, req.Name, req.Price, req.Stock)
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	product, err := h.service.AdjustStock(ctx, principal, id, req.Delta)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return errorResponse(c, err, fiber.StatusInternalServerError)
 	}
 
+	setETag(c, product.Version)
 	return c.JSON(toProductResponse(product))
 }
 
 func (h *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
 	id := c.Params("id")
+	version, err := ifMatch(c)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusPreconditionFailed)
+	}
+
 	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
 
-	if err := h.service.DeleteProduct(ctx, id); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	if err := h.service.DeleteProduct(ctx, principal, id, version); err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
+// BatchProducts runs up to service.MaxBatchSize creates, updates and deletes.
+// The response is 200 when every operation succeeded and 207 Multi-Status
+// otherwise, with each operation's own status in the body. Operations not
+// applied because an atomic batch failed elsewhere report 424.
+func (h *ProductHandler) BatchProducts(c *fiber.Ctx) error {
+	var req dto.BatchProductRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if len(req.Operations) == 0 || len(req.Operations) > service.MaxBatchSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("operations must hold 1 to %d entries", service.MaxBatchSize)})
+	}
+
+	items := make([]service.BatchItem, 0, len(req.Operations))
+	for i, op := range req.Operations {
+		version, err := parseIfMatch(op.IfMatch)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("operations[%d]: if_match must be a single strong entity tag", i)})
+		}
+		items = append(items, service.BatchItem{
+			Op:      service.BatchOp(op.Op),
+			ID:      op.ID,
+			Version: version,
+			Patch: domain.ProductPatch{
+				Name:       op.Product.Name,
+				Price:      op.Product.Price,
+				Currency:   op.Product.Currency,
+				Stock:      op.Product.Stock,
+				CategoryID: op.Product.CategoryID,
+				Tags:       op.Product.Tags,
+			},
+		})
+	}
+
+	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	results, err := h.service.ApplyBatch(ctx, principal, items, req.Atomic)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	resp := dto.BatchProductResponse{Results: make([]dto.BatchProductResult, 0, len(results))}
+	for i, r := range results {
+		result := dto.BatchProductResult{Index: i, Op: req.Operations[i].Op, ID: req.Operations[i].ID}
+		switch {
+		case r.Err != nil:
+			result.Status = errorStatus(r.Err, fiber.StatusBadRequest)
+			result.Error = r.Err.Error()
+			resp.Failed++
+		case r.Product == nil:
+			result.Status = fiber.StatusNoContent
+			resp.Succeeded++
+		default:
+			product := toProductResponse(r.Product)
+			result.Status = fiber.StatusOK
+			if items[i].Op == service.BatchCreate {
+				result.Status = fiber.StatusCreated
+			}
+			result.ID = r.Product.ID
+			result.ETag = formatETag(r.Product.Version)
+			result.Product = &product
+			resp.Succeeded++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	status := fiber.StatusOK
+	if resp.Failed > 0 {
+		status = fiber.StatusMultiStatus
+	}
+	return c.Status(status).JSON(resp)
+}
+
+func (h *ProductHandler) RestoreProduct(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	product, err := h.service.RestoreProduct(ctx, principal, id)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusBadRequest)
+	}
+
+	setETag(c, product.Version)
+	return c.JSON(toProductResponse(product))
+}
+
 func toProductResponse(p *domain.Product) dto.ProductResponse {
+	tags := p.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
 	return dto.ProductResponse{
-		ID:        p.ID,
-		UserID:    p.UserID,
-		Name:      p.Name,
-		Price:     p.Price,
-		Stock:     p.Stock,
-		CreatedAt: p.CreatedAt,
+		ID:             p.ID,
+		UserID:         p.UserID,
+		Name:           p.Name,
+		Price:          p.Price.Amount(),
+		Currency:       p.Price.Currency(),
+		FormattedPrice: p.Price.String(),
+		Stock:          p.Stock,
+		CategoryID:     p.CategoryID,
+		Tags:           tags,
+		CreatedAt:      p.CreatedAt,
+	}
+}
+
+func toProductListResponse(page *repository.ProductPage) dto.ProductListResponse {
+	resp := dto.ProductListResponse{
+		Items:      make([]dto.ProductResponse, 0, len(page.Products)),
+		NextCursor: page.NextCursor,
+	}
+	for _, p := range page.Products {
+		resp.Items = append(resp.Items, toProductResponse(p))
 	}
+	return resp
 }
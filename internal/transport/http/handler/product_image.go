@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/dto"
+	"github.com/yusirdemir/microservice/internal/service"
+	"github.com/yusirdemir/microservice/internal/transport/http/router"
+)
+
+// imageFormField is the multipart field an upload is read from.
+const imageFormField = "image"
+
+type ProductImageHandler struct {
+	service service.ProductImageService
+}
+
+func NewProductImageHandler(service service.ProductImageService) *ProductImageHandler {
+	return &ProductImageHandler{
+		service: service,
+	}
+}
+
+func (h *ProductImageHandler) Routes() []router.Route {
+	sellers := []domain.Role{domain.RoleSeller, domain.RoleAdmin}
+	read := []domain.Scope{domain.ScopeProductsRead}
+	write := []domain.Scope{domain.ScopeProductsWrite}
+	return []router.Route{
+		{Method: fiber.MethodPost, Path: "/products/:id/images", Handler: h.UploadImage, Roles: sellers, Scopes: write},
+		{Method: fiber.MethodGet, Path: "/products/:id/images", Handler: h.ListImages, Scopes: read},
+		{Method: fiber.MethodGet, Path: "/products/:id/images/:imageId", Handler: h.GetImage, Scopes: read},
+		{Method: fiber.MethodDelete, Path: "/products/:id/images/:imageId", Handler: h.DeleteImage, Roles: sellers, Scopes: write},
+	}
+}
+
+// UploadImage takes the file from the "image" field of a multipart form. The
+// format is sniffed from the bytes, whatever the part's Content-Type says.
+func (h *ProductImageHandler) UploadImage(c *fiber.Ctx) error {
+	id := c.Params("id")
+	file, err := c.FormFile(imageFormField)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "multipart field " + imageFormField + " is required"})
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	image, err := h.service.UploadImage(ctx, principal, id, data)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(toProductImageResponse(image))
+}
+
+func (h *ProductImageHandler) ListImages(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx := c.UserContext()
+
+	images, err := h.service.ListImages(ctx, id)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	resp := dto.ProductImageListResponse{Items: make([]dto.ProductImageResponse, 0, len(images))}
+	for i := range images {
+		resp.Items = append(resp.Items, toProductImageResponse(&images[i]))
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *ProductImageHandler) GetImage(c *fiber.Ctx) error {
+	id := c.Params("id")
+	imageID := c.Params("imageId")
+	ctx := c.UserContext()
+
+	image, err := h.service.GetImage(ctx, id, imageID)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	return c.JSON(toProductImageResponse(image))
+}
+
+func (h *ProductImageHandler) DeleteImage(c *fiber.Ctx) error {
+	id := c.Params("id")
+	imageID := c.Params("imageId")
+	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	if err := h.service.DeleteImage(ctx, principal, id, imageID); err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func toProductImageResponse(si *service.SignedImage) dto.ProductImageResponse {
+	return dto.ProductImageResponse{
+		ID:           si.Image.ID,
+		ProductID:    si.Image.ProductID,
+		ContentType:  si.Image.ContentType,
+		Size:         si.Image.Size,
+		Width:        si.Image.Width,
+		Height:       si.Image.Height,
+		URL:          si.URL,
+		ThumbnailURL: si.ThumbnailURL,
+		ExpiresAt:    si.ExpiresAt,
+		CreatedAt:    si.Image.CreatedAt,
+	}
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// querySort reads the sort parameter, where a leading "-" asks for
+// descending order.
+func querySort(c *fiber.Ctx) (string, bool) {
+	sort := c.Query("sort")
+	if rest, ok := strings.CutPrefix(sort, "-"); ok {
+		return rest, true
+	}
+	return sort, false
+}
+
+// queryTime parses an optional RFC 3339 query parameter.
+func queryTime(c *fiber.Ctx, name string) (*time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, errors.New("invalid " + name + " timestamp")
+	}
+	return &t, nil
+}
+
+// queryInt parses an optional integer query parameter.
+func queryInt(c *fiber.Ctx, name string) (*int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, errors.New("invalid " + name + " value")
+	}
+	return &n, nil
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/transport/http/router"
 )
 
 type TimeoutHandler struct{}
@@ -12,9 +13,11 @@ func NewTimeoutHandler() *TimeoutHandler {
 	return &TimeoutHandler{}
 }
 
-func (h *TimeoutHandler) Register(r fiber.Router) {
-	r.Get("/timeout", h.TestTimeout)
-	r.Post("/timeout", h.TestTimeout)
+func (h *TimeoutHandler) Routes() []router.Route {
+	return []router.Route{
+		{Method: fiber.MethodGet, Path: "/timeout", Handler: h.TestTimeout},
+		{Method: fiber.MethodPost, Path: "/timeout", Handler: h.TestTimeout},
+	}
 }
 
 func (h *TimeoutHandler) TestTimeout(c *fiber.Ctx) error {
@@ -1,10 +1,15 @@
 package handler
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/auth"
 	"github.com/yusirdemir/microservice/internal/domain"
 	"github.com/yusirdemir/microservice/internal/dto"
+	"github.com/yusirdemir/microservice/internal/repository"
 	"github.com/yusirdemir/microservice/internal/service"
+	"github.com/yusirdemir/microservice/internal/transport/http/router"
 )
 
 type UserHandler struct {
@@ -17,11 +22,24 @@ func NewUserHandler(service service.UserService) *UserHandler {
 	}
 }
 
-func (h *UserHandler) Register(r fiber.Router) {
-	r.Post("/users", h.CreateUser)
-	r.Get("/users/:id", h.GetUser)
-	r.Put("/users/:id", h.UpdateUser)
-	r.Delete("/users/:id", h.DeleteUser)
+func (h *UserHandler) Routes() []router.Route {
+	admins := []domain.Role{domain.RoleAdmin}
+	return []router.Route{
+		{Method: fiber.MethodPost, Path: "/users", Handler: h.CreateUser},
+		{Method: fiber.MethodGet, Path: "/users", Handler: h.ListUsers, Roles: admins},
+		{Method: fiber.MethodGet, Path: "/users/:id", Handler: h.GetUser, Auth: true},
+		{Method: fiber.MethodPut, Path: "/users/:id", Handler: h.UpdateUser, Auth: true},
+		{Method: fiber.MethodPatch, Path: "/users/:id", Handler: h.PatchUser, Auth: true},
+		{Method: fiber.MethodPut, Path: "/users/:id/password", Handler: h.ChangePassword, Auth: true},
+		{Method: fiber.MethodPut, Path: "/users/:id/roles", Handler: h.UpdateRoles, Roles: admins},
+		{Method: fiber.MethodPost, Path: "/users/:id/email", Handler: h.RequestEmailChange, Auth: true},
+		{Method: fiber.MethodPost, Path: "/users/email/confirm", Handler: h.ConfirmEmailChange},
+		{Method: fiber.MethodDelete, Path: "/users/:id", Handler: h.DeleteUser, Auth: true},
+		{Method: fiber.MethodPost, Path: "/users/:id/restore", Handler: h.RestoreUser, Roles: admins},
+		{Method: fiber.MethodPost, Path: "/users/:id/purge", Handler: h.PurgeUser, Roles: admins},
+		{Method: fiber.MethodPost, Path: "/auth/password-reset", Handler: h.RequestPasswordReset},
+		{Method: fiber.MethodPost, Path: "/auth/password-reset/confirm", Handler: h.ConfirmPasswordReset},
+	}
 }
 
 func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
@@ -30,15 +48,27 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
+	var role domain.Role
+	if req.Role != "" {
+		parsed, err := domain.ParseRole(req.Role)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		role = parsed
+	}
+
 	ctx := c.UserContext()
-	user, err := h.service.CreateUser(ctx, req.Name, req.Email, req.Password)
+	user, err := h.service.CreateUser(ctx, req.Name, req.Email, req.Password, role)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return errorResponse(c, err, fiber.StatusBadRequest)
 	}
 
+	setETag(c, user.Version())
 	return c.Status(fiber.StatusCreated).JSON(toUserResponse(user))
 }
 
+// GetUser returns the whole account to its owner and to admins. Anyone else
+// signed in sees the public profile only.
 func (h *UserHandler) GetUser(c *fiber.Ctx) error {
 	id := c.Params("id")
 	ctx := c.UserContext()
@@ -50,9 +80,59 @@ func (h *UserHandler) GetUser(c *fiber.Ctx) error {
 		})
 	}
 
+	principal, _ := auth.PrincipalFromContext(ctx)
+	if !principal.CanActOn(id) {
+		return c.JSON(toUserProfileResponse(user))
+	}
+
+	setETag(c, user.Version())
 	return c.JSON(toUserResponse(user))
 }
 
+// ListUsers pages through accounts. email matches the start of the address,
+// ignoring case; sort takes a field name, prefixed with "-" for descending
+// order; created_from and created_to are RFC 3339.
+func (h *UserHandler) ListUsers(c *fiber.Ctx) error {
+	query := repository.UserQuery{
+		NamePrefix:  c.Query("name"),
+		EmailPrefix: c.Query("email"),
+		Limit:       c.QueryInt("limit"),
+		Cursor:      c.Query("cursor"),
+	}
+
+	if sort, descending := querySort(c); sort != "" {
+		parsed, err := repository.ParseUserSort(sort)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		query.Sort, query.Descending = parsed, descending
+	}
+
+	var err error
+	if query.CreatedFrom, err = queryTime(c, "created_from"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if query.CreatedTo, err = queryTime(c, "created_to"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx := c.UserContext()
+	page, err := h.service.ListUsers(ctx, query)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	resp := dto.UserListResponse{
+		Items:      make([]dto.UserResponse, 0, len(page.Users)),
+		NextCursor: page.NextCursor,
+	}
+	for _, user := range page.Users {
+		resp.Items = append(resp.Items, toUserResponse(user))
+	}
+
+	return c.JSON(resp)
+}
+
 func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var req dto.UpdateUserRequest
@@ -60,32 +140,214 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
+	return h.updateUser(c, id, domain.UserPatch{Name: &req.Name})
+}
+
+// PatchUser applies a merge patch to the profile.
+func (h *UserHandler) PatchUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var req dto.PatchUserRequest
+	if _, err := parseMergePatch(c, &req); err != nil {
+		return errorResponse(c, err, fiber.StatusBadRequest)
+	}
+
+	return h.updateUser(c, id, domain.UserPatch{Name: req.Name})
+}
+
+func (h *UserHandler) updateUser(c *fiber.Ctx, id string, patch domain.UserPatch) error {
+	version, err := ifMatch(c)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusPreconditionFailed)
+	}
+
 	ctx := c.UserContext()
-	user, err := h.service.UpdateUser(ctx, id, req.Name, "")
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	user, err := h.service.UpdateUser(ctx, principal, id, version, patch)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return errorResponse(c, err, fiber.StatusBadRequest)
+	}
+
+	setETag(c, user.Version())
+	return c.JSON(toUserResponse(user))
+}
+
+func (h *UserHandler) ChangePassword(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var req dto.ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	if err := h.service.ChangePassword(ctx, principal, id, req.CurrentPassword, req.NewPassword); err != nil {
+		return errorResponse(c, err, fiber.StatusBadRequest)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *UserHandler) RequestEmailChange(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var req dto.ChangeEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	if err := h.service.RequestEmailChange(ctx, principal, id, req.Email); err != nil {
+		return errorResponse(c, err, fiber.StatusBadRequest)
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+func (h *UserHandler) ConfirmEmailChange(c *fiber.Ctx) error {
+	var req dto.ConfirmEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
+	ctx := c.UserContext()
+	user, err := h.service.ConfirmEmailChange(ctx, req.Token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return errorResponse(c, err, fiber.StatusBadRequest)
+	}
+
+	setETag(c, user.Version())
+	return c.JSON(toUserResponse(user))
+}
+
+func (h *UserHandler) UpdateRoles(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var req dto.UpdateRolesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	roles := make([]domain.Role, 0, len(req.Roles))
+	for _, r := range req.Roles {
+		role, err := domain.ParseRole(r)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		roles = append(roles, role)
+	}
+
+	ctx := c.UserContext()
+	user, err := h.service.UpdateRoles(ctx, id, roles)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusBadRequest)
+	}
+
+	setETag(c, user.Version())
 	return c.JSON(toUserResponse(user))
 }
 
 func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	id := c.Params("id")
+	version, err := ifMatch(c)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusPreconditionFailed)
+	}
+
 	ctx := c.UserContext()
+	principal, _ := auth.PrincipalFromContext(ctx)
 
-	if err := h.service.DeleteUser(ctx, id); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	if err := h.service.DeleteUser(ctx, principal, id, version); err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *UserHandler) RestoreUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx := c.UserContext()
+
+	user, err := h.service.RestoreUser(ctx, id)
+	if err != nil {
+		return errorResponse(c, err, fiber.StatusBadRequest)
+	}
+
+	setETag(c, user.Version())
+	return c.JSON(toUserResponse(user))
+}
+
+func (h *UserHandler) PurgeUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx := c.UserContext()
+
+	if err := h.service.PurgeUser(ctx, id); err != nil {
+		return errorResponse(c, err, fiber.StatusInternalServerError)
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
 func toUserResponse(u *domain.User) dto.UserResponse {
+	roles := make([]string, 0, len(u.Roles()))
+	for _, r := range u.Roles() {
+		roles = append(roles, string(r))
+	}
+
 	return dto.UserResponse{
 		ID:        u.ID(),
 		Name:      u.Name(),
 		Email:     u.Email(),
+		Roles:     roles,
 		CreatedAt: u.CreatedAt(),
 		UpdatedAt: u.UpdatedAt(),
 	}
 }
+
+func toUserProfileResponse(u *domain.User) dto.UserProfileResponse {
+	roles := make([]string, 0, len(u.Roles()))
+	for _, r := range u.Roles() {
+		roles = append(roles, string(r))
+	}
+
+	return dto.UserProfileResponse{
+		ID:    u.ID(),
+		Name:  u.Name(),
+		Roles: roles,
+	}
+}
+
+func (h *UserHandler) RequestPasswordReset(c *fiber.Ctx) error {
+	var req dto.PasswordResetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if err := h.service.RequestPasswordReset(c.UserContext(), req.Email, c.IP()); err != nil {
+		setRetryAfter(c, err)
+		return errorResponse(c, err, fiber.StatusInternalServerError)
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+func (h *UserHandler) ConfirmPasswordReset(c *fiber.Ctx) error {
+	var req dto.ConfirmPasswordResetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx := c.UserContext()
+	if err := h.service.ResetPassword(ctx, req.Token, req.NewPassword); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return errorResponse(c, err, fiber.StatusBadRequest)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/service"
+	"github.com/yusirdemir/microservice/internal/tenant"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+func Authenticate(authService service.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if key := c.Get(HeaderAPIKey); key != "" {
+			ctx := c.UserContext()
+			principal, err := authService.AuthenticateAPIKey(ctx, key)
+			if err != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+			}
+
+			c.SetUserContext(auth.WithPrincipal(ctx, principal))
+			return c.Next()
+		}
+
+		header := c.Get(fiber.HeaderAuthorization)
+		if header == "" {
+			return c.Next()
+		}
+
+		scheme, token, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid authorization header"})
+		}
+
+		ctx := c.UserContext()
+		principal, err := authService.Authenticate(ctx, token)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		// A token only works within the tenant it was issued for; when the
+		// request names no tenant, the token's claim decides.
+		if requested, ok := tenant.Lookup(ctx); ok && requested != principal.TenantID {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Token was issued for another tenant"})
+		}
+		ctx = tenant.WithTenant(ctx, principal.TenantID)
+
+		c.SetUserContext(auth.WithPrincipal(ctx, principal))
+		return c.Next()
+	}
+}
+
+func RequireAuth(c *fiber.Ctx) error {
+	if _, ok := auth.PrincipalFromContext(c.UserContext()); !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Authentication required"})
+	}
+	return c.Next()
+}
+
+func RequireRoles(roles ...domain.Role) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, ok := auth.PrincipalFromContext(c.UserContext())
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Authentication required"})
+		}
+		if !principal.HasAnyRole(roles...) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Insufficient role"})
+		}
+		return c.Next()
+	}
+}
+
+// RequireScopes limits API key callers to routes whose scopes their key
+// holds. An empty scope list admits no API key at all. Callers using a user
+// token are not affected.
+func RequireScopes(scopes ...domain.Scope) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, ok := auth.PrincipalFromContext(c.UserContext())
+		if !ok || principal.APIKeyID == "" {
+			return c.Next()
+		}
+		if len(scopes) == 0 || !principal.HasScopes(scopes...) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Insufficient scope"})
+		}
+		return c.Next()
+	}
+}
@@ -6,26 +6,41 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/yusirdemir/microservice/internal/metrics"
+	"github.com/yusirdemir/microservice/internal/tenant"
 )
 
-func Metrics(c *fiber.Ctx) error {
-	start := time.Now()
+// unknownTenant labels requests for tenants outside the registry, so clients
+// cannot mint label values and with them unbounded series.
+const unknownTenant = "unknown"
 
-	err := c.Next()
+// Metrics records request counts and durations. The tenant label only ever
+// takes a value from tenants, or unknownTenant.
+func Metrics(tenants *tenant.Registry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
 
-	duration := time.Since(start).Seconds()
+		err := c.Next()
 
-	path := c.Route().Path
-	if path == "" {
-		path = "/unknown-route"
-	}
+		duration := time.Since(start).Seconds()
+
+		path := c.Route().Path
+		if path == "" {
+			path = "/unknown-route"
+		}
 
-	method := c.Method()
-	statusCode := c.Response().StatusCode()
-	statusStr := strconv.Itoa(statusCode)
+		method := c.Method()
+		statusCode := c.Response().StatusCode()
+		statusStr := strconv.Itoa(statusCode)
 
-	metrics.HttpRequestsTotal.WithLabelValues(method, path, statusStr).Inc()
-	metrics.HttpRequestDuration.WithLabelValues(method, path).Observe(duration)
+		// Read after c.Next so the tenant resolved further down the chain counts.
+		tenantID := tenant.FromContext(c.UserContext())
+		if !tenants.Contains(tenantID) {
+			tenantID = unknownTenant
+		}
 
-	return err
+		metrics.HttpRequestsTotal.WithLabelValues(method, path, statusStr, tenantID).Inc()
+		metrics.HttpRequestDuration.WithLabelValues(method, path, tenantID).Observe(duration)
+
+		return err
+	}
 }
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/tenant"
+)
+
+// Tenant resolves the request's tenant from the given header or, when
+// baseDomain is set, from the subdomain in front of it: acme.shop.example
+// with base domain shop.example belongs to tenant acme. A tenant missing from
+// tenants is refused. Requests naming neither are left unresolved;
+// Authenticate may then take the tenant from the caller's token, and the
+// repositories fall back to tenant.Default.
+func Tenant(header, baseDomain string, tenants *tenant.Registry) fiber.Handler {
+	suffix := "." + strings.ToLower(baseDomain)
+
+	return func(c *fiber.Ctx) error {
+		id := c.Get(header)
+		if id == "" && baseDomain != "" {
+			host := c.Hostname()
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			if sub, ok := strings.CutSuffix(strings.ToLower(host), suffix); ok && !strings.Contains(sub, ".") {
+				id = sub
+			}
+		}
+		if id == "" {
+			return c.Next()
+		}
+
+		// The header value aliases fiber's buffer, and the tenant outlives
+		// the request in background jobs.
+		id = strings.Clone(strings.ToLower(id))
+		if err := tenant.Validate(id); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if !tenants.Contains(id) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": tenant.ErrUnknown.Error()})
+		}
+
+		c.SetUserContext(tenant.WithTenant(c.UserContext(), id))
+		return c.Next()
+	}
+}
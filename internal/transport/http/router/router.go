@@ -2,10 +2,26 @@ package router
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/domain"
+	"github.com/yusirdemir/microservice/internal/transport/http/middleware"
 )
 
+// Route describes a single endpoint. Routes that set Auth or Roles are only
+// reachable by authenticated callers; Roles further requires the caller to
+// hold at least one of the listed roles. Scopes lists what an API key must
+// carry to call the route; authenticated routes without scopes are closed to
+// API keys.
+type Route struct {
+	Method  string
+	Path    string
+	Handler fiber.Handler
+	Auth    bool
+	Roles   []domain.Role
+	Scopes  []domain.Scope
+}
+
 type RouteHandler interface {
-	Register(r fiber.Router)
+	Routes() []Route
 }
 
 type Router struct {
@@ -22,6 +38,23 @@ func New(app *fiber.App, handlers []RouteHandler) *Router {
 
 func (r *Router) SetupRoutes() {
 	for _, h := range r.Handlers {
-		h.Register(r.App)
+		for _, route := range h.Routes() {
+			r.App.Add(route.Method, route.Path, chain(route)...)
+		}
+	}
+}
+
+func chain(route Route) []fiber.Handler {
+	var handlers []fiber.Handler
+	authenticated := route.Auth || len(route.Roles) > 0
+	if authenticated {
+		handlers = append(handlers, middleware.RequireAuth)
+	}
+	if len(route.Roles) > 0 {
+		handlers = append(handlers, middleware.RequireRoles(route.Roles...))
+	}
+	if authenticated || len(route.Scopes) > 0 {
+		handlers = append(handlers, middleware.RequireScopes(route.Scopes...))
 	}
+	return append(handlers, route.Handler)
 }
@@ -0,0 +1,67 @@
+package router
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
+)
+
+type testRoutes []Route
+
+func (r testRoutes) Routes() []Route {
+	return r
+}
+
+func TestRouter_APIKeyScopes(t *testing.T) {
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	routes := testRoutes{
+		{Method: fiber.MethodGet, Path: "/read", Handler: ok, Auth: true, Scopes: []domain.Scope{domain.ScopeProductsRead}},
+		{Method: fiber.MethodGet, Path: "/write", Handler: ok, Auth: true, Scopes: []domain.Scope{domain.ScopeProductsWrite}},
+		{Method: fiber.MethodGet, Path: "/account", Handler: ok, Auth: true},
+	}
+
+	principals := map[string]*auth.Principal{
+		"user":   {UserID: "u1", Roles: []domain.Role{domain.RoleCustomer}},
+		"reader": {UserID: "u1", Roles: []domain.Role{domain.RoleCustomer}, APIKeyID: "k1", Scopes: []domain.Scope{domain.ScopeProductsRead}},
+	}
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		if principal, found := principals[c.Get("X-Test-Caller")]; found {
+			c.SetUserContext(auth.WithPrincipal(c.UserContext(), principal))
+		}
+		return c.Next()
+	})
+	New(app, []RouteHandler{routes}).SetupRoutes()
+
+	tests := []struct {
+		caller string
+		path   string
+		want   int
+	}{
+		{"user", "/read", fiber.StatusOK},
+		{"user", "/write", fiber.StatusOK},
+		{"user", "/account", fiber.StatusOK},
+		{"reader", "/read", fiber.StatusOK},
+		{"reader", "/write", fiber.StatusForbidden},
+		// Routes without scopes are closed to API keys altogether.
+		{"reader", "/account", fiber.StatusForbidden},
+		{"", "/read", fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caller+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodGet, tt.path, nil)
+			req.Header.Set("X-Test-Caller", tt.caller)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -12,24 +13,44 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/timeout"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/yusirdemir/microservice/internal/auth"
+	"github.com/yusirdemir/microservice/internal/domain"
 	"github.com/yusirdemir/microservice/internal/repository"
 	"github.com/yusirdemir/microservice/internal/repository/couchbase"
 	"github.com/yusirdemir/microservice/internal/repository/memory"
 	"github.com/yusirdemir/microservice/internal/service"
+	"github.com/yusirdemir/microservice/internal/tenant"
 	"github.com/yusirdemir/microservice/internal/transport/http/handler"
 	"github.com/yusirdemir/microservice/internal/transport/http/middleware"
 	"github.com/yusirdemir/microservice/internal/transport/http/router"
+	"github.com/yusirdemir/microservice/pkg/blobstore"
+	"github.com/yusirdemir/microservice/pkg/concurrency"
 	"github.com/yusirdemir/microservice/pkg/config"
+	"github.com/yusirdemir/microservice/pkg/mailer"
+	"github.com/yusirdemir/microservice/pkg/password"
 	"github.com/yusirdemir/microservice/pkg/telemetry"
 	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// multipartOverhead is the room an upload form needs beyond the file itself.
+const multipartOverhead = 64 << 10
+
 type Server struct {
 	App    *fiber.App
 	Config *config.Config
 	Logger *zap.Logger
+
+	jobs          context.Context
+	stopJobs      context.CancelFunc
+	purgeInterval time.Duration
+	purge         func(ctx context.Context) error
+	// pools run work requests hand off; they stop after the last request.
+	pools []*concurrency.WorkerPool
+	// closeDatabase runs once the pools, the last users of the repositories,
+	// have stopped.
+	closeDatabase func() error
 }
 
 func New(cfg *config.Config, logger *zap.Logger, version string) (*Server, error) {
@@ -51,6 +72,124 @@ func New(cfg *config.Config, logger *zap.Logger, version string) (*Server, error
 		return nil, err
 	}
 
+	accessTokenTTL, err := time.ParseDuration(cfg.Auth.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTokenTTL, err := time.ParseDuration(cfg.Auth.RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	emailChangeTTL, err := time.ParseDuration(cfg.Auth.EmailChangeTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordResetTTL, err := time.ParseDuration(cfg.Auth.PasswordResetTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	loginBaseDelay, err := time.ParseDuration(cfg.Login.BaseDelay)
+	if err != nil {
+		return nil, err
+	}
+	loginMaxDelay, err := time.ParseDuration(cfg.Login.MaxDelay)
+	if err != nil {
+		return nil, err
+	}
+	loginLockoutDuration, err := time.ParseDuration(cfg.Login.LockoutDuration)
+	if err != nil {
+		return nil, err
+	}
+	loginResetAfter, err := time.ParseDuration(cfg.Login.ResetAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	accountLockoutPolicy := domain.LockoutPolicy{
+		FreeAttempts:     cfg.Login.FreeAttempts,
+		BaseDelay:        loginBaseDelay,
+		MaxDelay:         loginMaxDelay,
+		LockoutThreshold: cfg.Login.AccountLockoutThreshold,
+		LockoutDuration:  loginLockoutDuration,
+		ResetAfter:       loginResetAfter,
+	}
+	ipLockoutPolicy := accountLockoutPolicy
+	ipLockoutPolicy.LockoutThreshold = cfg.Login.IPLockoutThreshold
+
+	resetBaseDelay, err := time.ParseDuration(cfg.Reset.BaseDelay)
+	if err != nil {
+		return nil, err
+	}
+	resetMaxDelay, err := time.ParseDuration(cfg.Reset.MaxDelay)
+	if err != nil {
+		return nil, err
+	}
+	resetAfter, err := time.ParseDuration(cfg.Reset.ResetAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	emailResetPolicy := domain.LockoutPolicy{
+		FreeAttempts: cfg.Reset.FreeRequests,
+		BaseDelay:    resetBaseDelay,
+		MaxDelay:     resetMaxDelay,
+		ResetAfter:   resetAfter,
+	}
+	ipResetPolicy := emailResetPolicy
+	ipResetPolicy.FreeAttempts = cfg.Reset.IPFreeRequests
+
+	if cfg.Export.Workers <= 0 || cfg.Export.QueueSize <= 0 {
+		return nil, errors.New("invalid export config: workers and queue size must be positive")
+	}
+	if cfg.Mailer.Workers <= 0 || cfg.Mailer.QueueSize <= 0 {
+		return nil, errors.New("invalid mailer config: workers and queue size must be positive")
+	}
+
+	gracePeriod, err := time.ParseDuration(cfg.Deletion.GracePeriod)
+	if err != nil {
+		return nil, err
+	}
+	purgeInterval, err := time.ParseDuration(cfg.Deletion.PurgeInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	exportTTL, err := time.ParseDuration(cfg.Export.TTL)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultCurrency, err := domain.NormalizeCurrency(cfg.Catalog.DefaultCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	blobURLTTL, err := time.ParseDuration(cfg.Blob.URLTTL)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Images.MaxSize <= 0 || cfg.Images.MaxPerProduct <= 0 || cfg.Images.ThumbnailSize <= 0 || cfg.Images.MaxProcessing <= 0 {
+		return nil, errors.New("invalid images config: limits must be positive")
+	}
+
+	// Uploads arrive as multipart forms, so the body limit leaves room for
+	// the form around the largest accepted image.
+	bodyLimit := max(fiber.DefaultBodyLimit, int(cfg.Images.MaxSize)+multipartOverhead)
+
+	tenants, err := tenant.NewRegistry(cfg.Tenant.Tenants)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tenant config: %w", err)
+	}
+
+	tokenManager, err := auth.NewTokenManager(cfg.Auth.SigningKey, cfg.Auth.Issuer, accessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
 	logLevel, err := zapcore.ParseLevel(cfg.Logger.Level)
 	if err != nil {
 		logLevel = zapcore.InfoLevel
@@ -62,6 +201,13 @@ func New(cfg *config.Config, logger *zap.Logger, version string) (*Server, error
 		ReadTimeout:           readTimeout,
 		WriteTimeout:          writeTimeout,
 		IdleTimeout:           idleTimeout,
+		BodyLimit:             bodyLimit,
+		// Client addresses key the login throttling, so a proxy header is
+		// only believed from the proxies we run.
+		ProxyHeader:             cfg.Server.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.Server.TrustedProxies,
+		EnableIPValidation:      true,
 	})
 
 	if tracer != nil {
@@ -79,7 +225,7 @@ func New(cfg *config.Config, logger *zap.Logger, version string) (*Server, error
 		return h(c)
 	})
 
-	app.Use(middleware.Metrics)
+	app.Use(middleware.Metrics(tenants))
 
 	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
 
@@ -103,29 +249,147 @@ func New(cfg *config.Config, logger *zap.Logger, version string) (*Server, error
 
 	var userRepo repository.UserRepository
 	var productRepo repository.ProductRepository
-	var errRepo error
+	var categoryRepo repository.CategoryRepository
+	var imageRepo repository.ProductImageRepository
+	var sessionRepo repository.SessionRepository
+	var tokenRepo repository.OneTimeTokenRepository
+	var apiKeyRepo repository.APIKeyRepository
+	var loginAttemptRepo repository.LoginAttemptRepository
+	var exportJobRepo repository.ExportJobRepository
+	var searchIndex repository.SearchIndex
+	// closeDatabase releases the connection every repository shares.
+	closeDatabase := func() error { return nil }
 
 	switch cfg.Database.Driver {
 	case "couchbase":
-		userRepo, errRepo = couchbase.NewUserRepository(cfg)
-		if errRepo == nil {
-			productRepo, errRepo = couchbase.NewProductRepository(cfg)
+		conn, err := couchbase.Connect(cfg.Database)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to couchbase: %w", err)
 		}
+		closeDatabase = conn.Close
+
+		productRepo, err = couchbase.NewProductRepository(conn, cfg.Catalog.DefaultCurrency)
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to initialize repository: %w", err)
+		}
+		userRepo = couchbase.NewUserRepository(conn)
+		categoryRepo = couchbase.NewCategoryRepository(conn)
+		imageRepo = couchbase.NewProductImageRepository(conn)
+		sessionRepo = couchbase.NewSessionRepository(conn)
+		tokenRepo = couchbase.NewOneTimeTokenRepository(conn)
+		apiKeyRepo = couchbase.NewAPIKeyRepository(conn)
+		loginAttemptRepo = couchbase.NewLoginAttemptRepository(conn)
+		exportJobRepo = couchbase.NewExportJobRepository(conn)
+		searchIndex = couchbase.NewSearchIndex(conn, cfg.Database.SearchIndex)
 	default:
 		userRepo = memory.NewUserRepository()
 		productRepo = memory.NewProductRepository()
+		categoryRepo = memory.NewCategoryRepository()
+		imageRepo = memory.NewProductImageRepository()
+		sessionRepo = memory.NewSessionRepository()
+		tokenRepo = memory.NewOneTimeTokenRepository()
+		apiKeyRepo = memory.NewAPIKeyRepository()
+		loginAttemptRepo = memory.NewLoginAttemptRepository()
+		exportJobRepo = memory.NewExportJobRepository()
+		searchIndex = memory.NewSearchIndex()
 	}
 
-	if errRepo != nil {
-		return nil, fmt.Errorf("failed to initialize repository: %w", errRepo)
+	var mail mailer.Mailer
+	switch cfg.Mailer.Driver {
+	case "smtp":
+		mail, err = mailer.NewSMTPMailer(cfg.Mailer.From, cfg.Mailer.SMTPHost, cfg.Mailer.SMTPPort, cfg.Mailer.SMTPUsername, cfg.Mailer.SMTPPassword)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize mailer: %w", err)
+		}
+	case "file":
+		mail, err = mailer.NewFileOutbox(cfg.Mailer.From, cfg.Mailer.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize mailer: %w", err)
+		}
+	default:
+		mail = mailer.NewOutbox(cfg.Mailer.From)
 	}
 
-	userService := service.NewUserService(userRepo)
-	productService := service.NewProductService(productRepo)
+	var blobs blobstore.BlobStore
+	switch cfg.Blob.Driver {
+	case "s3":
+		blobs, err = blobstore.NewS3Store(blobstore.S3Config{
+			Endpoint:        cfg.Blob.S3Endpoint,
+			Region:          cfg.Blob.S3Region,
+			Bucket:          cfg.Blob.S3Bucket,
+			AccessKeyID:     cfg.Blob.S3AccessKeyID,
+			SecretAccessKey: cfg.Blob.S3SecretAccessKey,
+			PathStyle:       cfg.Blob.S3PathStyle,
+		})
+	case "local":
+		blobs, err = blobstore.NewLocalStore(cfg.Blob.Dir, cfg.Blob.BaseURL, cfg.Blob.SigningKey)
+	default:
+		err = fmt.Errorf("unknown driver %q", cfg.Blob.Driver)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize blob store: %w", err)
+	}
+
+	bcryptHasher, err := password.NewBcrypt(cfg.Password.BcryptCost)
+	if err != nil {
+		return nil, fmt.Errorf("invalid password config: %w", err)
+	}
+	argon2Hasher, err := password.NewArgon2id(password.Argon2idParams{
+		Memory:      cfg.Password.Argon2Memory,
+		Iterations:  cfg.Password.Argon2Iterations,
+		Parallelism: cfg.Password.Argon2Parallelism,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid password config: %w", err)
+	}
+
+	// Hashes from the non-preferred algorithm still verify and are upgraded on
+	// the next successful login.
+	var hasher *password.Hasher
+	switch cfg.Password.Algorithm {
+	case "bcrypt":
+		hasher = password.NewHasher(bcryptHasher, argon2Hasher)
+	case "argon2id":
+		hasher = password.NewHasher(argon2Hasher, bcryptHasher)
+	default:
+		return nil, fmt.Errorf("invalid password config: unknown algorithm %q", cfg.Password.Algorithm)
+	}
+
+	imageService := service.NewProductImageService(imageRepo, productRepo, blobs, cfg.Images.MaxSize, cfg.Images.MaxPerProduct, cfg.Images.ThumbnailSize, cfg.Images.MaxProcessing, blobURLTTL)
+	productService := service.NewProductService(productRepo, categoryRepo, searchIndex, imageService, gracePeriod, defaultCurrency)
+	mailQueue := concurrency.NewWorkerPool(cfg.Mailer.Workers, cfg.Mailer.QueueSize)
+	resetGuard := service.NewPasswordResetGuard(loginAttemptRepo, emailResetPolicy, ipResetPolicy)
+	userService := service.NewUserService(userRepo, productRepo, productService, sessionRepo, tokenRepo, hasher, mail, resetGuard, mailQueue, emailChangeTTL, passwordResetTTL, gracePeriod)
+	// Accounts never cross tenants, so every tenant gets its own bootstrap
+	// administrator.
+	if cfg.Auth.AdminEmail != "" {
+		for _, id := range tenants.IDs() {
+			if err := userService.EnsureAdmin(tenant.WithTenant(context.Background(), id), "Administrator", cfg.Auth.AdminEmail, cfg.Auth.AdminPassword); err != nil {
+				return nil, fmt.Errorf("failed to bootstrap admin user for tenant %s: %w", id, err)
+			}
+		}
+	}
+
+	loginGuard := service.NewLoginGuard(loginAttemptRepo, accountLockoutPolicy, ipLockoutPolicy)
+	authService := service.NewAuthService(userService, sessionRepo, apiKeyRepo, loginGuard, tokenManager, refreshTokenTTL)
+	categoryService := service.NewCategoryService(categoryRepo, productRepo)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo)
+	exportBuilders := concurrency.NewWorkerPool(cfg.Export.Workers, cfg.Export.QueueSize)
+	exportService := service.NewExportService(userRepo, productRepo, exportJobRepo, exportBuilders, cfg.Export.SyncLimit, exportTTL)
+
+	app.Use(middleware.Tenant(cfg.Tenant.Header, cfg.Tenant.BaseDomain, tenants))
+	app.Use(middleware.Authenticate(authService))
 
 	handlers := []router.RouteHandler{
+		handler.NewAuthHandler(authService, loginGuard),
+		handler.NewAPIKeyHandler(apiKeyService),
 		handler.NewUserHandler(userService),
+		handler.NewExportHandler(exportService),
 		handler.NewProductHandler(productService),
+		handler.NewProductImageHandler(imageService),
+		handler.NewBlobHandler(blobs),
+		handler.NewCategoryHandler(categoryService),
 		handler.NewHealthHandler(),
 		handler.NewTimeoutHandler(),
 	}
@@ -133,19 +397,69 @@ func New(cfg *config.Config, logger *zap.Logger, version string) (*Server, error
 	r := router.New(app, handlers)
 	r.SetupRoutes()
 
+	jobs, stopJobs := context.WithCancel(context.Background())
+
 	return &Server{
-		App:    app,
-		Config: cfg,
-		Logger: logger,
+		App:           app,
+		Config:        cfg,
+		Logger:        logger,
+		jobs:          jobs,
+		stopJobs:      stopJobs,
+		purgeInterval: purgeInterval,
+		pools:         []*concurrency.WorkerPool{mailQueue, exportBuilders},
+		closeDatabase: closeDatabase,
+		purge: func(ctx context.Context) error {
+			// Repositories only ever see one tenant, so the sweep visits
+			// each in turn.
+			tenants, err := userRepo.ListTenants(ctx)
+			if err != nil {
+				return err
+			}
+			for _, id := range tenants {
+				tenantCtx := tenant.WithTenant(ctx, id)
+				if _, err := userService.PurgeDeleted(tenantCtx); err != nil {
+					return err
+				}
+				if err := productService.PurgeDeleted(tenantCtx); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
 	}, nil
 }
 
 func (s *Server) Run() error {
+	go s.runPurge()
+
 	port := ":" + s.Config.App.Port
 	s.Logger.Info("Initializing server...", zap.String("address", port))
 	return s.App.Listen(port)
 }
 
 func (s *Server) Shutdown() error {
-	return s.App.ShutdownWithTimeout(10 * time.Second)
+	s.stopJobs()
+	err := s.App.ShutdownWithTimeout(10 * time.Second)
+	for _, pool := range s.pools {
+		pool.Close()
+	}
+	return errors.Join(err, s.closeDatabase())
+}
+
+// runPurge hard-deletes soft-deleted records whose grace period is over. Every
+// replica runs it; the deletes are idempotent, so overlapping runs are fine.
+func (s *Server) runPurge() {
+	ticker := time.NewTicker(s.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.jobs.Done():
+			return
+		case <-ticker.C:
+			if err := s.purge(s.jobs); err != nil {
+				s.Logger.Error("Failed to purge deleted records", zap.Error(err))
+			}
+		}
+	}
 }
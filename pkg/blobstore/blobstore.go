@@ -0,0 +1,52 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound      = errors.New("blob not found")
+	ErrInvalidKey    = errors.New("invalid blob key")
+	ErrURLExpired    = errors.New("signed url has expired")
+	ErrURLSignature  = errors.New("signed url signature is invalid")
+	errTTLOutOfRange = errors.New("signed url ttl out of range")
+)
+
+// Blob is an object read back from a store. Callers close Body.
+type Blob struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+// BlobStore keeps opaque files under slash-separated keys such as
+// "tenant/products/42/image.png".
+type BlobStore interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (*Blob, error)
+	// Delete succeeds when the blob does not exist.
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that serves the blob to anyone holding it
+	// until ttl has passed.
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// URLVerifier is implemented by stores whose signed URLs point back at this
+// service rather than at the store itself, which then has to check them.
+type URLVerifier interface {
+	Verify(key string, expires string, signature string, now time.Time) error
+}
+
+// checkKey refuses keys that are not clean relative paths, so no key can
+// escape the store's root.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return ErrInvalidKey
+	}
+	return nil
+}
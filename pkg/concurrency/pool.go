@@ -0,0 +1,77 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrQueueFull  = errors.New("work queue is full")
+	ErrPoolClosed = errors.New("worker pool is closed")
+)
+
+type task struct {
+	ctx context.Context
+	fn  func(ctx context.Context)
+}
+
+// WorkerPool runs tasks on a fixed number of goroutines. The queue in front of
+// them is bounded, so a burst of work is refused instead of piling up.
+type WorkerPool struct {
+	tasks  chan task
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewWorkerPool(workers, queueSize int) *WorkerPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &WorkerPool{
+		tasks:  make(chan task, queueSize),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for range workers {
+		p.wg.Go(p.work)
+	}
+	return p
+}
+
+func (p *WorkerPool) work() {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case t := <-p.tasks:
+			ctx, cancel := context.WithCancel(t.ctx)
+			stop := context.AfterFunc(p.ctx, cancel)
+			t.fn(ctx)
+			stop()
+			cancel()
+		}
+	}
+}
+
+// Submit queues fn without blocking and fails with ErrQueueFull when the queue
+// is at capacity. fn sees ctx's values but not its cancellation, so it can
+// outlive the request that queued it; it is cancelled when the pool closes.
+func (p *WorkerPool) Submit(ctx context.Context, fn func(ctx context.Context)) error {
+	if p.ctx.Err() != nil {
+		return ErrPoolClosed
+	}
+
+	select {
+	case p.tasks <- task{ctx: context.WithoutCancel(ctx), fn: fn}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close cancels running tasks, drops queued ones and waits for the workers to
+// return.
+func (p *WorkerPool) Close() {
+	p.cancel()
+	p.wg.Wait()
+}
@@ -0,0 +1,76 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool(t *testing.T) {
+	t.Run("refuses work beyond the queue", func(t *testing.T) {
+		pool := NewWorkerPool(1, 1)
+		defer pool.Close()
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		if err := pool.Submit(context.Background(), func(context.Context) {
+			close(started)
+			<-release
+		}); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+		<-started
+
+		// The worker is busy, so one task fits in the queue and the next does not.
+		if err := pool.Submit(context.Background(), func(context.Context) {}); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+		if err := pool.Submit(context.Background(), func(context.Context) {}); !errors.Is(err, ErrQueueFull) {
+			t.Fatalf("expected ErrQueueFull, got %v", err)
+		}
+		close(release)
+	})
+
+	t.Run("outlives the submitting context", func(t *testing.T) {
+		pool := NewWorkerPool(1, 1)
+		defer pool.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		if err := pool.Submit(ctx, func(ctx context.Context) {
+			time.Sleep(10 * time.Millisecond)
+			done <- ctx.Err()
+		}); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+		cancel()
+
+		if err := <-done; err != nil {
+			t.Fatalf("expected the task to keep running, got %v", err)
+		}
+	})
+
+	t.Run("close cancels running tasks", func(t *testing.T) {
+		pool := NewWorkerPool(1, 1)
+
+		started := make(chan struct{})
+		done := make(chan error, 1)
+		if err := pool.Submit(context.Background(), func(ctx context.Context) {
+			close(started)
+			<-ctx.Done()
+			done <- ctx.Err()
+		}); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+		<-started
+		pool.Close()
+
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected the task to be cancelled, got %v", err)
+		}
+		if err := pool.Submit(context.Background(), func(context.Context) {}); !errors.Is(err, ErrPoolClosed) {
+			t.Fatalf("expected ErrPoolClosed, got %v", err)
+		}
+	})
+}
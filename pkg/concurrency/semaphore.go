@@ -0,0 +1,24 @@
+package concurrency
+
+import "context"
+
+// Semaphore bounds how many callers run a section at once.
+type Semaphore chan struct{}
+
+func NewSemaphore(n int) Semaphore {
+	return make(Semaphore, n)
+}
+
+// Acquire waits for a free slot or for ctx to end, whichever comes first.
+func (s Semaphore) Acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s Semaphore) Release() {
+	<-s
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -9,12 +10,27 @@ import (
 
 const DefaultPort = "3000"
 
+// placeholderSigningKey is a publicly known signing key; production refuses it
+// like an empty one.
+const placeholderSigningKey = "change-me-in-production"
+
 type Config struct {
 	App      AppConfig      `yaml:"app" env-prefix:"APP_"`
 	Server   ServerConfig   `yaml:"server" env-prefix:"SERVER_"`
 	Logger   LoggerConfig   `yaml:"logger" env-prefix:"LOGGER_"`
 	Database DatabaseConfig `yaml:"database" env-prefix:"DATABASE_"`
 	Trace    TraceConfig    `yaml:"trace" env-prefix:"TRACE_"`
+	Auth     AuthConfig     `yaml:"auth" env-prefix:"AUTH_"`
+	Password PasswordConfig `yaml:"password" env-prefix:"PASSWORD_"`
+	Login    LoginConfig    `yaml:"login" env-prefix:"LOGIN_"`
+	Reset    ResetConfig    `yaml:"password_reset" env-prefix:"PASSWORD_RESET_"`
+	Deletion DeletionConfig `yaml:"deletion" env-prefix:"DELETION_"`
+	Export   ExportConfig   `yaml:"export" env-prefix:"EXPORT_"`
+	Tenant   TenantConfig   `yaml:"tenant" env-prefix:"TENANT_"`
+	Catalog  CatalogConfig  `yaml:"catalog" env-prefix:"CATALOG_"`
+	Blob     BlobConfig     `yaml:"blob" env-prefix:"BLOB_"`
+	Images   ImagesConfig   `yaml:"images" env-prefix:"IMAGES_"`
+	Mailer   MailerConfig   `yaml:"mailer" env-prefix:"MAILER_"`
 }
 
 type DatabaseConfig struct {
@@ -23,12 +39,20 @@ type DatabaseConfig struct {
 	Bucket   string `yaml:"bucket" env:"BUCKET"`
 	Username string `yaml:"username" env:"USERNAME"`
 	Password string `yaml:"password" env:"PASSWORD"`
+	// SearchIndex names the Full Text Search index over products.
+	SearchIndex string `yaml:"search_index" env:"SEARCH_INDEX" env-default:"products"`
 }
 
 type ServerConfig struct {
 	ReadTimeout  string `yaml:"read_timeout" env:"READ_TIMEOUT" env-default:"5s"`
 	WriteTimeout string `yaml:"write_timeout" env:"WRITE_TIMEOUT" env-default:"10s"`
 	IdleTimeout  string `yaml:"idle_timeout" env:"IDLE_TIMEOUT" env-default:"120s"`
+	// ProxyHeader carries the client address when the service sits behind a
+	// reverse proxy. Use one the proxy overwrites, such as X-Real-IP: the
+	// first X-Forwarded-For entry is whatever the client sent. The header is
+	// only honoured on requests from TrustedProxies (addresses or CIDRs).
+	ProxyHeader    string   `yaml:"proxy_header" env:"PROXY_HEADER"`
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES" env-separator:","`
 }
 
 type AppConfig struct {
@@ -46,6 +70,123 @@ type TraceConfig struct {
 	Endpoint string `yaml:"endpoint" env:"ENDPOINT" env-default:"localhost:4318"`
 }
 
+type AuthConfig struct {
+	SigningKey       string `yaml:"signing_key" env:"SIGNING_KEY"`
+	Issuer           string `yaml:"issuer" env:"ISSUER" env-default:"microservice"`
+	AccessTokenTTL   string `yaml:"access_token_ttl" env:"ACCESS_TOKEN_TTL" env-default:"15m"`
+	RefreshTokenTTL  string `yaml:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL" env-default:"720h"`
+	EmailChangeTTL   string `yaml:"email_change_ttl" env:"EMAIL_CHANGE_TTL" env-default:"24h"`
+	PasswordResetTTL string `yaml:"password_reset_ttl" env:"PASSWORD_RESET_TTL" env-default:"1h"`
+	AdminEmail       string `yaml:"admin_email" env:"ADMIN_EMAIL"`
+	AdminPassword    string `yaml:"admin_password" env:"ADMIN_PASSWORD"`
+}
+
+type PasswordConfig struct {
+	Algorithm         string `yaml:"algorithm" env:"ALGORITHM" env-default:"argon2id"`
+	BcryptCost        int    `yaml:"bcrypt_cost" env:"BCRYPT_COST" env-default:"12"`
+	Argon2Memory      uint32 `yaml:"argon2_memory" env:"ARGON2_MEMORY" env-default:"65536"`
+	Argon2Iterations  uint32 `yaml:"argon2_iterations" env:"ARGON2_ITERATIONS" env-default:"3"`
+	Argon2Parallelism uint8  `yaml:"argon2_parallelism" env:"ARGON2_PARALLELISM" env-default:"2"`
+}
+
+type LoginConfig struct {
+	FreeAttempts            int    `yaml:"free_attempts" env:"FREE_ATTEMPTS" env-default:"3"`
+	BaseDelay               string `yaml:"base_delay" env:"BASE_DELAY" env-default:"1s"`
+	MaxDelay                string `yaml:"max_delay" env:"MAX_DELAY" env-default:"5m"`
+	AccountLockoutThreshold int    `yaml:"account_lockout_threshold" env:"ACCOUNT_LOCKOUT_THRESHOLD" env-default:"10"`
+	IPLockoutThreshold      int    `yaml:"ip_lockout_threshold" env:"IP_LOCKOUT_THRESHOLD" env-default:"50"`
+	LockoutDuration         string `yaml:"lockout_duration" env:"LOCKOUT_DURATION" env-default:"15m"`
+	ResetAfter              string `yaml:"reset_after" env:"RESET_AFTER" env-default:"24h"`
+}
+
+// ResetConfig throttles password reset requests per address and per client
+// address. The first free requests cost nothing; after that each request
+// doubles the wait from BaseDelay up to MaxDelay. The count is forgotten after
+// ResetAfter without requests.
+type ResetConfig struct {
+	FreeRequests   int    `yaml:"free_requests" env:"FREE_REQUESTS" env-default:"3"`
+	IPFreeRequests int    `yaml:"ip_free_requests" env:"IP_FREE_REQUESTS" env-default:"20"`
+	BaseDelay      string `yaml:"base_delay" env:"BASE_DELAY" env-default:"1m"`
+	MaxDelay       string `yaml:"max_delay" env:"MAX_DELAY" env-default:"1h"`
+	ResetAfter     string `yaml:"reset_after" env:"RESET_AFTER" env-default:"1h"`
+}
+
+type DeletionConfig struct {
+	GracePeriod   string `yaml:"grace_period" env:"GRACE_PERIOD" env-default:"720h"`
+	PurgeInterval string `yaml:"purge_interval" env:"PURGE_INTERVAL" env-default:"1h"`
+}
+
+// ExportConfig sizes personal data exports. Exports of more than SyncLimit
+// products are built by Workers goroutines from a queue of at most QueueSize
+// jobs; requests that would overflow it are refused.
+type ExportConfig struct {
+	SyncLimit int    `yaml:"sync_limit" env:"SYNC_LIMIT" env-default:"500"`
+	TTL       string `yaml:"ttl" env:"TTL" env-default:"24h"`
+	Workers   int    `yaml:"workers" env:"WORKERS" env-default:"2"`
+	QueueSize int    `yaml:"queue_size" env:"QUEUE_SIZE" env-default:"20"`
+}
+
+// TenantConfig controls how requests are mapped to tenants. BaseDomain is
+// optional; when set, the subdomain in front of it names the tenant. Tenants
+// lists the tenants served besides the default one; requests naming any
+// other tenant are refused.
+type TenantConfig struct {
+	Header     string   `yaml:"header" env:"HEADER" env-default:"X-Tenant-ID"`
+	BaseDomain string   `yaml:"base_domain" env:"BASE_DOMAIN"`
+	Tenants    []string `yaml:"tenants" env:"TENANTS" env-separator:","`
+}
+
+// CatalogConfig holds product defaults. DefaultCurrency prices products
+// created without a currency and stored before currencies existed.
+type CatalogConfig struct {
+	DefaultCurrency string `yaml:"default_currency" env:"DEFAULT_CURRENCY" env-default:"USD"`
+}
+
+// BlobConfig selects where uploaded files live. The local driver keeps them
+// under Dir and serves its own signed URLs below BaseURL, signed with
+// SigningKey; the s3 driver talks to any S3-compatible service and presigns
+// URLs against it. URLTTL is how long a signed URL stays valid.
+type BlobConfig struct {
+	Driver            string `yaml:"driver" env:"DRIVER" env-default:"local"`
+	Dir               string `yaml:"dir" env:"DIR" env-default:"blobs"`
+	BaseURL           string `yaml:"base_url" env:"BASE_URL" env-default:"http://localhost:3000"`
+	SigningKey        string `yaml:"signing_key" env:"SIGNING_KEY"`
+	URLTTL            string `yaml:"url_ttl" env:"URL_TTL" env-default:"15m"`
+	S3Endpoint        string `yaml:"s3_endpoint" env:"S3_ENDPOINT"`
+	S3Region          string `yaml:"s3_region" env:"S3_REGION" env-default:"us-east-1"`
+	S3Bucket          string `yaml:"s3_bucket" env:"S3_BUCKET"`
+	S3AccessKeyID     string `yaml:"s3_access_key_id" env:"S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `yaml:"s3_secret_access_key" env:"S3_SECRET_ACCESS_KEY"`
+	S3PathStyle       bool   `yaml:"s3_path_style" env:"S3_PATH_STYLE" env-default:"true"`
+}
+
+// ImagesConfig limits product image uploads. MaxSize is in bytes;
+// MaxProcessing is how many uploads are decoded at once, each taking up to
+// 64MB while it is.
+type ImagesConfig struct {
+	MaxSize       int64 `yaml:"max_size" env:"MAX_SIZE" env-default:"5242880"`
+	MaxPerProduct int   `yaml:"max_per_product" env:"MAX_PER_PRODUCT" env-default:"10"`
+	ThumbnailSize int   `yaml:"thumbnail_size" env:"THUMBNAIL_SIZE" env-default:"256"`
+	MaxProcessing int   `yaml:"max_processing" env:"MAX_PROCESSING" env-default:"4"`
+}
+
+// MailerConfig selects how mail leaves the service. Mail is sent by Workers
+// goroutines from a queue of at most QueueSize messages; requests that would
+// overflow it are refused.
+type MailerConfig struct {
+	Driver    string `yaml:"driver" env:"DRIVER" env-default:"memory"`
+	From      string `yaml:"from" env:"FROM" env-default:"no-reply@microservice.local"`
+	Dir       string `yaml:"dir" env:"DIR" env-default:"outbox"`
+	Workers   int    `yaml:"workers" env:"WORKERS" env-default:"2"`
+	QueueSize int    `yaml:"queue_size" env:"QUEUE_SIZE" env-default:"100"`
+	// The smtp driver's relay; the password is meant to come from the
+	// environment.
+	SMTPHost     string `yaml:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort     int    `yaml:"smtp_port" env:"SMTP_PORT" env-default:"587"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD"`
+}
+
 func LoadConfig() (*Config, error) {
 	cfg := &Config{}
 
@@ -77,5 +218,43 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
+
+// validate refuses a proxy header nobody is trusted to send, and production
+// configs whose signing keys are missing or still the placeholder; both keys
+// are meant to come from the environment. Production must also deliver mail
+// and store blobs somewhere other than the local disk.
+func (c *Config) validate() error {
+	if c.Server.ProxyHeader != "" && len(c.Server.TrustedProxies) == 0 {
+		return errors.New("SERVER_TRUSTED_PROXIES must be set when SERVER_PROXY_HEADER is")
+	}
+
+	if c.App.Env != "production" {
+		return nil
+	}
+
+	keys := []struct {
+		env, value string
+	}{
+		{"AUTH_SIGNING_KEY", c.Auth.SigningKey},
+		{"BLOB_SIGNING_KEY", c.Blob.SigningKey},
+	}
+	for _, key := range keys {
+		if key.value == "" || key.value == placeholderSigningKey {
+			return fmt.Errorf("%s must be set to a secret value in production", key.env)
+		}
+	}
+
+	if c.Mailer.Driver != "smtp" {
+		return fmt.Errorf("MAILER_DRIVER must be smtp in production, not %q", c.Mailer.Driver)
+	}
+	if c.Blob.Driver != "s3" {
+		return fmt.Errorf("BLOB_DRIVER must be s3 in production, not %q", c.Blob.Driver)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileOutbox writes every message as a JSON file into a directory, which is
+// handy when running the service locally without an SMTP relay.
+type FileOutbox struct {
+	from string
+	dir  string
+}
+
+func NewFileOutbox(from, dir string) (*FileOutbox, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+
+	return &FileOutbox{
+		from: from,
+		dir:  dir,
+	}, nil
+}
+
+func (o *FileOutbox) Send(ctx context.Context, msg Message) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	if msg.From == "" {
+		msg.From = o.from
+	}
+	msg.SentAt = time.Now()
+
+	data, err := json.MarshalIndent(msg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.json", msg.SentAt.UTC().Format("20060102T150405"), uuid.New().String())
+	return os.WriteFile(filepath.Join(o.dir, name), data, 0o644)
+}
@@ -0,0 +1,18 @@
+package mailer
+
+import (
+	"context"
+	"time"
+)
+
+type Message struct {
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Outbox keeps sent messages in memory. It is meant for local runs and tests
+// that need to read back what would have been delivered.
+type Outbox struct {
+	from     string
+	messages []Message
+	mu       sync.RWMutex
+}
+
+func NewOutbox(from string) *Outbox {
+	return &Outbox{from: from}
+}
+
+func (o *Outbox) Send(ctx context.Context, msg Message) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	if msg.From == "" {
+		msg.From = o.from
+	}
+	msg.SentAt = time.Now()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+func (o *Outbox) Messages() []Message {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	messages := make([]Message, len(o.messages))
+	copy(messages, o.messages)
+	return messages
+}
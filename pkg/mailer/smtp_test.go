@@ -0,0 +1,131 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRelay accepts a single plain SMTP session and hands back the envelope
+// and data it received.
+func fakeRelay(t *testing.T) (host string, port int, received <-chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		text := textproto.NewConn(conn)
+		var got []string
+		text.PrintfLine("220 fake ready")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch verb {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 fake")
+			case "MAIL", "RCPT":
+				got = append(got, line)
+				text.PrintfLine("250 ok")
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				data, err := text.ReadDotLines()
+				if err != nil {
+					return
+				}
+				got = append(got, data...)
+				text.PrintfLine("250 queued")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				lines <- got
+				return
+			default:
+				text.PrintfLine("502 unsupported")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, lines
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	host, port, received := fakeRelay(t)
+	m, err := NewSMTPMailer("no-reply@example.com", host, port, "", "")
+	if err != nil {
+		t.Fatalf("NewSMTPMailer: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = m.Send(ctx, Message{
+		To:      "ada@example.com",
+		Subject: "Reset\r\nBcc: eve@example.com",
+		Body:    "Hi Ada,\n.\nbye",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var lines []string
+	select {
+	case lines = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the relay to receive a message")
+	}
+	got := strings.Join(lines, "\n")
+
+	for _, want := range []string{
+		"MAIL FROM:<no-reply@example.com>",
+		"RCPT TO:<ada@example.com>",
+		"To: ada@example.com",
+		"Content-Type: text/plain; charset=utf-8",
+		// A lone dot survives the DATA encoding.
+		"Hi Ada,\n.\nbye",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected the message to contain %q, got:\n%s", want, got)
+		}
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Fatalf("expected the subject not to inject headers, got:\n%s", got)
+		}
+	}
+}
+
+func TestSMTPMailer_RefusesLineBreaksInAddresses(t *testing.T) {
+	m, err := NewSMTPMailer("no-reply@example.com", "127.0.0.1", 25, "", "")
+	if err != nil {
+		t.Fatalf("NewSMTPMailer: %v", err)
+	}
+	if err := m.Send(context.Background(), Message{To: "ada@example.com\r\nRCPT TO:<eve@example.com>"}); err == nil {
+		t.Fatal("expected an address with a line break to be refused")
+	}
+}
+
+func TestNewSMTPMailer(t *testing.T) {
+	for _, port := range []int{0, -1} {
+		if _, err := NewSMTPMailer("no-reply@example.com", "smtp.example.com", port, "", ""); err == nil {
+			t.Fatalf("expected port %d to be refused", port)
+		}
+	}
+	if _, err := NewSMTPMailer("no-reply@example.com", "", 587, "", ""); err == nil {
+		t.Fatal("expected a missing host to be refused")
+	}
+}
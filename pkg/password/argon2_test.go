@@ -0,0 +1,149 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// cheapArgon2id keeps the tests fast; the format does not depend on cost.
+var cheapArgon2id = Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func newTestArgon2id(t *testing.T, params Argon2idParams) *Argon2id {
+	t.Helper()
+	a, err := NewArgon2id(params)
+	if err != nil {
+		t.Fatalf("NewArgon2id: %v", err)
+	}
+	return a
+}
+
+func TestNewArgon2id(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  Argon2idParams
+		wantErr bool
+	}{
+		{"valid", cheapArgon2id, false},
+		{"too little memory per lane", Argon2idParams{Memory: 15, Iterations: 1, Parallelism: 2}, true},
+		{"no iterations", Argon2idParams{Memory: 64, Iterations: 0, Parallelism: 1}, true},
+		{"no lanes", Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 0}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewArgon2id(tt.params); (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestArgon2id_PHCFormat(t *testing.T) {
+	a := newTestArgon2id(t, cheapArgon2id)
+
+	hash, err := a.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("expected a PHC string carrying the parameters, got %q", hash)
+	}
+	if !a.Identifies(hash) {
+		t.Fatal("expected the hash to be identified as argon2id")
+	}
+
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		t.Fatalf("decodeArgon2id: %v", err)
+	}
+	if params != cheapArgon2id || len(salt) != argon2idSaltLength || len(key) != argon2idKeyLength {
+		t.Fatalf("expected %+v with a %d byte salt and %d byte key, got %+v, %d and %d",
+			cheapArgon2id, argon2idSaltLength, argon2idKeyLength, params, len(salt), len(key))
+	}
+
+	other, err := a.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if other == hash {
+		t.Fatal("expected every hash to get its own salt")
+	}
+}
+
+func TestArgon2id_Verify(t *testing.T) {
+	a := newTestArgon2id(t, cheapArgon2id)
+	hash, err := a.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	if ok, err := a.Verify(hash, "correct horse"); err != nil || !ok {
+		t.Fatalf("expected the password to verify, got %v, %v", ok, err)
+	}
+	if ok, err := a.Verify(hash, "wrong horse"); err != nil || ok {
+		t.Fatalf("expected a wrong password to fail without error, got %v, %v", ok, err)
+	}
+
+	// Hashes keep verifying after the preferred parameters change, because
+	// they carry their own.
+	stronger := newTestArgon2id(t, Argon2idParams{Memory: 128, Iterations: 2, Parallelism: 1})
+	if ok, err := stronger.Verify(hash, "correct horse"); err != nil || !ok {
+		t.Fatalf("expected the old hash to verify under new parameters, got %v, %v", ok, err)
+	}
+}
+
+func TestArgon2id_Malformed(t *testing.T) {
+	a := newTestArgon2id(t, cheapArgon2id)
+	hash, err := a.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	parts := strings.Split(hash, "$")
+
+	tests := map[string]string{
+		"empty":            "",
+		"wrong algorithm":  strings.Replace(hash, "argon2id", "argon2i", 1),
+		"missing part":     strings.Join(parts[:5], "$"),
+		"bad version":      strings.Replace(hash, "v=19", "v=x", 1),
+		"bad parameters":   strings.Replace(hash, "m=64,t=1,p=1", "m=64", 1),
+		"bad salt":         strings.Join([]string{"", parts[1], parts[2], parts[3], "!!", parts[5]}, "$"),
+		"empty key":        strings.Join([]string{"", parts[1], parts[2], parts[3], parts[4], ""}, "$"),
+		"unknown version":  strings.Replace(hash, "v=19", "v=16", 1),
+		"bcrypt, not ours": "$2a$10$abcdefghijklmnopqrstuu",
+	}
+	for name, malformed := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := a.Verify(malformed, "correct horse"); err == nil {
+				t.Fatal("expected an error")
+			}
+			if !a.NeedsRehash(malformed) {
+				t.Fatal("expected a malformed hash to need rehashing")
+			}
+		})
+	}
+
+	if _, err := a.Verify(tests["bad salt"], "correct horse"); !errors.Is(err, errMalformedArgon2id) {
+		t.Fatalf("expected errMalformedArgon2id, got %v", err)
+	}
+}
+
+func TestArgon2id_NeedsRehash(t *testing.T) {
+	a := newTestArgon2id(t, cheapArgon2id)
+	hash, err := a.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if a.NeedsRehash(hash) {
+		t.Fatal("expected a hash under the current parameters to be kept")
+	}
+
+	for _, params := range []Argon2idParams{
+		{Memory: 128, Iterations: 1, Parallelism: 1},
+		{Memory: 64, Iterations: 2, Parallelism: 1},
+		{Memory: 64, Iterations: 1, Parallelism: 2},
+	} {
+		if !newTestArgon2id(t, params).NeedsRehash(hash) {
+			t.Fatalf("expected a rehash when the parameters become %+v", params)
+		}
+	}
+}
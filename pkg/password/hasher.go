@@ -0,0 +1,56 @@
+package password
+
+import "errors"
+
+var ErrUnknownAlgorithm = errors.New("password hash uses an unknown algorithm")
+
+// Algorithm is a single hashing scheme. Identifies lets a Hasher route an
+// existing hash to the scheme that produced it.
+type Algorithm interface {
+	Identifies(hash string) bool
+	Hash(password string) (string, error)
+	Verify(hash, password string) (bool, error)
+	NeedsRehash(hash string) bool
+	// MaxLength is the longest password, in bytes, Hash accepts.
+	MaxLength() int
+}
+
+// Hasher hashes new passwords with the preferred algorithm and still verifies
+// hashes produced by any of the legacy ones.
+type Hasher struct {
+	preferred  Algorithm
+	algorithms []Algorithm
+}
+
+func NewHasher(preferred Algorithm, legacy ...Algorithm) *Hasher {
+	return &Hasher{
+		preferred:  preferred,
+		algorithms: append([]Algorithm{preferred}, legacy...),
+	}
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+func (h *Hasher) Verify(hash, password string) (bool, error) {
+	for _, a := range h.algorithms {
+		if a.Identifies(hash) {
+			return a.Verify(hash, password)
+		}
+	}
+	return false, ErrUnknownAlgorithm
+}
+
+// MaxLength is the preferred algorithm's: new passwords are only ever hashed
+// with it.
+func (h *Hasher) MaxLength() int {
+	return h.preferred.MaxLength()
+}
+
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if !h.preferred.Identifies(hash) {
+		return true
+	}
+	return h.preferred.NeedsRehash(hash)
+}
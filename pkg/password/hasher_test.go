@@ -0,0 +1,102 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestBcrypt(t *testing.T, cost int) *Bcrypt {
+	t.Helper()
+	b, err := NewBcrypt(cost)
+	if err != nil {
+		t.Fatalf("NewBcrypt: %v", err)
+	}
+	return b
+}
+
+func TestBcrypt(t *testing.T) {
+	if _, err := NewBcrypt(bcrypt.MinCost - 1); err == nil {
+		t.Fatal("expected a cost below the minimum to be refused")
+	}
+
+	b := newTestBcrypt(t, bcrypt.MinCost)
+	hash, err := b.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if !b.Identifies(hash) {
+		t.Fatalf("expected %q to be identified as bcrypt", hash)
+	}
+	if ok, err := b.Verify(hash, "correct horse"); err != nil || !ok {
+		t.Fatalf("expected the password to verify, got %v, %v", ok, err)
+	}
+	if ok, err := b.Verify(hash, "wrong horse"); err != nil || ok {
+		t.Fatalf("expected a wrong password to fail without error, got %v, %v", ok, err)
+	}
+
+	if b.NeedsRehash(hash) {
+		t.Fatal("expected a hash at the current cost to be kept")
+	}
+	if !newTestBcrypt(t, bcrypt.MinCost+1).NeedsRehash(hash) {
+		t.Fatal("expected a rehash when the cost goes up")
+	}
+
+	// bcrypt refuses what it would otherwise truncate, so the limit it
+	// reports is the one Hash enforces.
+	if _, err := b.Hash(strings.Repeat("a", b.MaxLength())); err != nil {
+		t.Fatalf("Hash at MaxLength: %v", err)
+	}
+	if _, err := b.Hash(strings.Repeat("a", b.MaxLength()+1)); err == nil {
+		t.Fatal("expected a password past MaxLength to be refused")
+	}
+}
+
+func TestHasher(t *testing.T) {
+	legacy := newTestBcrypt(t, bcrypt.MinCost)
+	preferred := newTestArgon2id(t, cheapArgon2id)
+	hasher := NewHasher(preferred, legacy)
+
+	t.Run("new hashes use the preferred algorithm", func(t *testing.T) {
+		hash, err := hasher.Hash("correct horse")
+		if err != nil {
+			t.Fatalf("Hash: %v", err)
+		}
+		if !preferred.Identifies(hash) {
+			t.Fatalf("expected an argon2id hash, got %q", hash)
+		}
+		if hasher.NeedsRehash(hash) {
+			t.Fatal("expected a preferred hash to be kept")
+		}
+		if hasher.MaxLength() != preferred.MaxLength() {
+			t.Fatalf("expected the preferred algorithm's limit %d, got %d", preferred.MaxLength(), hasher.MaxLength())
+		}
+	})
+
+	t.Run("legacy hashes verify and migrate", func(t *testing.T) {
+		old, err := legacy.Hash("correct horse")
+		if err != nil {
+			t.Fatalf("Hash: %v", err)
+		}
+		if ok, err := hasher.Verify(old, "correct horse"); err != nil || !ok {
+			t.Fatalf("expected the bcrypt hash to verify, got %v, %v", ok, err)
+		}
+		if ok, err := hasher.Verify(old, "wrong horse"); err != nil || ok {
+			t.Fatalf("expected a wrong password to fail, got %v, %v", ok, err)
+		}
+		if !hasher.NeedsRehash(old) {
+			t.Fatal("expected a bcrypt hash to need rehashing")
+		}
+	})
+
+	t.Run("unknown hashes are refused", func(t *testing.T) {
+		if _, err := hasher.Verify("$scrypt$whatever", "correct horse"); !errors.Is(err, ErrUnknownAlgorithm) {
+			t.Fatalf("expected ErrUnknownAlgorithm, got %v", err)
+		}
+		if !hasher.NeedsRehash("$scrypt$whatever") {
+			t.Fatal("expected an unknown hash to need rehashing")
+		}
+	})
+}